@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/bugdrill/backend/internal/execauth"
+	"github.com/gin-gonic/gin"
+)
+
+// Requests from the API are signed with the shared EXECUTOR_SECRET, and the
+// responses signed back; see internal/execauth for the scheme
+var (
+	executorSecret = []byte(os.Getenv("EXECUTOR_SECRET"))
+	production     = os.Getenv("ENV") == "production"
+
+	// signatureSkew is how far a request timestamp may be from now; nonces
+	// are remembered for twice as long, so a replay is always caught
+	signatureSkew = time.Duration(getEnvInt("SIGNATURE_SKEW_SEC", 300)) * time.Second
+
+	nonces = &nonceCache{seen: make(map[string]time.Time)}
+)
+
+// nonceCache remembers recently used nonces to reject replayed requests
+type nonceCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+// add records nonce and reports whether it was new
+func (n *nonceCache) add(nonce string, now time.Time) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for k, expires := range n.seen {
+		if now.After(expires) {
+			delete(n.seen, k)
+		}
+	}
+	if _, ok := n.seen[nonce]; ok {
+		return false
+	}
+	n.seen[nonce] = now.Add(2 * signatureSkew)
+	return true
+}
+
+// signedWriter holds the response back until it can be signed
+type signedWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *signedWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+func (w *signedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// requireSignature verifies signed requests and signs the responses. Without
+// a secret it lets everything through, except in production where it refuses
+// to run code at all.
+func requireSignature() gin.HandlerFunc {
+	if len(executorSecret) == 0 {
+		if production {
+			log.Println("❌ EXECUTOR_SECRET is not set; refusing all execution requests")
+		} else {
+			log.Println("⚠️  EXECUTOR_SECRET is not set; requests are not authenticated")
+		}
+	}
+
+	return func(c *gin.Context) {
+		if len(executorSecret) == 0 {
+			if production {
+				log.Printf("❌ Rejected request from %s: no secret configured", c.ClientIP())
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, ExecuteResponse{
+					Success: false,
+					Error:   "Executor is not configured to accept requests",
+				})
+				return
+			}
+			c.Next()
+			return
+		}
+
+		reject := func(reason string) {
+			log.Printf("❌ Rejected request from %s: %s", c.ClientIP(), reason)
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ExecuteResponse{
+				Success: false,
+				Error:   "Unauthorized",
+			})
+		}
+
+		timestamp := c.GetHeader(execauth.HeaderTimestamp)
+		nonce := c.GetHeader(execauth.HeaderNonce)
+		signature := c.GetHeader(execauth.HeaderSignature)
+		if timestamp == "" || nonce == "" || signature == "" {
+			reject("missing signature headers")
+			return
+		}
+
+		sec, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			reject("malformed timestamp")
+			return
+		}
+		now := time.Now()
+		if skew := now.Sub(time.Unix(sec, 0)); skew > signatureSkew || skew < -signatureSkew {
+			reject("timestamp outside the allowed window")
+			return
+		}
+
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBytes)
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				log.Printf("❌ Request body exceeds %d bytes", maxRequestBytes)
+				c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, ExecuteResponse{
+					Success: false,
+					Error:   fmt.Sprintf("Request body exceeds %d bytes", maxRequestBytes),
+				})
+				return
+			}
+			reject("unreadable body")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		if !execauth.VerifyRequest(executorSecret, c.Request.Method, c.Request.URL.Path, timestamp, nonce, body, signature) {
+			reject("bad signature")
+			return
+		}
+		if !nonces.add(nonce, now) {
+			reject("replayed nonce")
+			return
+		}
+
+		w := &signedWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+
+		w.ResponseWriter.Header().Set(execauth.HeaderSignature, execauth.SignResponse(executorSecret, nonce, w.body.Bytes()))
+		w.ResponseWriter.WriteHeaderNow()
+		w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
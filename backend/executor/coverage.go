@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Coverage is collected when a request asks for it. Python code learns so
+// from this variable, which reaches the interpreter running it inside
+// Docker and out; Go runs `go test -coverprofile`.
+const (
+	coverageEnv    = "SNIPPET_COVERAGE"
+	coveragePrefix = "__COVERAGE__"
+	// goCoverProfile is written into the workspace, next to the package
+	goCoverProfile = "zz_cover.out"
+)
+
+// Coverage is which lines of the code ran, out of those that can
+type Coverage struct {
+	Covered    []int `json:"covered"`
+	Executable []int `json:"executable"`
+}
+
+// coverageEnvVar tells the sandbox whether to collect coverage
+func coverageEnvVar(on bool) string {
+	if on {
+		return coverageEnv + "=1"
+	}
+	return coverageEnv + "="
+}
+
+// parseCoverage reads the runner's coverage report
+func parseCoverage(reports map[string]json.RawMessage) *Coverage {
+	var coverage Coverage
+	if !decodeReport(reports, "coverage", &coverage) {
+		return nil
+	}
+	return &coverage
+}
+
+// parseGoCoverage reads the cover profiles a Go run writes to its report
+// channel, the container's stderr: the whole run's, then one per test, each
+// after a line with the prefix and the test's name. Whatever precedes them is
+// returned as the run's stderr.
+func parseGoCoverage(reports []byte) (*Coverage, map[string]*Coverage, []byte) {
+	at := bytes.Index(reports, []byte(coveragePrefix+"\n"))
+	if at < 0 || at > 0 && reports[at-1] != '\n' {
+		return nil, nil, reports
+	}
+
+	var coverage *Coverage
+	tests := map[string]*Coverage{}
+	for i, section := range bytes.Split(reports[at+len(coveragePrefix):], []byte("\n"+coveragePrefix)) {
+		name, profile, _ := bytes.Cut(section, []byte("\n"))
+		if i == 0 {
+			coverage = parseGoCoverProfile(profile)
+		} else if c := parseGoCoverProfile(profile); c != nil {
+			tests[string(name)] = c
+		}
+	}
+	return coverage, tests, bytes.TrimSuffix(reports[:at], []byte("\n"))
+}
+
+// parseGoCoverProfile reads the lines of snippet.go from a cover profile.
+// Blocks are statement ranges; a line is covered when any block on it ran.
+func parseGoCoverProfile(profile []byte) *Coverage {
+	executable, covered := map[int]bool{}, map[int]bool{}
+	scanner := bufio.NewScanner(bytes.NewReader(profile))
+	for scanner.Scan() {
+		// snippet/snippet.go:12.2,14.16 2 1
+		name, block, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || !strings.HasSuffix(name, "/snippet.go") {
+			continue
+		}
+		fields := strings.Fields(block)
+		if len(fields) != 3 {
+			continue
+		}
+		start, end, ok := strings.Cut(fields[0], ",")
+		if !ok {
+			continue
+		}
+		first, _ := strconv.Atoi(strings.Split(start, ".")[0])
+		last, _ := strconv.Atoi(strings.Split(end, ".")[0])
+		count, _ := strconv.Atoi(fields[2])
+		for line := first; line > 0 && line <= last; line++ {
+			executable[line] = true
+			if count > 0 {
+				covered[line] = true
+			}
+		}
+	}
+	if len(executable) == 0 {
+		return nil
+	}
+	return &Coverage{Covered: sortedLines(covered), Executable: sortedLines(executable)}
+}
+
+func sortedLines(set map[int]bool) []int {
+	lines := make([]int, 0, len(set))
+	for line := range set {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
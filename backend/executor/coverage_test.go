@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseGoCoverProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile string
+		want    *Coverage
+	}{
+		{
+			name: "blocks over lines",
+			profile: `mode: atomic
+snippet/snippet.go:3.28,4.11 1 1
+snippet/snippet.go:4.11,6.3 1 0
+snippet/snippet.go:7.2,7.10 1 1
+`,
+			want: &Coverage{Covered: []int{3, 4, 7}, Executable: []int{3, 4, 5, 6, 7}},
+		},
+		{
+			name: "other files are left out",
+			profile: `mode: set
+snippet/helpers.go:1.1,2.2 1 1
+snippet/snippet.go:5.2,5.9 1 0
+`,
+			want: &Coverage{Covered: []int{}, Executable: []int{5}},
+		},
+		{
+			name:    "malformed lines are skipped",
+			profile: "mode: set\nsnippet/snippet.go:garbage\nsnippet/snippet.go:2.1,2.5 1\nsnippet/snippet.go:3.1,3.5 1 2\n",
+			want:    &Coverage{Covered: []int{3}, Executable: []int{3}},
+		},
+		{
+			name:    "nothing for snippet.go",
+			profile: "mode: set\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseGoCoverProfile([]byte(tt.profile)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseGoCoverage(t *testing.T) {
+	reports := []byte("sh: warning\n" +
+		"\n" + coveragePrefix + "\nmode: set\nsnippet/snippet.go:3.1,3.5 1 1\nsnippet/snippet.go:4.1,4.5 1 1\n" +
+		"\n" + coveragePrefix + "TestA\nmode: set\nsnippet/snippet.go:3.1,3.5 1 1\nsnippet/snippet.go:4.1,4.5 1 0\n" +
+		"\n" + coveragePrefix + "TestB\nmode: set\nsnippet/snippet.go:3.1,3.5 1 0\nsnippet/snippet.go:4.1,4.5 1 1\n")
+
+	coverage, tests, stderr := parseGoCoverage(reports)
+	if want := (&Coverage{Covered: []int{3, 4}, Executable: []int{3, 4}}); !reflect.DeepEqual(coverage, want) {
+		t.Fatalf("expected the run's coverage %+v, got %+v", want, coverage)
+	}
+	want := map[string]*Coverage{
+		"TestA": {Covered: []int{3}, Executable: []int{3, 4}},
+		"TestB": {Covered: []int{4}, Executable: []int{3, 4}},
+	}
+	if !reflect.DeepEqual(tests, want) {
+		t.Fatalf("expected per-test coverage %+v, got %+v", want, tests)
+	}
+	if string(stderr) != "sh: warning\n" {
+		t.Fatalf("expected what precedes the profiles as stderr, got %q", stderr)
+	}
+
+	// Without a profile everything is stderr
+	if coverage, tests, stderr := parseGoCoverage([]byte("killed\n")); coverage != nil || tests != nil || string(stderr) != "killed\n" {
+		t.Fatalf("expected no coverage, got %+v %+v and %q", coverage, tests, stderr)
+	}
+}
@@ -0,0 +1,322 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Diagnostics check code without running it, so the editor can underline
+// problems as the learner types. Python is compiled, and held to the policy,
+// in a short-lived interpreter under tight rlimits. Go is parsed in process
+// and, once it parses, type-checked and vetted by `go vet`, which never runs
+// the code.
+var (
+	diagnosticsTimeout = time.Duration(getEnvInt("DIAGNOSTICS_TIMEOUT_SEC", 5)) * time.Second
+	// go vet may have to compile the standard library's export data first
+	goVetTimeout = time.Duration(getEnvInt("GO_VET_TIMEOUT_SEC", 30)) * time.Second
+)
+
+const (
+	maxDiagnostics        = 100
+	maxDiagnosticsOutput  = 64 << 10
+	severityError         = "error"
+	severityWarning       = "warning"
+	diagnosticsPythonFile = "<snippet>"
+)
+
+type DiagnosticsRequest struct {
+	Code     string          `json:"code"`
+	Language string          `json:"language" binding:"required"`
+	Files    []WorkspaceFile `json:"files"`
+}
+
+// Diagnostic is a problem at a 1-based position in the code; Column is 0
+// when only the line is known. Source is "syntax", "compile", "vet" or
+// "policy".
+type Diagnostic struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Source   string `json:"source"`
+}
+
+type DiagnosticsResponse struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+	Error       string       `json:"error,omitempty"`
+}
+
+// pythonDiagnostics compiles the code on stdin, recording SyntaxWarnings,
+// and checks code that compiles against the policy in argv[3]. It follows
+// pythonPolicyCheck, which is handed a "null" policy so it stands aside.
+const pythonDiagnostics = `import resource as __resource, warnings as __warnings
+__resource.setrlimit(__resource.RLIMIT_CPU, (2, 2))
+__resource.setrlimit(__resource.RLIMIT_AS, (256 << 20, 256 << 20))
+__src = __sys.stdin.read()
+__out, __ok = [], False
+def __diag(line, column, severity, message, source):
+    __out.append({"line": line or 1, "column": column or 0, "severity": severity, "message": message, "source": source})
+with __warnings.catch_warnings(record=True) as __caught:
+    __warnings.simplefilter("always")
+    try:
+        compile(__src, "` + diagnosticsPythonFile + `", "exec", dont_inherit=True)
+        __ok = True
+    except SyntaxError as e:
+        __diag(e.lineno, e.offset, "error", "%s: %s" % (type(e).__name__, e.msg), "syntax")
+    except (ValueError, MemoryError, RecursionError) as e:
+        __diag(1, 0, "error", "%s: %s" % (type(e).__name__, e), "syntax")
+for __w in __caught:
+    if __w.filename == "` + diagnosticsPythonFile + `":
+        __diag(__w.lineno, 0, "warning", "%s: %s" % (__w.category.__name__, __w.message), "syntax")
+if __ok:
+    __files = __json.loads(__os.environ.get("` + workspaceEnv + `") or "[]")
+    __local = {f["path"].split("/")[0].removesuffix(".py") for f in __files if f["path"].endswith(".py")}
+    __policy = __json.loads(__sys.argv[3])
+    for __v in __policy_violations(__src, __policy, __local, escapes=__module_escapes(__policy["modules"])):
+        __diag(__v["line"], __v["column"], "error", __v["message"], "policy")
+__json.dump(__out, __sys.stdout)
+`
+
+func handleDiagnostics(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBytes)
+
+	var req DiagnosticsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, DiagnosticsResponse{
+				Error: fmt.Sprintf("Request body exceeds %d bytes", maxRequestBytes),
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, DiagnosticsResponse{Error: fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+	if err := validateWorkspace(req.Files); err != nil {
+		c.JSON(http.StatusBadRequest, DiagnosticsResponse{Error: fmt.Sprintf("Invalid request: %v", err)})
+		return
+	}
+
+	var diagnostics []Diagnostic
+	var err error
+	switch req.Language {
+	case "python":
+		diagnostics, err = diagnosePython(c.Request.Context(), req)
+	case "go":
+		diagnostics, err = diagnoseGo(c.Request.Context(), req)
+	default:
+		c.JSON(http.StatusBadRequest, DiagnosticsResponse{Error: "Only Python and Go are supported"})
+		return
+	}
+	if c.Request.Context().Err() != nil {
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Diagnostics failed: %v", err)
+		c.JSON(http.StatusInternalServerError, DiagnosticsResponse{Error: "Diagnostics failed"})
+		return
+	}
+
+	sort.SliceStable(diagnostics, func(i, j int) bool {
+		if diagnostics[i].Line != diagnostics[j].Line {
+			return diagnostics[i].Line < diagnostics[j].Line
+		}
+		return diagnostics[i].Column < diagnostics[j].Column
+	})
+	if len(diagnostics) > maxDiagnostics {
+		diagnostics = diagnostics[:maxDiagnostics]
+	}
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	c.JSON(http.StatusOK, DiagnosticsResponse{Diagnostics: diagnostics})
+}
+
+// diagnosePython runs the compile and policy check in an isolated
+// interpreter with no environment beyond the workspace's file list
+func diagnosePython(parent context.Context, req DiagnosticsRequest) ([]Diagnostic, error) {
+	ctx, cancel := context.WithTimeout(parent, diagnosticsTimeout)
+	defer cancel()
+
+	stdout := &limitedBuffer{limit: maxDiagnosticsOutput}
+	stderr := &limitedBuffer{limit: maxDiagnosticsOutput}
+	cmd := exec.CommandContext(ctx, "python3", "-I", "-c", pythonPolicyCheck+pythonDiagnostics,
+		"", "null", policyArg(policies["python"]))
+	cmd.Dir = os.TempDir()
+	cmd.Env = []string{workspaceEnvVar(req.Files)}
+	cmd.Stdin = strings.NewReader(req.Code)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("python diagnostics timed out")
+		}
+		lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+		return nil, fmt.Errorf("python diagnostics: %v: %s", err, lines[len(lines)-1])
+	}
+
+	var diagnostics []Diagnostic
+	if err := json.Unmarshal([]byte(stdout.String()), &diagnostics); err != nil {
+		return nil, fmt.Errorf("python diagnostics: %w", err)
+	}
+	return diagnostics, nil
+}
+
+// diagnoseGo reports every syntax error, or else what go vet finds. Code
+// without a package clause gets one on its first line, so lines still match
+// the editor's.
+func diagnoseGo(ctx context.Context, req DiagnosticsRequest) ([]Diagnostic, error) {
+	pkg := "snippet"
+	for _, f := range req.Files {
+		if m := goPackageClause.FindStringSubmatch(f.Content); m != nil && strings.HasSuffix(f.Path, ".go") && !strings.HasSuffix(f.Path, "_test.go") {
+			pkg = m[1]
+			break
+		}
+	}
+	code, shift := req.Code, 0
+	if !goPackageClause.MatchString(code) {
+		prefix := "package " + pkg + "; "
+		code, shift = prefix+code, len(prefix)
+	}
+	at := func(line, column int) (int, int) {
+		if line == 1 && column > 0 {
+			column = max(column-shift, 1)
+		}
+		return line, column
+	}
+
+	_, err := parser.ParseFile(token.NewFileSet(), "snippet.go", code, parser.AllErrors|parser.SkipObjectResolution)
+	var syntaxErrors scanner.ErrorList
+	if errors.As(err, &syntaxErrors) {
+		// One error per line; the rest are mostly the parser losing its way
+		syntaxErrors.RemoveMultiples()
+		var diagnostics []Diagnostic
+		for _, e := range syntaxErrors {
+			line, column := at(e.Pos.Line, e.Pos.Column)
+			diagnostics = append(diagnostics, Diagnostic{line, column, severityError, e.Msg, "syntax"})
+		}
+		return diagnostics, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	found, err := goVet(ctx, req.Files, code)
+	for i := range found {
+		found[i].Line, found[i].Column = at(found[i].Line, found[i].Column)
+	}
+	return found, err
+}
+
+// goVetError is an error in snippet.go that kept go vet from analyzing the
+// package, such as a missing import or a type error, of which it reports
+// only the first
+var goVetError = regexp.MustCompile(`^(?:vet: )?(?:\./)?snippet\.go:(\d+):(\d+): (.+)$`)
+
+// goVetPosn is where an analyzer finding is, as an absolute path
+var goVetPosn = regexp.MustCompile(`/snippet\.go:(\d+):(\d+)$`)
+
+// goVetReport is the -json output for one package: findings by analyzer
+type goVetReport map[string]map[string][]struct {
+	Posn    string `json:"posn"`
+	Message string `json:"message"`
+}
+
+// goVet runs go vet on the package in a fresh workspace. Analyzer findings
+// are warnings; anything that stops the analysis is an error.
+func goVet(parent context.Context, files []WorkspaceFile, code string) ([]Diagnostic, error) {
+	if _, err := exec.LookPath("go"); err != nil {
+		// Executors that only run Go in Docker have no toolchain of their own
+		return nil, nil
+	}
+	files = append(append([]WorkspaceFile{}, files...),
+		WorkspaceFile{"go.mod", goModule},
+		WorkspaceFile{"snippet.go", code},
+	)
+	dir, err := writeWorkspace(goWorkspace, files)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	ctx, cancel := context.WithTimeout(parent, goVetTimeout)
+	defer cancel()
+	stdout := &limitedBuffer{limit: maxDiagnosticsOutput}
+	stderr := &limitedBuffer{limit: maxDiagnosticsOutput}
+	cmd := exec.CommandContext(ctx, "go", "vet", "-json", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GOCACHE="+filepath.Join(goWorkspace, "cache"),
+		"GOFLAGS=-mod=mod",
+		"GOPROXY=off",
+		"GOTOOLCHAIN=local",
+		// Without cgo, vet can't be made to invoke a C compiler
+		"CGO_ENABLED=0",
+	)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return nil, fmt.Errorf("go vet timed out")
+	}
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+
+	var diagnostics []Diagnostic
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		if m := goVetError.FindStringSubmatch(line); m != nil {
+			diagnostics = append(diagnostics, goDiagnostic(m[1], m[2], severityError, m[3], "compile"))
+		}
+	}
+	// Tests make the package appear once more, with the same findings
+	seen := map[Diagnostic]bool{}
+	dec := json.NewDecoder(strings.NewReader(stdout.String()))
+	for {
+		var report goVetReport
+		if err := dec.Decode(&report); err != nil {
+			break
+		}
+		for _, analyzers := range report {
+			for analyzer, findings := range analyzers {
+				for _, f := range findings {
+					m := goVetPosn.FindStringSubmatch(f.Posn)
+					if m == nil {
+						continue
+					}
+					d := goDiagnostic(m[1], m[2], severityWarning, analyzer+": "+f.Message, "vet")
+					if !seen[d] {
+						seen[d] = true
+						diagnostics = append(diagnostics, d)
+					}
+				}
+			}
+		}
+	}
+	return diagnostics, nil
+}
+
+func goDiagnostic(line, column, severity, message, source string) Diagnostic {
+	d := Diagnostic{Severity: severity, Message: message, Source: source}
+	d.Line, _ = strconv.Atoi(line)
+	d.Column, _ = strconv.Atoi(column)
+	return d
+}
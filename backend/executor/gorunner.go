@@ -0,0 +1,614 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Go snippets are a package with a _test.go file, built with -race in one
+// container and run in another. Build artifacts, including the
+// race-instrumented standard library, are kept in a shared GOCACHE so only
+// the first run pays for compiling them; the tests never see it.
+var (
+	goImage       = getEnvString("GO_IMAGE", "golang:1.22")
+	goCacheVolume = getEnvString("GO_CACHE_VOLUME", "bugdrill-go-cache")
+	// goWorkspace is where diagnostics vet code on the host
+	goWorkspace = getEnvString("GO_WORKSPACE", filepath.Join(os.TempDir(), "bugdrill-go"))
+
+	// goBuildTimeout is allowed on top of the run's timeout for compiling
+	goBuildTimeout = time.Duration(getEnvInt("GO_BUILD_TIMEOUT_SEC", 60)) * time.Second
+	goMaxTestCount = getEnvInt("GO_MAX_TEST_COUNT", 20)
+
+	// The compiler and the race runtime need more than a Python run does
+	goMinMemoryMB = getEnvInt("GO_MIN_MEMORY_MB", 512)
+	goMinPids     = getEnvInt("GO_MIN_PIDS", 256)
+)
+
+const (
+	goModule        = "module snippet\n\ngo 1.22\n"
+	goContainerDir  = "/work"
+	goLeakCheckFile = "zz_leakcheck_test.go"
+	goLeakPrefix    = "__LEAK__"
+	goLeakEnd       = "__LEAK_END__"
+	goRaceBanner    = "WARNING: DATA RACE"
+	goRaceDivider   = "=================="
+	// goPackageResult collects failures that belong to no single test
+	goPackageResult = "(package)"
+)
+
+// GoTestOptions tune `go test`. TimeoutSec may not exceed the run's timeout.
+type GoTestOptions struct {
+	Count      int `json:"count"`
+	TimeoutSec int `json:"timeout_sec"`
+}
+
+// TestError is a structured failure: a data race, leaked goroutines, a
+// panic, a timeout or a failed assertion
+type TestError struct {
+	Kind      string          `json:"kind"`
+	Message   string          `json:"message"`
+	Locations []ErrorLocation `json:"locations,omitempty"`
+}
+
+// ErrorLocation is where in the snippet an error points. For races Op is the
+// access ("read", "previous write", "goroutine created"); for leaks it is
+// what the goroutine is blocked on.
+type ErrorLocation struct {
+	Op        string `json:"op,omitempty"`
+	Goroutine int    `json:"goroutine,omitempty"`
+	Function  string `json:"function,omitempty"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+}
+
+// goLeakCheck fails the run if goroutines started by the tests are still
+// alive once they finish, and dumps their stacks. It is only added when the
+// tests don't bring their own TestMain.
+const goLeakCheck = `package %s
+
+import (
+	__fmt "fmt"
+	__os "os"
+	__runtime "runtime"
+	__testing "testing"
+	__time "time"
+)
+
+func TestMain(m *__testing.M) {
+	before := __runtime.NumGoroutine()
+	code := m.Run()
+	// Goroutines on their way out get a moment to finish
+	for i := 0; i < 50 && __runtime.NumGoroutine() > before; i++ {
+		__time.Sleep(10 * __time.Millisecond)
+	}
+	if leaked := __runtime.NumGoroutine() - before; leaked > 0 {
+		buf := make([]byte, 1<<16)
+		buf = buf[:__runtime.Stack(buf, true)]
+		__fmt.Printf("\n` + goLeakPrefix + `%%d\n%%s\n` + goLeakEnd + `\n", leaked, buf)
+		if code == 0 {
+			code = 1
+		}
+	}
+	__os.Exit(code)
+}
+`
+
+var (
+	goPackageClause = regexp.MustCompile(`(?m)^package\s+(\w+)`)
+	goTestMain      = regexp.MustCompile(`(?m)^func\s+TestMain\s*\(`)
+	goRaceAccess    = regexp.MustCompile(`^((?:Previous )?(?:[Aa]tomic )?(?:[Rr]ead|[Ww]rite)) at 0x[0-9a-f]+ by (?:goroutine (\d+)|main goroutine):$`)
+	goRaceCreated   = regexp.MustCompile(`^Goroutine (\d+) \((?:running|finished)\) created at:$`)
+	goStackHeader   = regexp.MustCompile(`^goroutine (\d+) \[([^\],]+)`)
+	goFrameFile     = regexp.MustCompile(`^\s+(\S+\.go):(\d+)`)
+	goAssertion     = regexp.MustCompile(`^\s+(\w+\.go):(\d+): (.*)$`)
+	goPanicSuffix   = regexp.MustCompile(` \[recovered[^\]]*\]$`)
+)
+
+// goPackageFiles lays out the submission as a module, after the snippet's
+// own files. Code without a package clause gets the tests' package, as
+// snippets often hold only functions; it goes on the first line, so line
+// numbers in reports still match the code.
+func goPackageFiles(req ExecuteRequest) []WorkspaceFile {
+	testPkg := "snippet"
+	if m := goPackageClause.FindStringSubmatch(req.TestCode); m != nil {
+		testPkg = m[1]
+	}
+	code := req.Code
+	if !goPackageClause.MatchString(code) {
+		code = "package " + strings.TrimSuffix(testPkg, "_test") + "; " + code
+	}
+
+	files := append([]WorkspaceFile{}, req.Files...)
+	files = append(files,
+		WorkspaceFile{"go.mod", goModule},
+		WorkspaceFile{"snippet.go", code},
+		WorkspaceFile{"snippet_test.go", req.TestCode},
+	)
+	if !goTestMain.MatchString(req.TestCode) {
+		files = append(files, WorkspaceFile{goLeakCheckFile, fmt.Sprintf(goLeakCheck, testPkg)})
+	}
+	return files
+}
+
+// goTestBinary is the compiled tests, left in the work volume by the build
+const goTestBinary = "snippet.test"
+
+// goBuildArgs compile the tests without running them
+func goBuildArgs(coverage bool) []string {
+	args := []string{"test", "-c", "-race", "-o", goTestBinary}
+	if coverage {
+		args = append(args, "-cover")
+	}
+	return append(args, ".")
+}
+
+// goRunArgs run the compiled tests, reporting as `go test -json` would
+func goRunArgs(opts *GoTestOptions, limits ExecutionLimits, coverage bool) []string {
+	count, timeout := 1, limits.TimeoutSec
+	if opts != nil {
+		if opts.Count > 0 {
+			count = min(opts.Count, goMaxTestCount)
+		}
+		if opts.TimeoutSec > 0 {
+			timeout = min(opts.TimeoutSec, limits.TimeoutSec)
+		}
+	}
+	args := []string{"tool", "test2json", "-t", "./" + goTestBinary, "-test.v=test2json",
+		"-test.count=" + strconv.Itoa(count),
+		"-test.timeout=" + strconv.Itoa(timeout) + "s"}
+	if coverage {
+		args = append(args, "-test.coverprofile="+goCoverProfile)
+	}
+	return args
+}
+
+func executeGo(parent context.Context, req ExecuteRequest) ExecuteResponse {
+	startTime := time.Now()
+	limits := resolveLimits(req)
+	if req.TestCode == "" {
+		return ExecuteResponse{Success: false, Error: "Go snippets need test_code", Limits: &limits}
+	}
+	// There is no policy for Go, so it only ever runs in a container
+	if !isDockerAvailable() {
+		log.Println("❌ Refusing go test: Docker not available")
+		return ExecuteResponse{Success: false, Error: "Go snippets need Docker", Limits: &limits}
+	}
+	limits.MemoryMB = min(max(limits.MemoryMB, goMinMemoryMB), maxLimits.MemoryMB)
+	limits.Pids = min(max(limits.Pids, goMinPids), maxLimits.Pids)
+
+	// The test binary gets the run's timeout, and as long again to rerun
+	// each test for its coverage; compiling gets its own budget
+	runTimeout := time.Duration(limits.TimeoutSec) * time.Second
+	if req.Coverage {
+		runTimeout *= 2
+	}
+	ctx, cancel := context.WithTimeout(parent, runTimeout+goBuildTimeout)
+	defer cancel()
+
+	var overflowed atomic.Bool
+	onOverflow := func() {
+		overflowed.Store(true)
+		cancel()
+	}
+	stream := &goTestStream{out: &limitedBuffer{limit: limits.OutputBytes, onOverflow: onOverflow}}
+	reports := &limitedBuffer{limit: maxReportBytes}
+
+	log.Printf("🐳 Using Docker for go test (limits: %+v)", limits)
+	container := containerName()
+	volume := container + "-work"
+	if out, err := exec.CommandContext(ctx, "docker", "volume", "create", volume).CombinedOutput(); err != nil {
+		log.Printf("❌ Failed to create Go work volume: %v: %s", err, out)
+		return ExecuteResponse{Success: false, Error: "Failed to prepare Go workspace", Limits: &limits}
+	}
+	defer removeVolume(volume)
+
+	// The build owns the shared cache. Files are passed as arguments, as
+	// Python code is, and written inside the container.
+	build := &limitedBuffer{limit: limits.OutputBytes, onOverflow: onOverflow}
+	buildScript := `cd ` + goContainerDir + ` && while [ "$#" -gt 0 ]; do mkdir -p "$(dirname "$1")" && printf '%s' "$2" > "$1"; shift 2; done && exec go ` + strings.Join(goBuildArgs(req.Coverage), " ")
+	var buildArgs []string
+	for _, f := range goPackageFiles(req) {
+		buildArgs = append(buildArgs, f.Path, f.Content)
+	}
+	buildCmd := goContainerCmd(ctx, container+"-build", volume, limits, true, buildScript, buildArgs...)
+	buildCmd.Stdout, buildCmd.Stderr = build, build
+	buildErr := buildCmd.Run()
+	defer removeContainer(container + "-build")
+
+	// The learner's code only runs here, with no cache to tamper with.
+	// Everything the tests print goes through test2json to stdout, leaving
+	// the container's stderr to the cover profiles.
+	run := `exec go ` + strings.Join(goRunArgs(req.GoTest, limits, req.Coverage), " ") + ` 2>&1`
+	if req.Coverage {
+		run = `go ` + strings.Join(goRunArgs(req.GoTest, limits, true), " ") + ` 2>&1; rc=$?; ` + goTestCoverScript(limits) + `; exit $rc`
+	}
+	var err error
+	if buildErr == nil {
+		cmd := goContainerCmd(ctx, container, volume, limits, false, `cd `+goContainerDir+` && `+run)
+		cmd.Stdout, cmd.Stderr = stream, reports
+		err = cmd.Run()
+		defer removeContainer(container)
+	} else {
+		err = buildErr
+	}
+	executionTime := int(time.Since(startTime).Milliseconds())
+
+	exitCode := 0
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		exitCode = exitErr.ExitCode()
+	}
+
+	usage := &ResourceUsage{Termination: "exited"}
+	if buildErr != nil && containerOOMKilled(container+"-build") || buildErr == nil && containerOOMKilled(container) {
+		usage.Termination = "oom"
+	}
+	usage.OutputBytes = build.total + stream.out.total
+
+	var coverage *Coverage
+	var testCoverage map[string]*Coverage
+	stderr := reports.buf.Bytes()
+	if req.Coverage {
+		coverage, testCoverage, stderr = parseGoCoverage(stderr)
+	}
+
+	results := parseGoTestEvents(stream.events)
+	for i := range results {
+		results[i].Coverage = testCoverage[results[i].Name]
+	}
+	resp := ExecuteResponse{
+		Success:       exitCode == 0 && err == nil,
+		Stdout:        stream.out.String(),
+		Stderr:        build.String() + string(stderr),
+		ExitCode:      exitCode,
+		ExecutionTime: executionTime,
+		TestResults:   results,
+		Limits:        &limits,
+		Usage:         usage,
+		Coverage:      coverage,
+	}
+	switch {
+	case ctx.Err() == context.DeadlineExceeded:
+		usage.Termination = "timeout"
+		resp.Success, resp.ExitCode, resp.Error = false, 124, "Timeout"
+	case overflowed.Load():
+		usage.Termination = "output-limit"
+		resp.Success, resp.Error = false, "Output limit exceeded"
+	case buildErr != nil:
+		resp.Success, resp.Error = false, "Build failed"
+	case err != nil && exitCode == 0:
+		log.Printf("❌ Failed to run go test: %v", err)
+		resp.Error = "Failed to run go test"
+	}
+	return resp
+}
+
+// goTestCoverScript prints the run's cover profile to stderr, then reruns
+// each top-level test alone and prints its profile after its name. Output
+// from the reruns is dropped; the first run already reported it.
+func goTestCoverScript(limits ExecutionLimits) string {
+	timeout := "-test.timeout=" + strconv.Itoa(limits.TimeoutSec) + "s"
+	return `if [ -f ` + goCoverProfile + ` ]; then ` +
+		`printf '\n` + coveragePrefix + `\n' >&2; cat ` + goCoverProfile + ` >&2; ` +
+		`for t in $(./` + goTestBinary + ` -test.list '^Test'); do ` +
+		`rm -f ` + goCoverProfile + `; ` +
+		`./` + goTestBinary + ` -test.run "^$t\$" -test.count=1 ` + timeout + ` -test.coverprofile=` + goCoverProfile + ` >/dev/null 2>&1; ` +
+		`if [ -f ` + goCoverProfile + ` ]; then printf '\n` + coveragePrefix + `%s\n' "$t" >&2; cat ` + goCoverProfile + ` >&2; fi; ` +
+		`done; fi`
+}
+
+// goContainerCmd runs script in a Go container under the run's limits, with
+// the run's work volume. Only the build gets the shared GOCACHE.
+func goContainerCmd(ctx context.Context, name, volume string, limits ExecutionLimits, withCache bool, script string, args ...string) *exec.Cmd {
+	dockerArgs := []string{"run",
+		"--name", name,
+		"--network", "none",
+		"--memory", fmt.Sprintf("%dm", limits.MemoryMB),
+		"--memory-swap", fmt.Sprintf("%dm", limits.MemoryMB),
+		"--cpus", strconv.FormatFloat(limits.CPUs, 'f', -1, 64),
+		"--pids-limit", strconv.Itoa(limits.Pids),
+		"--security-opt=no-new-privileges",
+		"-v", volume + ":" + goContainerDir,
+		"-e", "GOFLAGS=-mod=mod",
+		"-e", "GOPROXY=off",
+		"-e", "GOTOOLCHAIN=local",
+		"-e", "CGO_ENABLED=1",
+	}
+	if withCache {
+		dockerArgs = append(dockerArgs, "-v", goCacheVolume+":/cache", "-e", "GOCACHE=/cache/build")
+	}
+	dockerArgs = append(dockerArgs, goImage, "sh", "-c", script, "sh")
+	cmd := exec.CommandContext(ctx, "docker", append(dockerArgs, args...)...)
+	cmd.Cancel = func() error {
+		_ = exec.Command("docker", "kill", name).Run()
+		return cmd.Process.Kill()
+	}
+	return cmd
+}
+
+// goTestEvent is one line of `go test -json`
+type goTestEvent struct {
+	Action  string  `json:"Action"`
+	Test    string  `json:"Test"`
+	Output  string  `json:"Output"`
+	Elapsed float64 `json:"Elapsed"`
+}
+
+// goTestStream decodes `go test -json` as it arrives. The output limit
+// applies to the test output itself rather than the JSON around it.
+type goTestStream struct {
+	partial []byte
+	out     *limitedBuffer
+	build   bytes.Buffer
+	events  []goTestEvent
+}
+
+func (s *goTestStream) Write(p []byte) (int, error) {
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := s.partial[:i]
+		s.partial = s.partial[i+1:]
+
+		var ev goTestEvent
+		if err := json.Unmarshal(line, &ev); err != nil {
+			s.out.Write(append(line, '\n'))
+			continue
+		}
+		if ev.Action == "build-output" {
+			if s.build.Len() < s.out.limit {
+				s.build.WriteString(ev.Output)
+			}
+			continue
+		}
+		s.out.Write([]byte(ev.Output))
+		if s.out.truncated {
+			ev.Output = ""
+		}
+		s.events = append(s.events, ev)
+	}
+}
+
+// parseGoTestEvents folds the event stream into one result per test. With
+// -count above one a test passes only if every run did.
+func parseGoTestEvents(events []goTestEvent) []TestResult {
+	type testRun struct {
+		result  *TestResult
+		started int
+		ended   int
+		output  strings.Builder
+	}
+	var order []string
+	tests := map[string]*testRun{}
+	var pkgOutput strings.Builder
+
+	for _, ev := range events {
+		if ev.Test == "" {
+			pkgOutput.WriteString(ev.Output)
+			continue
+		}
+		t, ok := tests[ev.Test]
+		if !ok {
+			t = &testRun{result: &TestResult{Name: ev.Test, Passed: true}}
+			tests[ev.Test] = t
+			order = append(order, ev.Test)
+		}
+		switch ev.Action {
+		case "run":
+			t.started++
+		case "pass", "skip":
+			t.ended++
+			t.result.ElapsedMS += int(ev.Elapsed * 1000)
+		case "fail":
+			t.ended++
+			t.result.Passed = false
+			t.result.ElapsedMS += int(ev.Elapsed * 1000)
+		case "output":
+			t.output.WriteString(ev.Output)
+		}
+	}
+
+	results := make([]TestResult, 0, len(order)+1)
+	for _, name := range order {
+		t := tests[name]
+		output := t.output.String()
+		t.result.Output = output
+		t.result.Errors = parseGoTestErrors(output)
+		// A test that never finished was cut off by -timeout
+		if t.ended < t.started {
+			t.result.Passed = false
+			if !hasErrorKind(t.result.Errors, "timeout") {
+				t.result.Errors = append(t.result.Errors, TestError{Kind: "timeout", Message: "test did not finish"})
+			}
+		}
+		results = append(results, *t.result)
+	}
+
+	// Leaks are found after every test has finished, and races outside a
+	// test can't be pinned on one
+	if errs := parseGoTestErrors(pkgOutput.String()); len(errs) > 0 {
+		results = append(results, TestResult{
+			Name:   goPackageResult,
+			Output: pkgOutput.String(),
+			Errors: errs,
+		})
+	}
+	return results
+}
+
+func hasErrorKind(errs []TestError, kind string) bool {
+	for _, e := range errs {
+		if e.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// parseGoTestErrors finds races, leaks, panics, timeouts and failed
+// assertions in a test's output
+func parseGoTestErrors(output string) []TestError {
+	var errs []TestError
+	lines := strings.Split(output, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == goRaceBanner:
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end]) != goRaceDivider {
+				end++
+			}
+			errs = append(errs, parseRaceReport(lines[i+1:end]))
+			i = end
+		case strings.HasPrefix(trimmed, goLeakPrefix):
+			end := i + 1
+			for end < len(lines) && strings.TrimSpace(lines[end]) != goLeakEnd {
+				end++
+			}
+			errs = append(errs, parseLeakReport(trimmed, lines[i+1:end]))
+			i = end
+		case strings.HasPrefix(trimmed, "panic: test timed out"):
+			errs = append(errs, TestError{Kind: "timeout", Message: strings.TrimPrefix(trimmed, "panic: ")})
+			i = len(lines)
+		case strings.HasPrefix(trimmed, "panic: "):
+			e := TestError{Kind: "panic", Message: goPanicSuffix.ReplaceAllString(strings.TrimPrefix(trimmed, "panic: "), "")}
+			// The stack follows a blank line, under the goroutine header
+			for j := i + 1; j < len(lines); j++ {
+				if goStackHeader.MatchString(lines[j]) {
+					if loc, ok := firstSnippetFrame(lines[j:]); ok {
+						e.Locations = []ErrorLocation{loc}
+					}
+					break
+				}
+			}
+			errs = append(errs, e)
+			i = len(lines)
+		default:
+			// testing.go reports races and the like, which are parsed above
+			if m := goAssertion.FindStringSubmatch(line); m != nil && m[1] != "testing.go" {
+				n, _ := strconv.Atoi(m[2])
+				errs = append(errs, TestError{
+					Kind:      "failure",
+					Message:   m[3],
+					Locations: []ErrorLocation{{File: m[1], Line: n}},
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// parseRaceReport turns the race detector's report into the conflicting
+// accesses and where their goroutines started
+func parseRaceReport(lines []string) TestError {
+	e := TestError{Kind: "race", Message: "data race"}
+	for i, line := range lines {
+		var loc ErrorLocation
+		if m := goRaceAccess.FindStringSubmatch(line); m != nil {
+			loc.Op = strings.ToLower(m[1])
+			loc.Goroutine, _ = strconv.Atoi(m[2])
+		} else if m := goRaceCreated.FindStringSubmatch(line); m != nil {
+			loc.Op = "goroutine created"
+			loc.Goroutine, _ = strconv.Atoi(m[1])
+		} else {
+			continue
+		}
+		if frame, ok := firstSnippetFrame(lines[i+1:]); ok {
+			loc.Function, loc.File, loc.Line = frame.Function, frame.File, frame.Line
+		}
+		e.Locations = append(e.Locations, loc)
+	}
+
+	var accesses []string
+	for _, loc := range e.Locations {
+		if loc.Op != "goroutine created" && loc.File != "" {
+			accesses = append(accesses, fmt.Sprintf("%s at %s:%d", loc.Op, loc.File, loc.Line))
+		}
+	}
+	if len(accesses) > 0 {
+		e.Message = "data race: " + strings.Join(accesses, " conflicts with ")
+	}
+	return e
+}
+
+// parseLeakReport lists the goroutines still running after the tests, by
+// what they are blocked on and where
+func parseLeakReport(header string, lines []string) TestError {
+	count, _ := strconv.Atoi(strings.TrimPrefix(header, goLeakPrefix))
+	e := TestError{Kind: "leak", Message: fmt.Sprintf("%d goroutine(s) still running after the tests finished", count)}
+
+	for i, line := range lines {
+		m := goStackHeader.FindStringSubmatch(line)
+		if m == nil || m[2] == "running" {
+			// The running goroutine is the leak check itself
+			continue
+		}
+		end := i + 1
+		for end < len(lines) && strings.TrimSpace(lines[end]) != "" {
+			end++
+		}
+		loc, ok := firstSnippetFrame(lines[i+1 : end])
+		if !ok {
+			continue
+		}
+		loc.Op = m[2]
+		loc.Goroutine, _ = strconv.Atoi(m[1])
+		e.Locations = append(e.Locations, loc)
+	}
+	return e
+}
+
+// goSnippetFile gives the path in the package of a frame's file, if it is
+// one the learner can see. Frames outside the workspace are the runtime or
+// the testing package.
+func goSnippetFile(file string) (string, bool) {
+	rel, ok := strings.CutPrefix(file, goContainerDir+"/")
+	if !ok {
+		return "", false
+	}
+	return rel, rel != goLeakCheckFile
+}
+
+// firstSnippetFrame finds the first stack frame in the snippet's own files,
+// reading function/file line pairs until the stack ends
+func firstSnippetFrame(lines []string) (ErrorLocation, bool) {
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			break
+		}
+		m := goFrameFile.FindStringSubmatch(lines[i])
+		if m == nil {
+			continue
+		}
+		if file, ok := goSnippetFile(m[1]); ok {
+			n, _ := strconv.Atoi(m[2])
+			function := strings.TrimSpace(lines[i-1])
+			if at := strings.LastIndex(function, "("); at > 0 {
+				function = function[:at]
+			}
+			return ErrorLocation{Function: function, File: file, Line: n}, true
+		}
+	}
+	return ErrorLocation{}, false
+}
+
+func getEnvString(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
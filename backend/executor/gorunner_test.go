@@ -0,0 +1,206 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGoTestStream(t *testing.T) {
+	input := `{"Action":"build-output","Output":"# snippet\n"}
+{"Action":"run","Test":"TestA"}
+{"Action":"output","Test":"TestA","Output":"=== RUN   TestA\n"}
+not json
+{"Action":"pass","Test":"TestA","Elapsed":0.25}
+`
+	s := &goTestStream{out: &limitedBuffer{limit: 1 << 10}}
+	// Lines arrive split across writes
+	for i := 0; i < len(input); i += 7 {
+		s.Write([]byte(input[i:min(i+7, len(input))]))
+	}
+
+	if got := s.build.String(); got != "# snippet\n" {
+		t.Fatalf("expected the build output apart, got %q", got)
+	}
+	if got := s.out.String(); got != "=== RUN   TestA\nnot json\n" {
+		t.Fatalf("expected the test output and the stray line, got %q", got)
+	}
+	if len(s.events) != 3 || s.events[2].Action != "pass" || s.events[2].Elapsed != 0.25 {
+		t.Fatalf("unexpected events %+v", s.events)
+	}
+}
+
+func TestParseGoTestEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []goTestEvent
+		want   []TestResult
+	}{
+		{
+			name: "pass and fail",
+			events: []goTestEvent{
+				{Action: "run", Test: "TestA"},
+				{Action: "pass", Test: "TestA", Elapsed: 0.5},
+				{Action: "run", Test: "TestB"},
+				{Action: "output", Test: "TestB", Output: "    snippet_test.go:9: got 1, want 2\n"},
+				{Action: "fail", Test: "TestB", Elapsed: 0.01},
+			},
+			want: []TestResult{
+				{Name: "TestA", Passed: true, ElapsedMS: 500},
+				{Name: "TestB", Output: "    snippet_test.go:9: got 1, want 2\n", ElapsedMS: 10, Errors: []TestError{
+					{Kind: "failure", Message: "got 1, want 2", Locations: []ErrorLocation{{File: "snippet_test.go", Line: 9}}},
+				}},
+			},
+		},
+		{
+			name: "every run of a repeated test must pass",
+			events: []goTestEvent{
+				{Action: "run", Test: "TestA"},
+				{Action: "pass", Test: "TestA", Elapsed: 0.1},
+				{Action: "run", Test: "TestA"},
+				{Action: "fail", Test: "TestA", Elapsed: 0.1},
+			},
+			want: []TestResult{{Name: "TestA", ElapsedMS: 200}},
+		},
+		{
+			name: "skipped test passes",
+			events: []goTestEvent{
+				{Action: "run", Test: "TestA"},
+				{Action: "skip", Test: "TestA"},
+			},
+			want: []TestResult{{Name: "TestA", Passed: true}},
+		},
+		{
+			name: "unfinished test timed out",
+			events: []goTestEvent{
+				{Action: "run", Test: "TestA"},
+				{Action: "output", Test: "TestA", Output: "=== RUN   TestA\n"},
+			},
+			want: []TestResult{{Name: "TestA", Output: "=== RUN   TestA\n", Errors: []TestError{
+				{Kind: "timeout", Message: "test did not finish"},
+			}}},
+		},
+		{
+			name: "leak outside any test",
+			events: []goTestEvent{
+				{Action: "run", Test: "TestA"},
+				{Action: "pass", Test: "TestA"},
+				{Action: "output", Output: "\n" + goLeakPrefix + "1\n" + goLeakEnd + "\n"},
+				{Action: "fail"},
+			},
+			want: []TestResult{
+				{Name: "TestA", Passed: true},
+				{Name: goPackageResult, Output: "\n" + goLeakPrefix + "1\n" + goLeakEnd + "\n", Errors: []TestError{
+					{Kind: "leak", Message: "1 goroutine(s) still running after the tests finished"},
+				}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseGoTestEvents(tt.events); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected\n%+v\ngot\n%+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseGoTestErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		output string
+		want   []TestError
+	}{
+		{
+			name:   "no errors",
+			output: "=== RUN   TestA\n--- PASS: TestA (0.00s)\n",
+		},
+		{
+			name:   "assertion",
+			output: "=== RUN   TestA\n    snippet_test.go:12: expected 3, got 4\n--- FAIL: TestA (0.00s)\n",
+			want: []TestError{
+				{Kind: "failure", Message: "expected 3, got 4", Locations: []ErrorLocation{{File: "snippet_test.go", Line: 12}}},
+			},
+		},
+		{
+			name: "panic",
+			output: `--- FAIL: TestGet (0.00s)
+panic: runtime error: index out of range [3] with length 3 [recovered, repanicked]
+
+goroutine 6 [running]:
+testing.tRunner.func1.2({0x6c9000, 0xb2cbab280d8})
+	/usr/local/go/src/testing/testing.go:2123 +0x232
+panic({0x6c9000?, 0xb2cbab280d8?})
+	/usr/local/go/src/runtime/panic.go:859 +0x125
+snippet.Get(...)
+	/work/snippet.go:4
+snippet.TestGet(0xb2cbabb0248?)
+	/work/snippet_test.go:6 +0xa
+`,
+			want: []TestError{
+				{Kind: "panic", Message: "runtime error: index out of range [3] with length 3", Locations: []ErrorLocation{{Function: "snippet.Get", File: "snippet.go", Line: 4}}},
+			},
+		},
+		{
+			name:   "timeout",
+			output: "panic: test timed out after 2s\n\trunning tests:\n\t\tTestA (2s)\n",
+			want:   []TestError{{Kind: "timeout", Message: "test timed out after 2s"}},
+		},
+		{
+			name: "race",
+			output: `==================
+WARNING: DATA RACE
+Write at 0x00c000012345 by goroutine 8:
+  snippet.(*Counter).Inc()
+      /work/snippet.go:10 +0x44
+  snippet.TestCounter.func1()
+      /work/snippet_test.go:15 +0x30
+
+Previous read at 0x00c000012345 by goroutine 7:
+  snippet.(*Counter).Inc()
+      /work/snippet.go:9 +0x30
+
+Goroutine 8 (running) created at:
+  snippet.TestCounter()
+      /work/snippet_test.go:13 +0x50
+==================
+    testing.go:1490: race detected during execution of test
+`,
+			want: []TestError{{
+				Kind:    "race",
+				Message: "data race: write at snippet.go:10 conflicts with previous read at snippet.go:9",
+				Locations: []ErrorLocation{
+					{Op: "write", Goroutine: 8, Function: "snippet.(*Counter).Inc", File: "snippet.go", Line: 10},
+					{Op: "previous read", Goroutine: 7, Function: "snippet.(*Counter).Inc", File: "snippet.go", Line: 9},
+					{Op: "goroutine created", Goroutine: 8, Function: "snippet.TestCounter", File: "snippet_test.go", Line: 13},
+				},
+			}},
+		},
+		{
+			name: "leak",
+			output: "\n" + goLeakPrefix + `1
+goroutine 1 [running]:
+snippet.TestMain(0xc000104000)
+	/work/` + goLeakCheckFile + `:20 +0x65
+
+goroutine 7 [chan receive]:
+snippet.worker(0xc000020120)
+	/work/snippet.go:5 +0x25
+created by snippet.Start in goroutine 6
+	/work/snippet.go:12 +0x4f
+` + goLeakEnd + "\n",
+			want: []TestError{{
+				Kind:      "leak",
+				Message:   "1 goroutine(s) still running after the tests finished",
+				Locations: []ErrorLocation{{Op: "chan receive", Goroutine: 7, Function: "snippet.worker", File: "snippet.go", Line: 5}},
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseGoTestErrors(strings.ReplaceAll(tt.output, "\r\n", "\n")); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected\n%+v\ngot\n%+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import "encoding/json"
+
+// A harness is trusted Python the API sends along with the code, for checks
+// that run next to the code but must not be left to it, such as timing it.
+// The runner loads the harness before the code, in a namespace of its own,
+// so it can take what it needs before the code could patch it. Once the code
+// has run, the harness's run() is called with the code's globals and what it
+// returns is reported; a run that dies part way reports nothing.
+const harnessEnv = "SNIPPET_HARNESS"
+
+// harnessEnvVar passes the harness to the sandbox
+func harnessEnvVar(harness string) string {
+	return harnessEnv + "=" + harness
+}
+
+// parseHarness returns what the harness reported, if the run got that far
+func parseHarness(reports map[string]json.RawMessage) json.RawMessage {
+	return reports["harness"]
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"bytes"
+	"os/exec"
+	"testing"
+)
+
+// runHarness runs code under the Python runner with a harness, returning
+// what the harness reported
+func runHarness(t *testing.T, code, harness string) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+	cmd := exec.Command("python3", "-I", "-c", pythonRunner, code, "null", "null")
+	cmd.Env = []string{harnessEnvVar(harness)}
+	var stdout, stderr, reports bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	runWithReportPipe(cmd, &reports)
+	parsed, _ := parseReports(reports.Bytes())
+	return string(parseHarness(parsed)), stdout.String() + stderr.String()
+}
+
+func TestHarness(t *testing.T) {
+	harness := "import time\n__clock = time.perf_counter\ndef run(g):\n    return {\"value\": g[\"f\"](), \"clock\": __clock is not time.perf_counter}\n"
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"plain code", "def f():\n    return 42\n", `{"value":42,"clock":false}`},
+		{
+			"code patching what the harness and reports use",
+			"import json, time\n" +
+				"time.perf_counter = lambda: 0\n" +
+				"json.dumps = lambda *a, **k: '{\"value\":0}'\n" +
+				"json.JSONEncoder.encode = lambda *a, **k: '{\"value\":0}'\n" +
+				"print('harness {\"value\":0}')\n" +
+				"def f():\n    return 42\n",
+			`{"value":42,"clock":true}`,
+		},
+		{"code that exits part way", "def f():\n    raise SystemExit(0)\n", ""},
+		{"code that fails", "def f():\n    return 1 / 0\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, output := runHarness(t, tt.code, harness)
+			if got != tt.want {
+				t.Fatalf("expected the harness to report %q, got %q\n%s", tt.want, got, output)
+			}
+		})
+	}
+}
@@ -1,23 +1,83 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/exec"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// truncationMarker is appended to output cut off at the limit
+const truncationMarker = "\n...[output truncated]"
+
+// pythonImage runs Python code and SQL queries under Docker
+const pythonImage = "python:3.11-alpine"
+
+var (
+	maxRequestBytes = int64(getEnvInt("MAX_REQUEST_BYTES", 1<<20))
+
+	// defaultLimits fill in whatever a request leaves unset, and no request
+	// may exceed maxLimits
+	defaultLimits = ExecutionLimits{
+		TimeoutSec:  10,
+		MemoryMB:    128,
+		CPUs:        0.5,
+		OutputBytes: 64 << 10,
+		Pids:        64,
+	}
+	maxLimits = ExecutionLimits{
+		TimeoutSec:  getEnvInt("MAX_TIMEOUT_SEC", 30),
+		MemoryMB:    getEnvInt("MAX_MEMORY_MB", 1024),
+		CPUs:        2,
+		OutputBytes: getEnvInt("MAX_OUTPUT_BYTES", 1<<20),
+		Pids:        getEnvInt("MAX_PIDS", 256),
+	}
+)
+
+type ExecutionLimits struct {
+	TimeoutSec  int     `json:"timeout_sec,omitempty"`
+	MemoryMB    int     `json:"memory_mb,omitempty"`
+	CPUs        float64 `json:"cpus,omitempty"`
+	OutputBytes int     `json:"output_bytes,omitempty"`
+	Pids        int     `json:"pids,omitempty"`
+}
+
 type ExecuteRequest struct {
-	Code       string   `json:"code" binding:"required"`
-	Language   string   `json:"language" binding:"required"`
-	TestCases  []string `json:"test_cases"`
-	TimeoutSec int      `json:"timeout_sec"`
+	Code       string           `json:"code" binding:"required"`
+	Language   string           `json:"language" binding:"required"`
+	TestCases  []string         `json:"test_cases"`
+	TimeoutSec int              `json:"timeout_sec"`
+	Limits     *ExecutionLimits `json:"limits"`
+	// Policy is empty for the language's policy, or "trusted" to skip it
+	Policy string        `json:"policy"`
+	Trace  *TraceOptions `json:"trace"`
+	// TestCode is a test file run against the code: a _test.go file for
+	// Go, or a pytest file when TestFormat is "pytest"
+	TestCode   string         `json:"test_code"`
+	TestFormat string         `json:"test_format"`
+	GoTest     *GoTestOptions `json:"go_test"`
+	// Files are written into the workspace the code runs in
+	Files []WorkspaceFile `json:"files"`
+	// SQL sets up the database for language "sql", where Code is a query
+	SQL *SQLOptions `json:"sql"`
+	// Stdin is streamed to the code, for snippets that are programs
+	Stdin string `json:"stdin"`
+	// Coverage asks which lines of the code ran, for Python code and Go
+	// tests
+	Coverage bool `json:"coverage"`
+	// Harness is trusted Python run alongside Python code; see harnessEnv
+	Harness string `json:"harness"`
 }
 
 type ExecuteResponse struct {
@@ -28,6 +88,19 @@ type ExecuteResponse struct {
 	ExecutionTime int          `json:"execution_time_ms"`
 	Error         string       `json:"error,omitempty"`
 	TestResults   []TestResult `json:"test_results,omitempty"`
+	// Limits are the limits that were enforced for this run
+	Limits *ExecutionLimits `json:"limits,omitempty"`
+	Usage  *ResourceUsage   `json:"usage,omitempty"`
+	// PolicyViolations are set when the code was refused without running
+	PolicyViolations []PolicyViolation `json:"policy_violations,omitempty"`
+	Trace            *Trace            `json:"trace,omitempty"`
+	// ResultSet is what a SQL query returned
+	ResultSet *ResultSet `json:"result_set,omitempty"`
+	Coverage  *Coverage  `json:"coverage,omitempty"`
+	// Harness is what the request's harness reported
+	Harness json.RawMessage `json:"harness,omitempty"`
+	// RunnerVersion is the executor's runnerVersion
+	RunnerVersion string `json:"runner_version,omitempty"`
 }
 
 type TestResult struct {
@@ -35,6 +108,13 @@ type TestResult struct {
 	Expected string `json:"expected"`
 	Actual   string `json:"actual"`
 	Passed   bool   `json:"passed"`
+	// Name, Output and Errors are set for tests run by a test framework
+	Name      string      `json:"name,omitempty"`
+	Output    string      `json:"output,omitempty"`
+	ElapsedMS int         `json:"elapsed_ms,omitempty"`
+	Errors    []TestError `json:"errors,omitempty"`
+	// Coverage is the lines a Go test ran when rerun on its own
+	Coverage *Coverage `json:"coverage,omitempty"`
 }
 
 func main() {
@@ -44,7 +124,8 @@ func main() {
 	r.GET("/health", healthHandler)
 	r.HEAD("/health", healthHandler)
 
-	r.POST("/execute", handleExecute)
+	r.POST("/execute", requireSignature(), handleExecute)
+	r.POST("/diagnostics", requireSignature(), handleDiagnostics)
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -59,16 +140,28 @@ func main() {
 
 func healthHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"service": "executor",
-		"status":  "healthy",
+		"service":        "executor",
+		"status":         "healthy",
+		"runner_version": runnerVersion,
 	})
 }
 
 func handleExecute(c *gin.Context) {
 	log.Println("📥 Received execution request")
 
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRequestBytes)
+
 	var req ExecuteRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			log.Printf("❌ Request body exceeds %d bytes", maxRequestBytes)
+			c.JSON(http.StatusRequestEntityTooLarge, ExecuteResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Request body exceeds %d bytes", maxRequestBytes),
+			})
+			return
+		}
 		log.Printf("❌ Failed to bind JSON: %v", err)
 		c.JSON(http.StatusBadRequest, ExecuteResponse{
 			Success: false,
@@ -76,87 +169,289 @@ func handleExecute(c *gin.Context) {
 		})
 		return
 	}
-
-	log.Printf("🐍 Executing Python code (length: %d bytes)", len(req.Code))
-
-	// Set default timeout
-	if req.TimeoutSec == 0 {
-		req.TimeoutSec = 10
+	if err := validateWorkspace(req.Files); err != nil {
+		log.Printf("❌ Invalid workspace: %v", err)
+		c.JSON(http.StatusBadRequest, ExecuteResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request: %v", err),
+		})
+		return
 	}
 
-	// Validate language
-	if req.Language != "python" {
+	var result ExecuteResponse
+	switch req.Language {
+	case "python":
+		log.Printf("🐍 Executing Python code (length: %d bytes)", len(req.Code))
+		result = executePython(c.Request.Context(), req)
+	case "sql":
+		// SQL runs in the Python sandbox, under a trusted sqlite3 runner
+		log.Printf("🗄️ Executing SQL query (length: %d bytes)", len(req.Code))
+		result = executePython(c.Request.Context(), req)
+	case "go":
+		log.Printf("🐹 Running Go tests (length: %d bytes)", len(req.Code)+len(req.TestCode))
+		result = executeGo(c.Request.Context(), req)
+	default:
 		c.JSON(http.StatusBadRequest, ExecuteResponse{
 			Success: false,
-			Error:   "Only Python is supported",
+			Error:   "Only Python, Go and SQL are supported",
 		})
 		return
 	}
-
-	result := executePython(req)
+	if c.Request.Context().Err() != nil {
+		log.Println("⚠️  Client disconnected, execution cancelled")
+		return
+	}
 	log.Printf("✅ Execution complete: success=%v, exitCode=%d, stderr_len=%d", result.Success, result.ExitCode, len(result.Stderr))
+	result.RunnerVersion = runnerVersion
 	c.JSON(http.StatusOK, result)
 }
 
-func executePython(req ExecuteRequest) ExecuteResponse {
+func executePython(parent context.Context, req ExecuteRequest) ExecuteResponse {
 	startTime := time.Now()
+	limits := resolveLimits(req)
+	policy := policyArg(policyFor(req))
+	// argv[3] carries the trace options, or the tests for pytest
+	image, runner, extra := pythonImage, pythonRunner, traceArg(resolveTrace(req.Trace, limits))
+	switch {
+	case req.Language == "sql":
+		// argv[3] carries the schema, seed and query bounds
+		runner, extra = sqlRunner, sqlArg(resolveSQL(req.SQL, limits))
+	case req.TestFormat == "pytest":
+		image, runner, extra = pytestImage, pytestRunner, req.TestCode
+	}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(req.TimeoutSec)*time.Second)
+	// Create context with timeout; output overflow or the API hanging up
+	// cancels it early
+	ctx, cancel := context.WithTimeout(parent, time.Duration(limits.TimeoutSec)*time.Second)
 	defer cancel()
 
+	// Capture output, keeping only the first OutputBytes of each stream
+	var overflowed atomic.Bool
+	onOverflow := func() {
+		overflowed.Store(true)
+		cancel()
+	}
+	stdout := &limitedBuffer{limit: limits.OutputBytes, onOverflow: onOverflow}
+	stderr := &limitedBuffer{limit: limits.OutputBytes, onOverflow: onOverflow}
+	// The sandbox's reports don't count as output
+	reports := &limitedBuffer{limit: maxReportBytes}
+
 	// Try Docker first (if available), fallback to direct execution
 	useDocker := isDockerAvailable()
 
 	var cmd *exec.Cmd
+	var container string
 	if useDocker {
-		log.Println("🐳 Using Docker for isolated execution")
-		// Use docker run to execute Python code in isolated container
-		cmd = exec.CommandContext(ctx, "docker", "run", "--rm",
+		log.Printf("🐳 Using Docker for isolated execution (limits: %+v)", limits)
+		container = containerName()
+		// The container is removed after inspecting it for an OOM kill. The
+		// code runs under usageWrapper, which reports its resource usage and
+		// writes the workspace from the environment. -i passes Stdin through.
+		// The code's output comes framed on stdout and the reports on stderr.
+		cmd = exec.CommandContext(ctx, "docker", "run", "-i",
+			"--name", container,
 			"--network", "none",
-			"--memory", "128m",
-			"--cpus", "0.5",
+			"--memory", fmt.Sprintf("%dm", limits.MemoryMB),
+			"--memory-swap", fmt.Sprintf("%dm", limits.MemoryMB),
+			"--cpus", strconv.FormatFloat(limits.CPUs, 'f', -1, 64),
+			"--pids-limit", strconv.Itoa(limits.Pids),
 			"--security-opt=no-new-privileges",
-			"python:3.11-alpine",
-			"python", "-c", req.Code,
+			"-e", workspaceEnv,
+			"-e", coverageEnv,
+			"-e", harnessEnv,
+			"-e", reportFDEnv+"=2",
+			image,
+			"python", "-c", usageWrapper, req.Code, policy, extra, runner,
 		)
+		cmd.Env = append(os.Environ(), workspaceEnvVar(req.Files), coverageEnvVar(req.Coverage), harnessEnvVar(req.Harness))
+		// Killing the docker client alone would leave the container running
+		cmd.Cancel = func() error {
+			_ = exec.Command("docker", "kill", container).Run()
+			return cmd.Process.Kill()
+		}
+		defer removeContainer(container)
+		cmd.Stdout = &frameWriter{stdout: stdout, stderr: stderr}
+		cmd.Stderr = reports
 	} else {
 		log.Println("🐍 Using direct Python execution (Docker not available)")
-		// Fallback to direct Python execution
-		cmd = exec.CommandContext(ctx, "python3", "-c", req.Code)
-	}
+		// Fallback to direct Python execution; only time and output are enforced.
+		// One interpreter checks the policy and then runs the code.
+		dir, err := writeWorkspace(pythonWorkspace, req.Files)
+		if err != nil {
+			log.Printf("❌ Failed to prepare Python workspace: %v", err)
+			return ExecuteResponse{Success: false, Error: "Failed to prepare workspace", Limits: &limits}
+		}
+		defer os.RemoveAll(dir)
 
-	// Capture output
-	var stdout, stderr strings.Builder
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+		cmd = exec.CommandContext(ctx, "python3", "-c", pythonPolicyCheck+runner, req.Code, policy, extra)
+		cmd.Dir = dir
+		cmd.Env = append(os.Environ(), workspaceEnvVar(req.Files), coverageEnvVar(req.Coverage), harnessEnvVar(req.Harness))
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		limits.MemoryMB, limits.CPUs, limits.Pids = 0, 0, 0
+	}
+	cmd.Stdin = strings.NewReader(req.Stdin)
 
 	// Run the command
-	err := cmd.Run()
+	var err error
+	if useDocker {
+		err = cmd.Run()
+	} else {
+		err = runWithReportPipe(cmd, reports)
+	}
 	executionTime := int(time.Since(startTime).Milliseconds())
 
 	exitCode := 0
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		} else if ctx.Err() == context.DeadlineExceeded {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		exitCode = exitErr.ExitCode()
+	}
+
+	// Anything on the channel that isn't a report, such as an error from
+	// docker itself, belongs with stderr
+	parsed, other := parseReports(reports.buf.Bytes())
+	stderrText := stderr.String() + string(other)
+
+	var usage *ResourceUsage
+	if useDocker {
+		usage = parseUsage(parsed)
+		if usage == nil {
+			usage = &ResourceUsage{Termination: "exited"}
+		}
+		if containerOOMKilled(container) {
+			usage.Termination = "oom"
+		}
+	} else {
+		usage = processUsage(cmd.ProcessState)
+	}
+	usage.OutputBytes = stdout.total + stderr.total
+	coverage := parseCoverage(parsed)
+	traced := parseTrace(parsed)
+	var results []TestResult
+	if req.TestFormat == "pytest" {
+		results = parseJUnit(parsed)
+	}
+	var resultSet *ResultSet
+	if req.Language == "sql" {
+		if exitCode == 0 {
+			resultSet = parseResultSet(stdout.String())
+		} else if exitCode == sqlTimeoutExitCode {
+			usage.Termination = "timeout"
+		}
+	}
+
+	if exitCode == policyExitCode {
+		if violations := parsePolicyViolations(parsed); violations != nil {
+			log.Printf("🚫 Code refused by policy: %d violation(s)", len(violations))
+			usage.Termination = "policy"
 			return ExecuteResponse{
-				Success:       false,
-				Stderr:        "Execution timeout exceeded",
-				ExitCode:      124,
-				ExecutionTime: executionTime,
-				Error:         "Timeout",
+				Success:          false,
+				Stderr:           policyMessage(violations),
+				ExitCode:         exitCode,
+				ExecutionTime:    executionTime,
+				Error:            "Policy violation",
+				Limits:           &limits,
+				Usage:            usage,
+				PolicyViolations: violations,
 			}
 		}
 	}
 
+	// A process killed at the deadline also reports an ExitError
+	if ctx.Err() == context.DeadlineExceeded {
+		usage.Termination = "timeout"
+		return ExecuteResponse{
+			Success:       false,
+			Stdout:        stdout.String(),
+			Stderr:        "Execution timeout exceeded",
+			ExitCode:      124,
+			ExecutionTime: executionTime,
+			Error:         "Timeout",
+			Limits:        &limits,
+			Usage:         usage,
+		}
+	}
+	// The kill that stops a runaway print also loses the container's usage
+	// report, so the overflow is all there is to go on
+	if overflowed.Load() {
+		usage.Termination = "output-limit"
+		return ExecuteResponse{
+			Success:       false,
+			Stdout:        stdout.String(),
+			Stderr:        stderrText,
+			ExitCode:      exitCode,
+			ExecutionTime: executionTime,
+			Error:         "Output limit exceeded",
+			Limits:        &limits,
+			Usage:         usage,
+		}
+	}
+
 	return ExecuteResponse{
 		Success:       exitCode == 0,
 		Stdout:        stdout.String(),
-		Stderr:        stderr.String(),
+		Stderr:        stderrText,
 		ExitCode:      exitCode,
 		ExecutionTime: executionTime,
+		Limits:        &limits,
+		Usage:         usage,
+		Trace:         traced,
+		TestResults:   results,
+		ResultSet:     resultSet,
+		Coverage:      coverage,
+		Harness:       parseHarness(parsed),
+	}
+}
+
+// resolveTrace caps the trace budgets, keeping a trace within half the
+// output limit
+func resolveTrace(opts *TraceOptions, limits ExecutionLimits) *TraceOptions {
+	if opts == nil {
+		return nil
+	}
+	resolved := *opts
+	if resolved.MaxSteps <= 0 || resolved.MaxSteps > maxTraceSteps {
+		resolved.MaxSteps = maxTraceSteps
+	}
+	if resolved.MaxBytes <= 0 || resolved.MaxBytes > limits.OutputBytes/2 {
+		resolved.MaxBytes = limits.OutputBytes / 2
+	}
+	if resolved.MaxValueBytes <= 0 || resolved.MaxValueBytes > resolved.MaxBytes {
+		resolved.MaxValueBytes = min(200, resolved.MaxBytes)
 	}
+	return &resolved
+}
+
+// resolveLimits fills unset limits from the defaults and clamps them to the
+// maximums. Requests without limits fall back to their TimeoutSec.
+func resolveLimits(req ExecuteRequest) ExecutionLimits {
+	limits := defaultLimits
+	if req.TimeoutSec > 0 {
+		limits.TimeoutSec = req.TimeoutSec
+	}
+	if r := req.Limits; r != nil {
+		if r.TimeoutSec > 0 {
+			limits.TimeoutSec = r.TimeoutSec
+		}
+		if r.MemoryMB > 0 {
+			limits.MemoryMB = r.MemoryMB
+		}
+		if r.CPUs > 0 {
+			limits.CPUs = r.CPUs
+		}
+		if r.OutputBytes > 0 {
+			limits.OutputBytes = r.OutputBytes
+		}
+		if r.Pids > 0 {
+			limits.Pids = r.Pids
+		}
+	}
+
+	limits.TimeoutSec = min(limits.TimeoutSec, maxLimits.TimeoutSec)
+	limits.MemoryMB = min(limits.MemoryMB, maxLimits.MemoryMB)
+	limits.CPUs = min(limits.CPUs, maxLimits.CPUs)
+	limits.OutputBytes = min(limits.OutputBytes, maxLimits.OutputBytes)
+	limits.Pids = min(limits.Pids, maxLimits.Pids)
+	return limits
 }
 
 // isDockerAvailable checks if Docker daemon is available
@@ -165,3 +460,43 @@ func isDockerAvailable() bool {
 	err := cmd.Run()
 	return err == nil
 }
+
+// limitedBuffer keeps the first limit bytes written to it and drops the
+// rest, so a runaway print can't exhaust memory. It also counts every byte.
+type limitedBuffer struct {
+	buf        bytes.Buffer
+	limit      int
+	total      int
+	truncated  bool
+	onOverflow func()
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.total += len(p)
+
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		if !b.truncated && b.onOverflow != nil {
+			b.onOverflow()
+		}
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	if b.truncated {
+		return b.buf.String() + truncationMarker
+	}
+	return b.buf.String()
+}
+
+func getEnvInt(key string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+const (
+	// policyExitCode is how the sandbox says it refused to run the code; it
+	// must match pythonPolicyCheck
+	policyExitCode = 126
+	// trustedPolicy is sent by the API for its own scripts, which only parse
+	// the code they carry
+	trustedPolicy = "trusted"
+)
+
+// Policy restricts what code may import and reference. It is checked
+// statically, by an AST pass in the sandbox, before the code runs.
+type Policy struct {
+	// Modules are the top-level modules that may be imported
+	Modules []string `json:"modules"`
+	// Builtins are names that may not be referenced at all, which also
+	// stops aliasing them
+	Builtins []string `json:"builtins"`
+	// Attributes may not be accessed; they are the usual ways out of a
+	// restricted namespace
+	Attributes []string `json:"attributes"`
+}
+
+// PolicyViolation is one disallowed construct. Kind is "import", "builtin"
+// or "attribute". File is set for violations in a workspace file rather
+// than the code.
+type PolicyViolation struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+var policies = map[string]*Policy{
+	"python": {
+		Modules: getEnvList("PYTHON_ALLOWED_MODULES", []string{
+			"collections", "heapq", "bisect", "math", "itertools", "functools",
+			"operator", "string", "re", "typing", "dataclasses", "enum", "copy",
+			"random", "time", "json", "statistics", "fractions", "decimal", "array",
+		}),
+		Builtins: []string{
+			"__import__", "eval", "exec", "compile", "open", "globals", "locals",
+			"vars", "getattr", "setattr", "delattr", "breakpoint", "memoryview",
+			"__builtins__", "__loader__", "__spec__",
+		},
+		Attributes: []string{
+			"__class__", "__bases__", "__base__", "__mro__", "__subclasses__",
+			"__globals__", "__builtins__", "__code__", "__closure__", "__dict__",
+			"__getattribute__", "__import__", "__loader__", "__spec__", "__reduce__",
+			"__reduce_ex__", "f_globals", "f_locals", "f_back", "f_builtins",
+			"gi_frame", "cr_frame", "tb_frame",
+		},
+	},
+}
+
+// pythonPolicyCheck walks the AST of argv[1], and of the workspace's Python
+// files, against the policy in argv[2] and exits with policyExitCode, with a
+// report, if anything is disallowed. The workspace's own modules
+// may be imported, and relative imports are allowed inside its packages.
+// Code that doesn't parse is left to the interpreter to report.
+//
+// Allowed modules re-export others, as typing.sys or enum.bltns do, so the
+// names under which an allowed module holds one that leads back to sys, os
+// or the builtins are refused as attributes and as imported names, whatever
+// they are reached through.
+const pythonPolicyCheck = pythonReport + `import ast as __ast, json as __json, os as __os, sys as __sys
+def __module_escapes(allowed):
+    import importlib, types
+    roots = {"sys", "builtins", "os", "posix", "nt", "io", "_io", "importlib", "_imp", "gc", "inspect",
+             "subprocess", "_thread", "threading", "marshal", "ctypes", "_ctypes", "signal", "socket",
+             "shutil", "types", "pathlib", "tempfile", "runpy", "pickle", "_pickle", "code", "pdb"}
+    ok = lambda name: name.split(".")[0] in allowed
+    edges, queue, seen = [], [], set()
+    for name in allowed:
+        try:
+            queue.append(importlib.import_module(name))
+        except ImportError:
+            pass
+    while queue:
+        m = queue.pop()
+        if m.__name__ in seen:
+            continue
+        seen.add(m.__name__)
+        for attr, v in list(vars(m).items()):
+            if isinstance(v, types.ModuleType):
+                edges.append((m.__name__, attr, v.__name__))
+                queue.append(v)
+    # A module is unsafe if it is a root or holds one, and isn't allowed
+    unsafe = {n for n in seen if n.split(".")[0] in roots and not ok(n)}
+    grew = True
+    while grew:
+        grew = False
+        for src, _, dst in edges:
+            if dst in unsafe and src not in unsafe and not ok(src):
+                unsafe.add(src)
+                grew = True
+    # Only the names reachable without passing an unsafe module matter
+    names, queue, seen = set(), list(allowed), set()
+    while queue:
+        at = queue.pop()
+        if at in seen:
+            continue
+        seen.add(at)
+        for src, attr, dst in edges:
+            if src != at:
+                continue
+            if dst in unsafe:
+                names.add(attr)
+            else:
+                queue.append(dst)
+    return names
+def __policy_violations(src, policy, local=(), package=False, escapes=()):
+    try:
+        tree = __ast.parse(src)
+    except SyntaxError:
+        return []
+    modules, builtins, attrs = set(policy["modules"]) | set(local), set(policy["builtins"]), set(policy["attributes"])
+    found, imported = [], set()
+    def add(kind, name, node, msg):
+        found.append({"kind": kind, "name": name, "line": getattr(node, "lineno", 0),
+                      "column": getattr(node, "col_offset", -1) + 1, "message": msg})
+    for n in __ast.walk(tree):
+        if isinstance(n, __ast.Import):
+            for a in n.names:
+                top = a.name.split(".")[0]
+                if top not in local:
+                    imported.add(a.asname or top)
+                if top not in modules:
+                    add("import", a.name, n, "import of '%s' is not allowed" % a.name)
+        elif isinstance(n, __ast.ImportFrom):
+            name = "." * n.level + (n.module or "")
+            own = bool(n.level) or name.split(".")[0] in local
+            if (n.level and not package) or (not own and name.split(".")[0] not in modules):
+                add("import", name, n, "import from '%s' is not allowed" % name)
+            for a in n.names:
+                if own:
+                    continue
+                imported.add(a.asname or a.name)
+                if a.name in escapes:
+                    add("import", a.name, n, "import of module '%s' from '%s' is not allowed" % (a.name, name))
+                elif a.name.startswith("_"):
+                    add("import", a.name, n, "import of private name '%s' is not allowed" % a.name)
+        elif isinstance(n, __ast.Name) and n.id in builtins:
+            add("builtin", n.id, n, "use of '%s' is not allowed" % n.id)
+    # Private module attributes such as random._os lead back to the host
+    for n in __ast.walk(tree):
+        if isinstance(n, __ast.Attribute):
+            if n.attr in attrs:
+                add("attribute", n.attr, n, "access to attribute '%s' is not allowed" % n.attr)
+            elif n.attr in escapes:
+                add("attribute", n.attr, n, "access to module attribute '%s' is not allowed" % n.attr)
+            elif n.attr.startswith("_") and isinstance(n.value, __ast.Name) and n.value.id in imported:
+                add("attribute", n.attr, n, "access to private attribute '%s.%s' is not allowed" % (n.value.id, n.attr))
+    found.sort(key=lambda v: v["line"])
+    return found
+if __sys.argv[2] != "null":
+    __policy = __json.loads(__sys.argv[2])
+    __files = [f for f in __json.loads(__os.environ.get("` + workspaceEnv + `") or "[]") if f["path"].endswith(".py")]
+    __local = {f["path"].split("/")[0].removesuffix(".py") for f in __files}
+    __escapes = __module_escapes(__policy["modules"])
+    __found = __policy_violations(__sys.argv[1], __policy, __local, escapes=__escapes)
+    for __f in __files:
+        for __v in __policy_violations(__f["content"], __policy, __local, "/" in __f["path"], __escapes):
+            __v["file"] = __f["path"]
+            __found.append(__v)
+    if __found:
+        __report("policy", __found)
+        __sys.exit(126)
+`
+
+// policyFor picks the policy for a request; trusted API scripts get none
+func policyFor(req ExecuteRequest) *Policy {
+	if req.Policy == trustedPolicy {
+		return nil
+	}
+	return policies[req.Language]
+}
+
+// policyArg is how the policy is handed to the sandbox
+func policyArg(policy *Policy) string {
+	data, err := json.Marshal(policy)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode policy: %v", err)
+		return "null"
+	}
+	return string(data)
+}
+
+// parsePolicyViolations reads the sandbox's report of refused code
+func parsePolicyViolations(reports map[string]json.RawMessage) []PolicyViolation {
+	var violations []PolicyViolation
+	if !decodeReport(reports, "policy", &violations) || len(violations) == 0 {
+		return nil
+	}
+	return violations
+}
+
+// policyMessage is one readable line per violation, which stands in for
+// the refused code's stderr
+func policyMessage(violations []PolicyViolation) string {
+	var msg strings.Builder
+	for _, v := range violations {
+		if v.File != "" {
+			fmt.Fprintf(&msg, "PolicyViolation: %s (%s, line %d)\n", v.Message, v.File, v.Line)
+			continue
+		}
+		fmt.Fprintf(&msg, "PolicyViolation: %s (line %d)\n", v.Message, v.Line)
+	}
+	return msg.String()
+}
+
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
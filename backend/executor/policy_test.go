@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+// checkPolicy runs the sandbox's policy check on code alone, returning the
+// violations it reports
+func checkPolicy(t *testing.T, code string) []PolicyViolation {
+	t.Helper()
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+	cmd := exec.Command("python3", "-I", "-c", pythonPolicyCheck, code, policyArg(policies["python"]))
+	var stderr, reports bytes.Buffer
+	cmd.Stderr = &stderr
+	err := runWithReportPipe(cmd, &reports)
+	var exitErr *exec.ExitError
+	if err == nil {
+		return nil
+	}
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != policyExitCode {
+		t.Fatalf("policy check failed: %v\n%s", err, stderr.String())
+	}
+	parsed, _ := parseReports(reports.Bytes())
+	violations := parsePolicyViolations(parsed)
+	if len(violations) == 0 {
+		t.Fatalf("policy check refused the code without a report:\n%s", stderr.String())
+	}
+	return violations
+}
+
+func TestPythonPolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		refused string
+	}{
+		{"plain code", "def f(nums):\n    return sorted(nums)\n", ""},
+		{"allowed module", "import collections\nq = collections.deque()\n", ""},
+		{"allowed submodule", "import collections\nok = isinstance([], collections.abc.Iterable)\n", ""},
+		{"user attribute named like a module", "class G:\n    def __init__(self):\n        self.path = []\n", ""},
+		{"disallowed import", "import os\n", "os"},
+		{"typing re-exports sys", "import typing\ntyping.sys.modules['os'].system('id')\n", "sys"},
+		{"dataclasses re-exports sys", "import dataclasses\ndataclasses.sys.modules\n", "sys"},
+		{"enum re-exports sys", "import enum\nenum.sys.modules\n", "sys"},
+		{"enum re-exports builtins", "import enum\nenum.bltns.open('/etc/passwd')\n", "bltns"},
+		{"dataclasses re-exports inspect", "import dataclasses\ndataclasses.inspect.stack()\n", "inspect"},
+		{"aliased module", "import typing\nt = typing\nt.sys.modules\n", "sys"},
+		{"module imported by name", "from typing import sys\n", "sys"},
+		{"private module attribute", "import random\nrandom._os.system('id')\n", "_os"},
+		{"blocked builtin", "eval('1')\n", "eval"},
+		{"blocked attribute", "().__class__.__bases__\n", "__class__"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := checkPolicy(t, tt.code)
+			if tt.refused == "" {
+				if len(violations) > 0 {
+					t.Fatalf("expected the code to be allowed, got %+v", violations)
+				}
+				return
+			}
+			for _, v := range violations {
+				if v.Name == tt.refused {
+					return
+				}
+			}
+			t.Fatalf("expected %q to be refused, got %+v", tt.refused, violations)
+		})
+	}
+}
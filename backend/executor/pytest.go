@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// pytestImage is the sandbox image for pytest snippets; it is the Python
+// image with pytest installed, see Dockerfile.pytest
+var pytestImage = getEnvString("PYTEST_IMAGE", "bugdrill/python-pytest:3.11")
+
+// pytestRunner saves the code in argv[1] as solution.py in the workspace,
+// next to the test file in argv[3], runs pytest on it and reports the JUnit
+// XML. The policy check has already passed on the code by then, and pytest
+// runs without the report channel.
+const pytestRunner = pythonReport + `import os as __os, subprocess as __sp, sys as __sys, tempfile as __tf
+for __name, __src in (("solution.py", __sys.argv[1]), ("test_solution.py", __sys.argv[3])):
+    with open(__name, "w") as __f:
+        __f.write(__src)
+__junit = __os.path.join(__tf.mkdtemp(), "report.xml")
+__rc = __sp.run([__sys.executable, "-m", "pytest", "-q", "-p", "no:cacheprovider",
+                 "--junitxml=" + __junit, "test_solution.py"]).returncode
+if __os.path.exists(__junit):
+    with open(__junit) as __f:
+        __report("junit", __f.read())
+__sys.exit(__rc)
+`
+
+// junitReport reads both a <testsuites> root and a lone <testsuite>
+type junitReport struct {
+	Suites []junitReport `xml:"testsuite"`
+	Cases  []junitCase   `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      float64       `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure"`
+	Error     *junitProblem `xml:"error"`
+	SystemOut string        `xml:"system-out"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// pytestFrame is a traceback line in a workspace file; pytest prints those
+// relative to the workspace and anything else, such as pytest itself, with
+// an absolute path
+var pytestFrame = regexp.MustCompile(`(?m)^(\w[\w./-]*\.py):(\d+):`)
+
+// parseJUnit reads the runner's JUnit report into one result per test case
+func parseJUnit(reports map[string]json.RawMessage) []TestResult {
+	var junit string
+	if !decodeReport(reports, "junit", &junit) {
+		return nil
+	}
+
+	var report junitReport
+	if err := xml.Unmarshal([]byte(junit), &report); err != nil {
+		log.Printf("⚠️ Failed to parse JUnit report: %v", err)
+		return nil
+	}
+	return junitResults(report, nil)
+}
+
+func junitResults(report junitReport, results []TestResult) []TestResult {
+	for _, suite := range report.Suites {
+		results = junitResults(suite, results)
+	}
+	for _, tc := range report.Cases {
+		result := TestResult{
+			Name:      junitTestName(tc),
+			Passed:    tc.Failure == nil && tc.Error == nil,
+			Output:    tc.SystemOut,
+			ElapsedMS: int(tc.Time * 1000),
+		}
+		if tc.Failure != nil {
+			result.Errors = append(result.Errors, junitError(tc.Failure, "failure"))
+			result.Output += tc.Failure.Text
+		}
+		if tc.Error != nil {
+			result.Errors = append(result.Errors, junitError(tc.Error, "error"))
+			result.Output += tc.Error.Text
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// junitTestName is the pytest node name without the module, such as
+// "test_put" or "TestLRU::test_evicts_oldest"
+func junitTestName(tc junitCase) string {
+	if _, class, ok := strings.Cut(tc.Classname, "."); ok {
+		return strings.ReplaceAll(class, ".", "::") + "::" + tc.Name
+	}
+	return tc.Name
+}
+
+// junitError is an assertion "failure", another "exception" raised by the
+// test, or a pytest "error" in collection or a fixture
+func junitError(p *junitProblem, kind string) TestError {
+	// pytest puts the crash line in the message, e.g. "assert 1 == 2" or
+	// "KeyError: 3"
+	message, _, _ := strings.Cut(p.Message, "\n")
+	if kind == "failure" && !strings.HasPrefix(message, "assert") && !strings.HasPrefix(message, "AssertionError") {
+		kind = "exception"
+	}
+	e := TestError{Kind: kind, Message: message}
+
+	seen := map[string]bool{}
+	for _, m := range pytestFrame.FindAllStringSubmatch(p.Text, -1) {
+		if seen[m[0]] {
+			continue
+		}
+		seen[m[0]] = true
+		line, _ := strconv.Atoi(m[2])
+		e.Locations = append(e.Locations, ErrorLocation{File: m[1], Line: line})
+	}
+	return e
+}
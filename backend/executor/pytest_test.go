@@ -0,0 +1,72 @@
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const pytestReport = `<?xml version="1.0" encoding="utf-8"?><testsuites><testsuite name="pytest" errors="0" failures="2" skipped="0" tests="3" time="0.05"><testcase classname="test_solution" name="test_add" time="0.002"><failure message="assert -1 == 3&#10; +  where -1 = add(1, 2)">def test_add():
+&gt;       assert add(1, 2) == 3
+E       assert -1 == 3
+
+test_solution.py:4: AssertionError</failure></testcase><testcase classname="test_solution" name="test_ok" time="0.001"><system-out>hi
+</system-out></testcase><testcase classname="test_solution.TestMore" name="test_key" time="0.001"><failure message="KeyError: 3">    def test_key(self):
+&gt;       lookup({}, 3)
+
+test_solution.py:12:
+_ _ _ _
+
+solution.py:5: in lookup
+    return d[k]
+E   KeyError: 3
+/usr/local/lib/python3.11/site-packages/_pytest/python.py:194: KeyError</failure></testcase></testsuite></testsuites>`
+
+func TestParseJUnit(t *testing.T) {
+	tests := []struct {
+		name   string
+		report string
+		want   []TestResult
+	}{
+		{
+			name:   "pytest report",
+			report: pytestReport,
+			want: []TestResult{
+				{Name: "test_add", ElapsedMS: 2, Output: "def test_add():\n>       assert add(1, 2) == 3\nE       assert -1 == 3\n\ntest_solution.py:4: AssertionError", Errors: []TestError{
+					{Kind: "failure", Message: "assert -1 == 3", Locations: []ErrorLocation{{File: "test_solution.py", Line: 4}}},
+				}},
+				{Name: "test_ok", Passed: true, ElapsedMS: 1, Output: "hi\n"},
+				{Name: "TestMore::test_key", ElapsedMS: 1, Output: "    def test_key(self):\n>       lookup({}, 3)\n\ntest_solution.py:12:\n_ _ _ _\n\nsolution.py:5: in lookup\n    return d[k]\nE   KeyError: 3\n/usr/local/lib/python3.11/site-packages/_pytest/python.py:194: KeyError", Errors: []TestError{
+					{Kind: "exception", Message: "KeyError: 3", Locations: []ErrorLocation{{File: "test_solution.py", Line: 12}, {File: "solution.py", Line: 5}}},
+				}},
+			},
+		},
+		{
+			name: "lone testsuite with a collection error",
+			report: `<testsuite><testcase classname="" name="test_solution" time="0"><error message="collection failure">test_solution.py:1: in &lt;module&gt;
+    from solution import add
+E   ImportError: cannot import name 'add'</error></testcase></testsuite>`,
+			want: []TestResult{
+				{Name: "test_solution", Output: "test_solution.py:1: in <module>\n    from solution import add\nE   ImportError: cannot import name 'add'", Errors: []TestError{
+					{Kind: "error", Message: "collection failure", Locations: []ErrorLocation{{File: "test_solution.py", Line: 1}}},
+				}},
+			},
+		},
+		{
+			name:   "malformed report is dropped",
+			report: "<testsuites><testcase",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, _ := json.Marshal(tt.report)
+			if got := parseJUnit(map[string]json.RawMessage{"junit": report}); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected\n%+v\ngot\n%+v", tt.want, got)
+			}
+		})
+	}
+
+	if got := parseJUnit(map[string]json.RawMessage{}); got != nil {
+		t.Fatalf("expected no results without a report, got %+v", got)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"regexp"
+	"time"
+)
+
+// Machine reports - resource usage, policy violations, traces, coverage and
+// test reports - travel on a channel of their own, apart from the code's
+// output, so the code can neither forge them nor push them past the output
+// limit. Sandbox scripts write to the descriptor in this variable: fd 3 of a
+// direct run, or the container's stderr under Docker, where the wrapper
+// frames the code's own output on stdout.
+const reportFDEnv = "SNIPPET_REPORT_FD"
+
+// reportCloseWait is how long a direct run's report pipe may stay open
+// after the run, held by whatever the code left running
+const reportCloseWait = time.Second
+
+var (
+	// maxReportBytes bounds the reports of one run
+	maxReportBytes = getEnvInt("MAX_REPORT_BYTES", 8<<20)
+	reportKind     = regexp.MustCompile(`^[a-z]+$`)
+)
+
+// pythonReport defines __report, which sandbox scripts write a report with:
+// one line holding its kind and JSON. The encoder is put together from the C
+// parts of json, and everything __report calls is bound when it is defined,
+// so code that later patches the json or os modules can't change a report.
+const pythonReport = `import os as __report_os
+from _json import encode_basestring_ascii as __report_str, make_encoder as __report_encoder
+def __report_default(o):
+    raise TypeError("cannot report %s" % type(o).__name__)
+def __report(kind, data, encode=__report_encoder(None, __report_default, __report_str, None, ":", ",", False, False, True),
+             write=__report_os.write, fd=int(__report_os.environ.get("` + reportFDEnv + `") or 2)):
+    line = (kind + " " + "".join(encode(data, 0)) + "\n").encode()
+    while line:
+        line = line[write(fd, line):]
+`
+
+// Frames on the stdout of a Docker run are a stream byte and a big-endian
+// length, followed by that much of the stream
+const (
+	frameStdout = 1
+	frameStderr = 2
+	frameHeader = 5
+)
+
+// frameWriter splits the framed output of a Docker run into the code's
+// stdout and stderr. A frame cut off by a kill is dropped.
+type frameWriter struct {
+	stdout, stderr io.Writer
+	partial        []byte
+}
+
+func (w *frameWriter) Write(p []byte) (int, error) {
+	w.partial = append(w.partial, p...)
+	for len(w.partial) >= frameHeader {
+		n := int(binary.BigEndian.Uint32(w.partial[1:frameHeader]))
+		if len(w.partial) < frameHeader+n {
+			break
+		}
+		data := w.partial[frameHeader : frameHeader+n]
+		switch w.partial[0] {
+		case frameStdout:
+			w.stdout.Write(data)
+		case frameStderr:
+			w.stderr.Write(data)
+		}
+		w.partial = w.partial[frameHeader+n:]
+	}
+	return len(p), nil
+}
+
+// parseReports reads the report channel into the last report of each kind.
+// Anything else there, such as the wrapper's own traceback, is returned so
+// it can be shown with the code's stderr.
+func parseReports(data []byte) (map[string]json.RawMessage, []byte) {
+	reports := map[string]json.RawMessage{}
+	var other []byte
+	for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+		kind, report, ok := bytes.Cut(bytes.TrimSuffix(line, []byte("\n")), []byte(" "))
+		if ok && reportKind.Match(kind) && json.Valid(report) {
+			reports[string(kind)] = report
+			continue
+		}
+		other = append(other, line...)
+	}
+	return reports, other
+}
+
+// decodeReport decodes the report of a kind into v, reporting whether there
+// was one
+func decodeReport(reports map[string]json.RawMessage, kind string, v interface{}) bool {
+	report, ok := reports[kind]
+	if !ok {
+		return false
+	}
+	if err := json.Unmarshal(report, v); err != nil {
+		log.Printf("⚠️ Failed to parse %s report: %v", kind, err)
+		return false
+	}
+	return true
+}
+
+// runWithReportPipe runs a direct command with a pipe on fd 3 for its
+// reports, copied into reports
+func runWithReportPipe(cmd *exec.Cmd, reports io.Writer) error {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.ExtraFiles = []*os.File{w}
+	cmd.Env = append(cmd.Env, reportFDEnv+"=3")
+
+	copied := make(chan struct{})
+	go func() {
+		io.Copy(reports, r)
+		close(copied)
+	}()
+	err = cmd.Start()
+	w.Close()
+	if err == nil {
+		err = cmd.Wait()
+	}
+
+	select {
+	case <-copied:
+	case <-time.After(reportCloseWait):
+	}
+	r.Close()
+	<-copied
+	return err
+}
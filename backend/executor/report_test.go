@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func frame(stream byte, data string) []byte {
+	header := make([]byte, frameHeader)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[1:], uint32(len(data)))
+	return append(header, data...)
+}
+
+func TestFrameWriter(t *testing.T) {
+	var framed []byte
+	framed = append(framed, frame(frameStdout, "hello ")...)
+	framed = append(framed, frame(frameStderr, "oops\n")...)
+	framed = append(framed, frame(frameStdout, "usage {\"rc\": 0}\n")...)
+	// Cut off by a kill
+	framed = append(framed, frame(frameStdout, "lost")[:frameHeader+2]...)
+
+	tests := []struct {
+		name  string
+		chunk int
+	}{
+		{"whole", len(framed)},
+		{"byte by byte", 1},
+		{"split headers", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			w := &frameWriter{stdout: &stdout, stderr: &stderr}
+			for i := 0; i < len(framed); i += tt.chunk {
+				w.Write(framed[i:min(i+tt.chunk, len(framed))])
+			}
+			// What the code printed stays its output, whatever it looks like
+			if stdout.String() != "hello usage {\"rc\": 0}\n" || stderr.String() != "oops\n" {
+				t.Fatalf("expected the streams apart, got %q and %q", stdout.String(), stderr.String())
+			}
+		})
+	}
+}
+
+func TestParseReports(t *testing.T) {
+	tests := []struct {
+		name      string
+		channel   string
+		want      map[string]json.RawMessage
+		wantOther string
+	}{
+		{
+			name:    "reports",
+			channel: "trace {\"steps\": []}\nusage {\"rc\": 1}\n",
+			want:    map[string]json.RawMessage{"trace": json.RawMessage(`{"steps": []}`), "usage": json.RawMessage(`{"rc": 1}`)},
+		},
+		{
+			name:    "the last report of a kind wins",
+			channel: "usage {\"rc\": 1}\nusage {\"rc\": 2}\n",
+			want:    map[string]json.RawMessage{"usage": json.RawMessage(`{"rc": 2}`)},
+		},
+		{
+			name:      "anything else is kept apart",
+			channel:   "docker: Error response from daemon\nValueError: 3\nusage {\"rc\": 1}\nTraceback",
+			want:      map[string]json.RawMessage{"usage": json.RawMessage(`{"rc": 1}`)},
+			wantOther: "docker: Error response from daemon\nValueError: 3\nTraceback",
+		},
+		{
+			name:      "a report cut short",
+			channel:   "trace {\"steps\": [",
+			want:      map[string]json.RawMessage{},
+			wantOther: "trace {\"steps\": [",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports, other := parseReports([]byte(tt.channel))
+			if !reflect.DeepEqual(reports, tt.want) || string(other) != tt.wantOther {
+				t.Fatalf("expected %s and %q, got %s and %q", tt.want, tt.wantOther, reports, other)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"strings"
+)
+
+const (
+	defaultSQLMaxRows   = 500
+	maxSQLRows          = 10000
+	defaultSQLTimeoutMS = 2000
+	// sqlTimeoutExitCode is how sqlRunner reports an interrupted query, the
+	// same as a run killed at the deadline; it must match sqlRunner
+	sqlTimeoutExitCode = 124
+)
+
+// SQLOptions set up the database a SQL query runs against. Schema and Seed
+// are scripts run on a fresh in-memory SQLite database; MaxRows and
+// TimeoutMS bound the query itself.
+type SQLOptions struct {
+	Schema    string `json:"schema"`
+	Seed      string `json:"seed"`
+	MaxRows   int    `json:"max_rows"`
+	TimeoutMS int    `json:"timeout_ms"`
+}
+
+// ResultSet is what a query returned. Values are JSON numbers, strings or
+// null; blobs are hex strings.
+type ResultSet struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// sqlRunner loads the schema and seed from the options in argv[3] into an
+// in-memory SQLite database and runs the single statement in argv[1],
+// printing its result set as JSON. SQLite's progress handler interrupts a
+// query past its deadline, and the authorizer keeps it from attaching files.
+const sqlRunner = `import json, sqlite3, sys, time
+opts = json.loads(sys.argv[3])
+db = sqlite3.connect(":memory:")
+try:
+    db.executescript(opts["schema"])
+    db.executescript(opts["seed"])
+except sqlite3.Error as e:
+    sys.stderr.write("SetupError: %s\n" % e)
+    sys.exit(2)
+db.set_authorizer(lambda action, *args: sqlite3.SQLITE_DENY if action in (sqlite3.SQLITE_ATTACH, sqlite3.SQLITE_DETACH) else sqlite3.SQLITE_OK)
+deadline = time.monotonic() + opts["timeout_ms"] / 1000
+db.set_progress_handler(lambda: time.monotonic() > deadline, 1000)
+try:
+    cur = db.execute(sys.argv[1])
+    rows = cur.fetchmany(opts["max_rows"] + 1)
+except sqlite3.Error as e:
+    if str(e) == "interrupted":
+        sys.stderr.write("QueryTimeout: query ran longer than %d ms\n" % opts["timeout_ms"])
+        sys.exit(124)
+    sys.stderr.write("%s: %s\n" % (type(e).__name__, e))
+    sys.exit(1)
+if len(rows) > opts["max_rows"]:
+    sys.stderr.write("RowLimitExceeded: query returned more than %d rows\n" % opts["max_rows"])
+    sys.exit(1)
+def value(v):
+    return v.hex() if isinstance(v, bytes) else v
+json.dump({"columns": [d[0] for d in cur.description or []],
+           "rows": [[value(v) for v in row] for row in rows]}, sys.stdout)
+`
+
+// resolveSQL fills in the query bounds; the timeout never outlasts the run
+func resolveSQL(opts *SQLOptions, limits ExecutionLimits) SQLOptions {
+	var resolved SQLOptions
+	if opts != nil {
+		resolved = *opts
+	}
+	if resolved.MaxRows <= 0 {
+		resolved.MaxRows = defaultSQLMaxRows
+	}
+	resolved.MaxRows = min(resolved.MaxRows, maxSQLRows)
+	if resolved.TimeoutMS <= 0 {
+		resolved.TimeoutMS = defaultSQLTimeoutMS
+	}
+	resolved.TimeoutMS = min(resolved.TimeoutMS, limits.TimeoutSec*1000)
+	return resolved
+}
+
+// sqlArg is how the options are handed to the sandbox
+func sqlArg(opts SQLOptions) string {
+	data, err := json.Marshal(opts)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode SQL options: %v", err)
+		return "{}"
+	}
+	return string(data)
+}
+
+// parseResultSet decodes the runner's output. The output limit can cut it
+// short, in which case there is no result set.
+func parseResultSet(stdout string) *ResultSet {
+	var rs ResultSet
+	if err := json.Unmarshal([]byte(strings.TrimSpace(stdout)), &rs); err != nil {
+		return nil
+	}
+	if rs.Rows == nil {
+		rs.Rows = [][]interface{}{}
+	}
+	return &rs
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+)
+
+const maxTraceSteps = 5000
+
+// TraceOptions turn on line tracing of the code's functions. The budgets
+// bound the number of steps, the encoded size of the trace and each value.
+type TraceOptions struct {
+	MaxSteps      int `json:"max_steps"`
+	MaxBytes      int `json:"max_bytes"`
+	MaxValueBytes int `json:"max_value_bytes"`
+}
+
+// TraceStep is one event in a traced function: "call", "line", "return" or
+// "exception". Locals are repr()s; Value is the returned value or raised
+// exception.
+type TraceStep struct {
+	Line     int               `json:"line"`
+	Event    string            `json:"event"`
+	Function string            `json:"function"`
+	Locals   map[string]string `json:"locals"`
+	Value    string            `json:"value,omitempty"`
+}
+
+type Trace struct {
+	Steps []TraceStep `json:"steps"`
+	// Truncated is set when a budget ran out before the code finished
+	Truncated bool `json:"truncated"`
+}
+
+// pythonRunner runs argv[1] and prints errors the way "python -c" would.
+// With trace options in argv[3] it records every event in functions of the
+// code, skipping module level and anything outside the code, and reports
+// the trace. Asked for coverage instead, it reports the lines of the code
+// that ran and those that could have. A harness is loaded before the code
+// and run after it.
+const pythonRunner = pythonReport + `import json as __json, os as __os, sys as __sys
+__g = {"__name__": "__main__", "__builtins__": __builtins__}
+__h = {"__name__": "__harness__", "__builtins__": __builtins__}
+if __os.environ.get("` + harnessEnv + `"):
+    exec(compile(__os.environ["` + harnessEnv + `"], "<harness>", "exec"), __h)
+__opts = __json.loads(__sys.argv[3])
+__steps, __state = [], {"size": 0, "truncated": False}
+__cover, __covered = __os.environ.get("` + coverageEnv + `") == "1", set()
+
+def __repr(v):
+    try:
+        r = repr(v)
+    except Exception:
+        r = "<unrepresentable>"
+    if len(r) > __opts["max_value_bytes"]:
+        r = r[:__opts["max_value_bytes"]] + "..."
+    return r
+
+def __tracer(frame, event, arg):
+    code = frame.f_code
+    if code.co_filename != "<string>" or code.co_name == "<module>" or frame.f_globals is not __g:
+        return None
+    if len(__steps) >= __opts["max_steps"] or __state["size"] >= __opts["max_bytes"]:
+        __state["truncated"] = True
+        __sys.settrace(None)
+        return None
+    step = {"line": frame.f_lineno, "event": event, "function": code.co_name,
+            "locals": {k: __repr(v) for k, v in frame.f_locals.items() if not k.startswith("__")}}
+    if event == "return":
+        step["value"] = __repr(arg)
+    elif event == "exception":
+        step["value"] = __repr(arg[1])
+    __state["size"] += len(__json.dumps(step))
+    __steps.append(step)
+    return __tracer
+
+def __coverer(frame, event, arg):
+    if frame.f_code.co_filename != "<string>":
+        return None
+    if event == "line":
+        __covered.add(frame.f_lineno)
+    return __coverer
+
+def __executable(code):
+    lines = {line for _, _, line in code.co_lines() if line}
+    for c in code.co_consts:
+        if hasattr(c, "co_lines"):
+            lines |= __executable(c)
+    return lines
+
+__rc = 0
+if __opts:
+    __sys.settrace(__tracer)
+elif __cover:
+    __sys.settrace(__coverer)
+try:
+    exec(compile(__sys.argv[1], "<string>", "exec"), __g)
+    if "run" in __h:
+        __report("harness", __h["run"](__g))
+except SystemExit:
+    raise
+except BaseException as __e:
+    import traceback as __tb
+    __tb.print_exception(type(__e), __e, __e.__traceback__.tb_next)
+    __rc = 1
+finally:
+    __sys.settrace(None)
+    if __opts:
+        __report("trace", {"steps": __steps, "truncated": __state["truncated"]})
+    if __cover and not __opts:
+        try:
+            __lines = __executable(compile(__sys.argv[1], "<string>", "exec"))
+        except Exception:
+            __lines = None
+        if __lines is not None:
+            __report("coverage", {"covered": sorted(__covered & __lines), "executable": sorted(__lines)})
+__sys.exit(__rc)
+`
+
+func traceArg(opts *TraceOptions) string {
+	if opts == nil {
+		return "null"
+	}
+	data, err := json.Marshal(opts)
+	if err != nil {
+		log.Printf("⚠️ Failed to encode trace options: %v", err)
+		return "null"
+	}
+	return string(data)
+}
+
+// parseTrace reads the runner's trace report
+func parseTrace(reports map[string]json.RawMessage) *Trace {
+	var trace Trace
+	if !decodeReport(reports, "trace", &trace) {
+		return nil
+	}
+	return &trace
+}
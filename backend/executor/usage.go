@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// usageWrapper runs the code from argv in a child interpreter under the
+// runner passed as argv[4], once it passes the policy check, and reports the
+// child's CPU time, peak RSS and exit status. Inside Docker the rusage of the
+// docker client would say nothing about the container. The child runs in a
+// fresh workspace holding the snippet's files. Its output is framed on
+// stdout, leaving stderr to the reports, which the child writes to a copy of
+// it.
+const usageWrapper = pythonPolicyCheck + `import json, os, resource, selectors, subprocess, sys, tempfile
+ws = tempfile.mkdtemp()
+for f in json.loads(os.environ.pop("` + workspaceEnv + `", "") or "[]"):
+    name = os.path.join(ws, f["path"])
+    os.makedirs(os.path.dirname(name), exist_ok=True)
+    with open(name, "w") as fh:
+        fh.write(f["content"])
+os.chdir(ws)
+out_r, out_w = os.pipe()
+err_r, err_w = os.pipe()
+rep = os.dup(2)
+env = dict(os.environ)
+env["` + reportFDEnv + `"] = str(rep)
+child = subprocess.Popen([sys.executable, "-c", sys.argv[4]] + sys.argv[1:4], stdout=out_w, stderr=err_w, pass_fds=(rep,), env=env)
+for fd in (out_w, err_w, rep):
+    os.close(fd)
+sel = selectors.DefaultSelector()
+sel.register(out_r, selectors.EVENT_READ, 1)
+sel.register(err_r, selectors.EVENT_READ, 2)
+while sel.get_map():
+    for key, _ in sel.select():
+        data = os.read(key.fd, 1 << 16)
+        if not data:
+            sel.unregister(key.fd)
+            continue
+        sys.stdout.buffer.write(bytes([key.data]) + len(data).to_bytes(4, "big") + data)
+        sys.stdout.buffer.flush()
+rc = child.wait()
+u = resource.getrusage(resource.RUSAGE_CHILDREN)
+__report("usage", {"user": u.ru_utime, "sys": u.ru_stime, "maxrss": u.ru_maxrss, "rc": rc})
+sys.exit(rc if rc >= 0 else 128 - rc)
+`
+
+// ResourceUsage is what a run consumed and why it ended. Termination is
+// "exited", "signal", "timeout", "oom" or "output-limit".
+type ResourceUsage struct {
+	CPUUserMS    float64 `json:"cpu_user_ms"`
+	CPUSysMS     float64 `json:"cpu_sys_ms"`
+	PeakMemoryKB int64   `json:"peak_memory_kb"`
+	OutputBytes  int     `json:"output_bytes"`
+	Termination  string  `json:"termination"`
+	Signal       int     `json:"signal,omitempty"`
+}
+
+// parseUsage reads the wrapper's report. It returns nil when the wrapper
+// never got to write it, e.g. because the container was killed.
+func parseUsage(reports map[string]json.RawMessage) *ResourceUsage {
+	var raw struct {
+		User   float64 `json:"user"`
+		Sys    float64 `json:"sys"`
+		MaxRSS int64   `json:"maxrss"`
+		RC     int     `json:"rc"`
+	}
+	if !decodeReport(reports, "usage", &raw) {
+		return nil
+	}
+
+	usage := &ResourceUsage{
+		CPUUserMS:    raw.User * 1000,
+		CPUSysMS:     raw.Sys * 1000,
+		PeakMemoryKB: raw.MaxRSS,
+		Termination:  "exited",
+	}
+	if raw.RC < 0 {
+		usage.Termination = "signal"
+		usage.Signal = -raw.RC
+	}
+	return usage
+}
+
+// processUsage reads rusage of a process run directly on the host
+func processUsage(state *os.ProcessState) *ResourceUsage {
+	usage := &ResourceUsage{Termination: "exited"}
+	if state == nil {
+		return usage
+	}
+
+	if ru, ok := state.SysUsage().(*syscall.Rusage); ok {
+		usage.CPUUserMS = float64(ru.Utime.Nano()) / 1e6
+		usage.CPUSysMS = float64(ru.Stime.Nano()) / 1e6
+		// Linux reports ru_maxrss in kilobytes
+		usage.PeakMemoryKB = ru.Maxrss
+	}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		usage.Termination = "signal"
+		usage.Signal = int(ws.Signal())
+	}
+	return usage
+}
+
+func containerName() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "bugdrill-exec-" + hex.EncodeToString(b)
+}
+
+// containerOOMKilled asks Docker whether the kernel OOM killer fired in the
+// container, which is more reliable than guessing from a SIGKILL
+func containerOOMKilled(name string) bool {
+	out, err := exec.Command("docker", "inspect", "--format", "{{.State.OOMKilled}}", name).Output()
+	if err != nil {
+		return false
+	}
+	return strings.TrimSpace(string(out)) == "true"
+}
+
+func removeContainer(name string) {
+	if err := exec.Command("docker", "rm", "-f", name).Run(); err != nil {
+		log.Printf("⚠️ Failed to remove container %s: %v", name, err)
+	}
+}
+
+func removeVolume(name string) {
+	if err := exec.Command("docker", "volume", "rm", "-f", name).Run(); err != nil {
+		log.Printf("⚠️ Failed to remove volume %s: %v", name, err)
+	}
+}
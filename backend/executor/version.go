@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// runnerVersion identifies how this executor runs code: the images, the
+// sandbox scripts and the policy for Python, pytest, SQLite and Go. It is
+// reported by /health and with every run, and the API keys cached results
+// by it, so changing any of them invalidates results produced before.
+var runnerVersion = computeRunnerVersion()
+
+func computeRunnerVersion() string {
+	h := sha256.New()
+	policy, _ := json.Marshal(policies)
+	probe := ExecutionLimits{TimeoutSec: 1}
+	for _, part := range []string{
+		pythonImage, pytestImage, goImage,
+		usageWrapper, pythonRunner, pytestRunner, sqlRunner, string(policy),
+		goModule, goLeakCheck,
+		strings.Join(goBuildArgs(true), " "),
+		strings.Join(goRunArgs(nil, probe, true), " "),
+		goTestCoverScript(probe),
+	} {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Multi-file snippets run in a workspace: a fresh directory holding the files
+// next to the code, which is the entrypoint. Inside Docker the files travel
+// in this variable, so they stay off the docker command line, and the
+// sandbox writes them out; the direct fallback writes them on the host.
+const workspaceEnv = "SNIPPET_FILES"
+
+// maxWorkspaceFiles bounds how many files one run may write
+const maxWorkspaceFiles = 50
+
+// pythonWorkspace holds the workspaces of direct Python runs
+var pythonWorkspace = filepath.Join(os.TempDir(), "bugdrill-python")
+
+// WorkspaceFile is one file of the tree, at a relative slash-separated path
+type WorkspaceFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+// validateWorkspace refuses paths that would land outside the workspace
+func validateWorkspace(files []WorkspaceFile) error {
+	if len(files) > maxWorkspaceFiles {
+		return fmt.Errorf("at most %d files per run", maxWorkspaceFiles)
+	}
+	seen := map[string]bool{}
+	for _, f := range files {
+		p := f.Path
+		if p == "" || path.IsAbs(p) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") || strings.ContainsAny(p, "\\\x00") {
+			return fmt.Errorf("invalid file path %q", p)
+		}
+		if seen[p] {
+			return fmt.Errorf("duplicate file path %q", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
+// workspaceEnvVar is the files as the sandbox reads them
+func workspaceEnvVar(files []WorkspaceFile) string {
+	if files == nil {
+		files = []WorkspaceFile{}
+	}
+	data, _ := json.Marshal(files)
+	return workspaceEnv + "=" + string(data)
+}
+
+// writeWorkspace writes files into a fresh directory under parent
+func writeWorkspace(parent string, files []WorkspaceFile) (string, error) {
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(parent, "run-")
+	if err != nil {
+		return "", err
+	}
+	for _, f := range files {
+		name := filepath.Join(dir, filepath.FromSlash(f.Path))
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+		if err := os.WriteFile(name, []byte(f.Content), 0o644); err != nil {
+			os.RemoveAll(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
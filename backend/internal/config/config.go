@@ -3,8 +3,11 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/bugdrill/backend/internal/model"
 	"github.com/joho/godotenv"
 )
 
@@ -14,6 +17,7 @@ type Config struct {
 	Redis    RedisConfig
 	JWT      JWTConfig
 	App      AppConfig
+	Executor ExecutorConfig
 }
 
 type ServerConfig struct {
@@ -52,6 +56,32 @@ type AppConfig struct {
 	TrialSnippets  int
 	MaxSnippetSize int
 	CodeTimeoutSec int
+	DraftTTL       time.Duration
+	// ResultCacheTTL keeps results of identical runs; zero disables caching
+	ResultCacheTTL time.Duration
+	// Limits applies to every run unless a snippet overrides it. Snippet
+	// overrides are capped at MaxLimits, and trial users at TrialLimits.
+	Limits      model.ExecutionLimits
+	MaxLimits   model.ExecutionLimits
+	TrialLimits model.ExecutionLimits
+}
+
+type ExecutorConfig struct {
+	// URLs are the executor instances requests are balanced across
+	URLs   []string
+	Secret string
+	// Timeout is allowed on top of a run's own time limit for starting its
+	// sandbox and answering, and GoBuildTimeout for compiling Go; it should
+	// match the executor's GO_BUILD_TIMEOUT_SEC. Retries is how many more
+	// attempts a failed run gets on other instances.
+	Timeout        time.Duration
+	GoBuildTimeout time.Duration
+	Retries        int
+	// An instance failing BreakerThreshold times in a row is skipped for
+	// BreakerCooldown before it is tried again
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	HealthInterval   time.Duration
 }
 
 func Load() (*Config, error) {
@@ -90,9 +120,42 @@ func Load() (*Config, error) {
 			Name:           "bugdrill",
 			TrialSnippets:  5,
 			MaxSnippetSize: 10000, // 10KB
-			CodeTimeoutSec: 3,
+			CodeTimeoutSec: getIntEnv("CODE_TIMEOUT_SEC", 10),
+			DraftTTL:       getDurationEnv("DRAFT_TTL", 7*24*time.Hour),
+			ResultCacheTTL: getDurationEnv("RESULT_CACHE_TTL", 10*time.Minute),
+		},
+		Executor: ExecutorConfig{
+			URLs:             getListEnv("EXECUTOR_URLS", getEnv("EXECUTOR_URL", "http://localhost:8082")),
+			Secret:           getEnv("EXECUTOR_SECRET", ""),
+			Timeout:          getDurationEnv("EXECUTOR_TIMEOUT", 30*time.Second),
+			GoBuildTimeout:   getDurationEnv("EXECUTOR_GO_BUILD_TIMEOUT", 60*time.Second),
+			Retries:          getIntEnv("EXECUTOR_RETRIES", 2),
+			BreakerThreshold: getIntEnv("EXECUTOR_BREAKER_THRESHOLD", 5),
+			BreakerCooldown:  getDurationEnv("EXECUTOR_BREAKER_COOLDOWN", 30*time.Second),
+			HealthInterval:   getDurationEnv("EXECUTOR_HEALTH_INTERVAL", 10*time.Second),
 		},
 	}
+	cfg.App.Limits = model.ExecutionLimits{
+		TimeoutSec:  cfg.App.CodeTimeoutSec,
+		MemoryMB:    getIntEnv("EXEC_MEMORY_MB", 128),
+		CPUs:        getFloatEnv("EXEC_CPUS", 0.5),
+		OutputBytes: getIntEnv("EXEC_OUTPUT_BYTES", 64<<10),
+		Pids:        getIntEnv("EXEC_PIDS", 64),
+	}
+	cfg.App.MaxLimits = model.ExecutionLimits{
+		TimeoutSec:  30,
+		MemoryMB:    1024,
+		CPUs:        2,
+		OutputBytes: 1 << 20,
+		Pids:        256,
+	}
+	cfg.App.TrialLimits = model.ExecutionLimits{
+		TimeoutSec:  getIntEnv("TRIAL_EXEC_TIMEOUT_SEC", cfg.App.CodeTimeoutSec),
+		MemoryMB:    getIntEnv("TRIAL_EXEC_MEMORY_MB", 128),
+		CPUs:        getFloatEnv("TRIAL_EXEC_CPUS", 0.5),
+		OutputBytes: getIntEnv("TRIAL_EXEC_OUTPUT_BYTES", 64<<10),
+		Pids:        getIntEnv("TRIAL_EXEC_PIDS", 64),
+	}
 
 	return cfg, nil
 }
@@ -113,6 +176,17 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getListEnv splits a comma-separated value, dropping empty entries
+func getListEnv(key, defaultValue string) []string {
+	var list []string
+	for _, item := range strings.Split(getEnv(key, defaultValue), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
 func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	if value := os.Getenv(key); value != "" {
 		if d, err := time.ParseDuration(value); err == nil {
@@ -121,3 +195,21 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+func getIntEnv(key string, defaultValue int) int {
+	if value := os.Getenv(key); value != "" {
+		if i, err := strconv.Atoi(value); err == nil {
+			return i
+		}
+	}
+	return defaultValue
+}
+
+func getFloatEnv(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	}
+	return defaultValue
+}
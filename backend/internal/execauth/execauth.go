@@ -0,0 +1,54 @@
+// Package execauth signs the API's requests to the code executor and the
+// executor's responses with the shared EXECUTOR_SECRET. A request signature
+// covers the method, path, timestamp, nonce and a hash of the body; the
+// response is signed over the request nonce and the response body, so the
+// API knows it is talking to the real executor.
+package execauth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+)
+
+const (
+	HeaderTimestamp = "X-Executor-Timestamp"
+	HeaderNonce     = "X-Executor-Nonce"
+	HeaderSignature = "X-Executor-Signature"
+)
+
+// NewNonce returns a random nonce for a request
+func NewNonce() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+func SignRequest(secret []byte, method, path, timestamp, nonce string, body []byte) string {
+	return sign(secret, method+"\n"+path+"\n"+timestamp+"\n"+nonce, body)
+}
+
+func SignResponse(secret []byte, nonce string, body []byte) string {
+	return sign(secret, "response\n"+nonce, body)
+}
+
+// VerifyRequest reports whether signature is the request's signature
+func VerifyRequest(secret []byte, method, path, timestamp, nonce string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignRequest(secret, method, path, timestamp, nonce, body)), []byte(signature))
+}
+
+// VerifyResponse reports whether signature is the response's signature
+func VerifyResponse(secret []byte, nonce string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(SignResponse(secret, nonce, body)), []byte(signature))
+}
+
+func sign(secret []byte, prefix string, body []byte) string {
+	sum := sha256.Sum256(body)
+	mac := hmac.New(sha256.New, secret)
+	io.WriteString(mac, prefix+"\n"+hex.EncodeToString(sum[:]))
+	return hex.EncodeToString(mac.Sum(nil))
+}
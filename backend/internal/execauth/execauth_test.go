@@ -0,0 +1,55 @@
+package execauth
+
+import "testing"
+
+func TestVerifyRequest(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"code":"print(1)"}`)
+	signature := SignRequest(secret, "POST", "/execute", "1700000000", "abc", body)
+
+	tests := []struct {
+		name      string
+		secret    []byte
+		method    string
+		path      string
+		timestamp string
+		nonce     string
+		body      []byte
+		want      bool
+	}{
+		{"matching", secret, "POST", "/execute", "1700000000", "abc", body, true},
+		{"other secret", []byte("other"), "POST", "/execute", "1700000000", "abc", body, false},
+		{"other method", secret, "GET", "/execute", "1700000000", "abc", body, false},
+		{"other path", secret, "POST", "/trace", "1700000000", "abc", body, false},
+		{"other timestamp", secret, "POST", "/execute", "1700000001", "abc", body, false},
+		{"other nonce", secret, "POST", "/execute", "1700000000", "abd", body, false},
+		{"tampered body", secret, "POST", "/execute", "1700000000", "abc", []byte(`{"code":"print(2)"}`), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyRequest(tt.secret, tt.method, tt.path, tt.timestamp, tt.nonce, tt.body, signature); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"success":true}`)
+	signature := SignResponse(secret, "abc", body)
+
+	if !VerifyResponse(secret, "abc", body, signature) {
+		t.Fatalf("expected the response to verify")
+	}
+	if VerifyResponse(secret, "abd", body, signature) {
+		t.Fatalf("expected a response to another request to fail")
+	}
+	if VerifyResponse(secret, "abc", []byte(`{"success":false}`), signature) {
+		t.Fatalf("expected a tampered response to fail")
+	}
+	// A request signature never passes for a response
+	if VerifyResponse(secret, "abc", body, SignRequest(secret, "POST", "/execute", "1700000000", "abc", body)) {
+		t.Fatalf("expected a request signature to fail as a response signature")
+	}
+}
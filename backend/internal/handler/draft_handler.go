@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bugdrill/backend/internal/model"
+	"github.com/bugdrill/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type DraftHandler struct {
+	snippetService *service.SnippetService
+	draftService   *service.DraftService
+}
+
+func NewDraftHandler(snippetService *service.SnippetService, draftService *service.DraftService) *DraftHandler {
+	return &DraftHandler{
+		snippetService: snippetService,
+		draftService:   draftService,
+	}
+}
+
+func (h *DraftHandler) GetDraft(c *gin.Context) {
+	draft, err := h.draftService.GetDraft(c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch draft"})
+		return
+	}
+	if draft == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Draft not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, draft)
+}
+
+func (h *DraftHandler) SaveDraft(c *gin.Context) {
+	snippetID := c.Param("id")
+
+	var req model.SaveDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+
+	draft, err := h.draftService.SaveDraft(c.GetString("user_id"), snippet, &req)
+	switch {
+	case errors.Is(err, service.ErrDraftTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidFileEdit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrDraftConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "draft": draft})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save draft"})
+	default:
+		c.JSON(http.StatusOK, draft)
+	}
+}
+
+func (h *DraftHandler) DeleteDraft(c *gin.Context) {
+	if err := h.draftService.DeleteDraft(c.GetString("user_id"), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete draft"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bugdrill/backend/internal/model"
+	"github.com/bugdrill/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type MutationHandler struct {
+	snippetService  *service.SnippetService
+	mutationService *service.MutationService
+}
+
+func NewMutationHandler(snippetService *service.SnippetService, mutationService *service.MutationService) *MutationHandler {
+	return &MutationHandler{
+		snippetService:  snippetService,
+		mutationService: mutationService,
+	}
+}
+
+func (h *MutationHandler) GenerateMutants(c *gin.Context) {
+	snippetID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	snippet, err := h.snippetService.GetSnippetAdmin(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+
+	report, err := h.mutationService.GenerateMutants(c.Request.Context(), snippet, userID)
+	if errors.Is(err, service.ErrNoTestCases) || errors.Is(err, service.ErrInvalidSnippet) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		executionFailed(c, err, "Failed to generate mutants")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ReviewDraft approves or rejects a pending_review draft, such as a mutant
+func (h *MutationHandler) ReviewDraft(c *gin.Context) {
+	snippetID := c.Param("id")
+
+	var req model.ReviewDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if _, err := h.snippetService.GetSnippetAdmin(snippetID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+
+	snippet, err := h.mutationService.ReviewDraft(snippetID, req.Decision == "approve")
+	if errors.Is(err, service.ErrNotPendingReview) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to review snippet"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snippet)
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/bugdrill/backend/internal/model"
+	"github.com/bugdrill/backend/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type QuizHandler struct {
+	snippetService *service.SnippetService
+	quizService    *service.QuizService
+}
+
+func NewQuizHandler(snippetService *service.SnippetService, quizService *service.QuizService) *QuizHandler {
+	return &QuizHandler{
+		snippetService: snippetService,
+		quizService:    quizService,
+	}
+}
+
+func (h *QuizHandler) ListBugTypes(c *gin.Context) {
+	bugTypes, err := h.quizService.GetBugTypes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch bug types"})
+		return
+	}
+
+	c.JSON(http.StatusOK, bugTypes)
+}
+
+func (h *QuizHandler) GetQuiz(c *gin.Context) {
+	snippetID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+
+	quiz, err := h.quizService.GetQuiz(userID, snippet)
+	if errors.Is(err, service.ErrNoBugQuiz) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build quiz"})
+		return
+	}
+
+	c.JSON(http.StatusOK, quiz)
+}
+
+func (h *QuizHandler) AnswerQuiz(c *gin.Context) {
+	snippetID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req model.QuizAnswerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+
+	result, err := h.quizService.AnswerQuiz(userID, snippet, req.BugTypeID)
+	switch {
+	case errors.Is(err, service.ErrNoBugQuiz):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidChoice):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrQuizAnswered):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "result": result})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record answer"})
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -1,6 +1,10 @@
 package handler
 
 import (
+	"context"
+	"errors"
+	"log"
+	"math"
 	"net/http"
 	"strconv"
 
@@ -11,11 +15,21 @@ import (
 )
 
 type SnippetHandler struct {
-	snippetService *service.SnippetService
+	snippetService  *service.SnippetService
+	progressService *service.ProgressService
+	draftService    *service.DraftService
 }
 
-func NewSnippetHandler(snippetService *service.SnippetService) *SnippetHandler {
-	return &SnippetHandler{snippetService: snippetService}
+func NewSnippetHandler(
+	snippetService *service.SnippetService,
+	progressService *service.ProgressService,
+	draftService *service.DraftService,
+) *SnippetHandler {
+	return &SnippetHandler{
+		snippetService:  snippetService,
+		progressService: progressService,
+		draftService:    draftService,
+	}
 }
 
 func (h *SnippetHandler) ListPatterns(c *gin.Context) {
@@ -36,14 +50,28 @@ func (h *SnippetHandler) ListSnippetsByPattern(c *gin.Context) {
 	}
 
 	difficulty := c.Query("difficulty")
+	bugType := c.Query("bug_type")
 
-	snippets, err := h.snippetService.GetSnippetsByPattern(patternID, difficulty)
+	snippets, err := h.snippetService.GetSnippetsByPattern(patternID, difficulty, bugType)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch snippets"})
 		return
 	}
 
-	c.JSON(http.StatusOK, snippets)
+	ids := make([]string, len(snippets))
+	for i, snippet := range snippets {
+		ids[i] = snippet.ID
+	}
+	states, err := h.progressService.GetSolveStates(c.GetString("user_id"), ids)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress"})
+		return
+	}
+	if bugType != "" {
+		snippets = service.VisibleBugTypes(snippets, viewer(c), states)
+	}
+
+	c.JSON(http.StatusOK, service.ProjectSnippets(snippets, viewer(c), states))
 }
 
 func (h *SnippetHandler) GetSnippet(c *gin.Context) {
@@ -55,12 +83,68 @@ func (h *SnippetHandler) GetSnippet(c *gin.Context) {
 		return
 	}
 
-	// Don't send the correct code to the client
-	snippet.CorrectCode = ""
+	state, err := h.progressService.GetSolveState(c.GetString("user_id"), snippetID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, service.ProjectSnippet(snippet, viewer(c), state))
+}
+
+// GetSnippetAdmin returns the full snippet, answer and specs included
+func (h *SnippetHandler) GetSnippetAdmin(c *gin.Context) {
+	snippet, err := h.snippetService.GetSnippetAdmin(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
 
 	c.JSON(http.StatusOK, snippet)
 }
 
+// GetCoverage runs the correct code through the tests and shows the lines
+// they never reach
+func (h *SnippetHandler) GetCoverage(c *gin.Context) {
+	snippet, err := h.snippetService.GetSnippetAdmin(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+
+	report, err := h.snippetService.CorrectCodeCoverage(c.Request.Context(), snippet)
+	if errors.Is(err, service.ErrCoverageUnsupported) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		executionFailed(c, err, "Coverage run failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GiveUp records a forfeit and reveals the answer
+func (h *SnippetHandler) GiveUp(c *gin.Context) {
+	snippetID := c.Param("id")
+
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+
+	state, err := h.progressService.GiveUp(c.GetString("user_id"), snippet)
+	if err != nil {
+		log.Printf("❌ Failed to record give up: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to give up"})
+		return
+	}
+
+	c.JSON(http.StatusOK, service.ProjectSnippet(snippet, viewer(c), state))
+}
+
 func (h *SnippetHandler) ExecuteCode(c *gin.Context) {
 	snippetID := c.Param("id")
 
@@ -69,10 +153,24 @@ func (h *SnippetHandler) ExecuteCode(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.IsTrial = c.GetBool("is_trial")
+
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+	if !h.checkLocalized(c, snippet, req.Mode) {
+		return
+	}
 
-	result, err := h.snippetService.ExecuteCode(snippetID, req.Code, req.Language)
+	result, err := h.snippetService.ExecuteCode(c.Request.Context(), snippetID, &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Code execution failed"})
+		executionFailed(c, err, "Code execution failed")
+		return
+	}
+	if err := h.projectEdit(c, snippetID, result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check progress"})
 		return
 	}
 
@@ -88,19 +186,109 @@ func (h *SnippetHandler) SubmitSolution(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.IsTrial = c.GetBool("is_trial")
 
-	result, err := h.snippetService.ExecuteCode(snippetID, req.Code, req.Language)
+	snippet, err := h.snippetService.GetSnippet(snippetID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Code execution failed"})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
 		return
 	}
 
-	// TODO: Store submission in database
-	_ = userID // Will be used when storing attempts
+	if !h.checkLocalized(c, snippet, req.Mode) {
+		return
+	}
+
+	result, err := h.snippetService.ExecuteCode(c.Request.Context(), snippetID, &req)
+	if err != nil {
+		executionFailed(c, err, "Code execution failed")
+		return
+	}
+
+	if err := h.progressService.RecordAttempt(userID, snippet, req.Code, result); err != nil {
+		log.Printf("❌ Failed to record attempt: %v", err)
+	}
+
+	// The draft has served its purpose once the snippet is solved
+	if result.IsCorrect {
+		if err := h.draftService.DeleteDraft(userID, snippetID); err != nil {
+			log.Printf("❌ Failed to clear draft: %v", err)
+		}
+	}
+	if err := h.projectEdit(c, snippetID, result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check progress"})
+		return
+	}
 
 	c.JSON(http.StatusOK, result)
 }
 
+// checkLocalized refuses to run code in localize mode, which snippets that
+// require localization always use, until the learner has guessed the buggy
+// lines. Every endpoint that runs the learner's code checks it. It reports
+// whether the request may go ahead.
+func (h *SnippetHandler) checkLocalized(c *gin.Context, snippet *model.Snippet, mode string) bool {
+	if !snippet.RequireLocalization && mode != "localize" {
+		return true
+	}
+	localization, err := h.progressService.GetLocalization(c.GetString("user_id"), snippet.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check localization"})
+		return false
+	}
+	if localization == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": service.ErrLocalizationRequired.Error()})
+		return false
+	}
+	return true
+}
+
+// projectEdit hides the bug region of the result's edit unless the viewer
+// may already see the solution
+func (h *SnippetHandler) projectEdit(c *gin.Context, snippetID string, result *model.ExecuteCodeResponse) error {
+	if result.Edit == nil {
+		return nil
+	}
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		return err
+	}
+	state, err := h.progressService.GetSolveState(c.GetString("user_id"), snippetID)
+	if err != nil {
+		return err
+	}
+	service.ProjectEdit(result.Edit, service.CanSeeSolution(snippet, viewer(c), state))
+	return nil
+}
+
+func (h *SnippetHandler) LocalizeBug(c *gin.Context) {
+	snippetID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req model.LocalizeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+
+	result, err := h.progressService.Localize(userID, snippet, &req)
+	switch {
+	case errors.Is(err, service.ErrInvalidLineRange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrAlreadyLocalized):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "localization": result})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to localize bug"})
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
 func (h *SnippetHandler) GetHint(c *gin.Context) {
 	snippetID := c.Param("id")
 	tier := c.Param("tier")
@@ -128,12 +316,15 @@ func (h *SnippetHandler) GetHint(c *gin.Context) {
 }
 
 func (h *SnippetHandler) GetUserProgress(c *gin.Context) {
-	// TODO: Implement progress tracking
-	c.JSON(http.StatusOK, gin.H{
-		"total_snippets_attempted": 0,
-		"total_snippets_solved":    0,
-		"patterns":                 []interface{}{},
-	})
+	userID := c.GetString("user_id")
+
+	progress, err := h.progressService.GetUserProgress(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch progress"})
+		return
+	}
+
+	c.JSON(http.StatusOK, progress)
 }
 
 func (h *SnippetHandler) CreateSnippet(c *gin.Context) {
@@ -149,6 +340,10 @@ func (h *SnippetHandler) CreateSnippet(c *gin.Context) {
 	snippet.Status = "active"
 
 	if err := h.snippetService.CreateSnippet(&snippet); err != nil {
+		if errors.Is(err, service.ErrInvalidSnippet) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create snippet"})
 		return
 	}
@@ -160,3 +355,172 @@ func (h *SnippetHandler) UpdateSnippet(c *gin.Context) {
 	// TODO: Implement update logic
 	c.JSON(http.StatusNotImplemented, gin.H{"error": "Not implemented yet"})
 }
+
+// RevealSolution returns the reference solution, a diff from the buggy code
+// and the explanation once the snippet is solved or forfeited
+func (h *SnippetHandler) RevealSolution(c *gin.Context) {
+	snippetID := c.Param("id")
+
+	// The body is optional; without one the reveal is not a forfeit
+	var req model.RevealRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+
+	reveal, err := h.progressService.Reveal(c.GetString("user_id"), snippet, req.Forfeit)
+	if errors.Is(err, service.ErrRevealLocked) {
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Failed to reveal solution: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reveal solution"})
+		return
+	}
+
+	c.JSON(http.StatusOK, reveal)
+}
+
+// TraceCode records a step-by-step timeline of one test case, optionally
+// against the reference solution
+func (h *SnippetHandler) TraceCode(c *gin.Context) {
+	snippetID := c.Param("id")
+
+	var req model.TraceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.IsTrial = c.GetBool("is_trial")
+
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+	if !h.checkLocalized(c, snippet, req.Mode) {
+		return
+	}
+
+	// The reference timeline would give the answer away
+	showReference := false
+	if req.CompareReference {
+		state, err := h.progressService.GetSolveState(c.GetString("user_id"), snippetID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check progress"})
+			return
+		}
+		showReference = service.CanSeeSolution(snippet, viewer(c), state)
+	}
+
+	result, err := h.snippetService.TraceCode(c.Request.Context(), snippet, &req, showReference)
+	if errors.Is(err, service.ErrNoSuchTestCase) || errors.Is(err, service.ErrTraceUnsupported) || errors.Is(err, service.ErrMultiFileUnsupported) || errors.Is(err, service.ErrTraceNeedsFunction) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		executionFailed(c, err, "Trace failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// RunCustom runs the learner's code and the reference solution on inputs the
+// learner chose, returning both outputs but not the reference code
+func (h *SnippetHandler) RunCustom(c *gin.Context) {
+	snippetID := c.Param("id")
+
+	var req model.CustomRunRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.IsTrial = c.GetBool("is_trial")
+
+	snippet, err := h.snippetService.GetSnippet(snippetID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snippet not found"})
+		return
+	}
+	if !h.checkLocalized(c, snippet, req.Mode) {
+		return
+	}
+
+	result, err := h.snippetService.RunCustom(c.Request.Context(), snippet, &req)
+	if errors.Is(err, service.ErrInvalidCustomInput) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":  err.Error(),
+			"params": service.SnippetParams(snippet),
+		})
+		return
+	}
+	if err != nil {
+		executionFailed(c, err, "Custom run failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Diagnose checks code as it is typed, without running it
+func (h *SnippetHandler) Diagnose(c *gin.Context) {
+	var req model.DiagnosticsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.snippetService.Diagnose(c.Request.Context(), &req)
+	if errors.Is(err, service.ErrDiagnosticsUnsupported) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		executionFailed(c, err, "Diagnostics failed")
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// statusClientClosedRequest is logged when the client hung up mid-run
+const statusClientClosedRequest = 499
+
+// executionFailed maps an error from running code to a response: oversized
+// code, edits to files that can't be edited, an executor outage (with
+// Retry-After) or a client that went away
+func executionFailed(c *gin.Context, err error, message string) {
+	var unavailable *service.ExecutorUnavailableError
+	switch {
+	case errors.Is(err, service.ErrCodeTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, service.ErrInvalidFileEdit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.As(err, &unavailable):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(unavailable.RetryAfter.Seconds()))))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Code execution is temporarily unavailable, please retry"})
+	case errors.Is(err, context.Canceled):
+		log.Println("⚠️  Client disconnected, execution cancelled")
+		c.AbortWithStatus(statusClientClosedRequest)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// viewer identifies the authenticated user for snippet projection
+func viewer(c *gin.Context) service.Viewer {
+	return service.Viewer{
+		UserID: c.GetString("user_id"),
+		Role:   c.GetString("user_role"),
+	}
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"time"
+)
+
+// BugType is a node of the bug taxonomy. Categories have no ParentID.
+type BugType struct {
+	ID          int    `json:"id" db:"id"`
+	Slug        string `json:"slug" db:"slug"`
+	Name        string `json:"name" db:"name"`
+	Description string `json:"description" db:"description"`
+	ParentID    *int   `json:"parent_id,omitempty" db:"parent_id"`
+}
+
+// BugQuiz asks the learner which type of bug a snippet contains
+type BugQuiz struct {
+	SnippetID string    `json:"snippet_id"`
+	Choices   []BugType `json:"choices"`
+}
+
+type QuizAnswerRequest struct {
+	BugTypeID int `json:"bug_type_id" binding:"required"`
+}
+
+type QuizAnswer struct {
+	ID               int64     `json:"id" db:"id"`
+	UserID           string    `json:"user_id" db:"user_id"`
+	SnippetID        string    `json:"snippet_id" db:"snippet_id"`
+	ChosenBugTypeID  int       `json:"chosen_bug_type_id" db:"chosen_bug_type_id"`
+	CorrectBugTypeID int       `json:"correct_bug_type_id" db:"correct_bug_type_id"`
+	IsCorrect        bool      `json:"is_correct" db:"is_correct"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+}
+
+type QuizAnswerResponse struct {
+	IsCorrect bool     `json:"is_correct"`
+	Chosen    *BugType `json:"chosen"`
+	Answer    *BugType `json:"answer"`
+}
+
+// BugTypeProgress is a user's quiz accuracy on one bug type
+type BugTypeProgress struct {
+	BugTypeID int     `json:"bug_type_id" db:"bug_type_id"`
+	Slug      string  `json:"slug" db:"slug"`
+	Name      string  `json:"name" db:"name"`
+	Answered  int     `json:"answered" db:"answered"`
+	Correct   int     `json:"correct" db:"correct"`
+	Accuracy  float64 `json:"accuracy" db:"accuracy"`
+}
@@ -0,0 +1,34 @@
+package model
+
+// Coverage is which lines of the code ran. Uncovered are the lines that
+// could have run but didn't.
+type Coverage struct {
+	Covered   []int `json:"covered"`
+	Uncovered []int `json:"uncovered"`
+}
+
+// CoverageReport shows an author which lines of CorrectCode the snippet's
+// tests never reach; tests that leave lines uncovered can pass buggy code.
+// Tests break coverage down by test case where the runner allows it, which
+// Go tests don't. Passed is false when CorrectCode fails its own tests, so
+// the coverage is of a run that went wrong.
+type CoverageReport struct {
+	SnippetID string `json:"snippet_id"`
+	Coverage
+	UncoveredCode []SourceLine   `json:"uncovered_code"`
+	Tests         []CaseCoverage `json:"tests,omitempty"`
+	Passed        bool           `json:"passed"`
+}
+
+// SourceLine is one line of code, by its 1-based number
+type SourceLine struct {
+	Line int    `json:"line"`
+	Code string `json:"code"`
+}
+
+// CaseCoverage is what one test case covered
+type CaseCoverage struct {
+	TestCase int    `json:"test_case"`
+	Name     string `json:"name,omitempty"`
+	Covered  []int  `json:"covered"`
+}
@@ -0,0 +1,39 @@
+package model
+
+// CustomRunRequest runs the learner's code and the reference solution on
+// inputs the learner made up. Each input maps parameter names to values.
+type CustomRunRequest struct {
+	Code     string                   `json:"code" binding:"required"`
+	Language string                   `json:"language" binding:"required"`
+	Inputs   []map[string]interface{} `json:"inputs" binding:"required,min=1"`
+	// Mode "localize" requires the bug to be localized before running, as
+	// snippets with RequireLocalization always do
+	Mode string `json:"mode,omitempty"`
+	// IsTrial selects the trial plan's limits; set from the token, not the body
+	IsTrial bool `json:"-"`
+}
+
+// SnippetParam is one parameter of the snippet's function. Type is "int",
+// "float", "bool", "string", "int_list" or "string_list" from a fuzz
+// spec, or "number", "list" or "object" when inferred from the test cases.
+type SnippetParam struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// CustomRunCase puts both outputs for one input side by side. Errors are
+// the raised exception, or why the run produced no output.
+type CustomRunCase struct {
+	Input          map[string]interface{} `json:"input"`
+	Learner        interface{}            `json:"learner"`
+	LearnerError   string                 `json:"learner_error,omitempty"`
+	Reference      interface{}            `json:"reference"`
+	ReferenceError string                 `json:"reference_error,omitempty"`
+	Match          bool                   `json:"match"`
+}
+
+type CustomRunResult struct {
+	SnippetID string          `json:"snippet_id"`
+	Params    []SnippetParam  `json:"params"`
+	Cases     []CustomRunCase `json:"cases"`
+}
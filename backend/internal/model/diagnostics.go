@@ -0,0 +1,26 @@
+package model
+
+// DiagnosticsRequest is code in the editor, to be checked without running
+// it. Files are the rest of a multi-file snippet's tree, which the code may
+// import.
+type DiagnosticsRequest struct {
+	Code     string        `json:"code"`
+	Language string        `json:"language" binding:"required"`
+	Files    []FileContent `json:"files,omitempty" binding:"dive"`
+}
+
+// Diagnostic is a problem at a 1-based position in the code; Column is 0
+// when only the line is known. Severity is "error" or "warning", and Source
+// is "syntax", "compile" (Go type and import errors), "vet" or "policy"
+// (Python the sandbox would refuse to run).
+type Diagnostic struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Source   string `json:"source"`
+}
+
+type DiagnosticsResult struct {
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+)
+
+// Draft is a learner's unsubmitted code for a snippet. Version changes on
+// every save and guards against overwriting edits made on another device;
+// it is never reused, even once a draft expires or is deleted.
+type Draft struct {
+	SnippetID string `json:"snippet_id"`
+	Code      string `json:"code"`
+	// Files are the edited files of a multi-file snippet, as submitted
+	Files     []FileContent `json:"files,omitempty"`
+	Version   int           `json:"version"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// SaveDraftRequest carries the version the client last saw, 0 for a new draft
+type SaveDraftRequest struct {
+	Code    string        `json:"code"`
+	Files   []FileContent `json:"files,omitempty" binding:"dive"`
+	Version int           `json:"version" binding:"min=0"`
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// ExecutionLimits bound a single run in the executor. Zero fields fall back
+// to the next level: snippet overrides, then the global defaults.
+type ExecutionLimits struct {
+	TimeoutSec  int     `json:"timeout_sec,omitempty"`
+	MemoryMB    int     `json:"memory_mb,omitempty"`
+	CPUs        float64 `json:"cpus,omitempty"`
+	OutputBytes int     `json:"output_bytes,omitempty"`
+	Pids        int     `json:"pids,omitempty"`
+}
+
+// Scan implements sql.Scanner for JSONB
+func (l *ExecutionLimits) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, l)
+}
+
+// Value implements driver.Valuer for JSONB
+func (l ExecutionLimits) Value() (driver.Value, error) {
+	return json.Marshal(l)
+}
+
+// PolicyViolation is a construct the executor's sandbox policy refused, such
+// as a disallowed import. Kind is "import", "builtin" or "attribute"; File
+// names the snippet file it is in, unless it is in the submitted code.
+type PolicyViolation struct {
+	Kind    string `json:"kind"`
+	Name    string `json:"name"`
+	File    string `json:"file,omitempty"`
+	Line    int    `json:"line"`
+	Message string `json:"message"`
+}
+
+// ResourceUsage is what a run consumed in the executor and why it ended.
+// Termination is "exited", "signal", "timeout", "oom" or "output-limit".
+type ResourceUsage struct {
+	CPUUserMS    float64 `json:"cpu_user_ms"`
+	CPUSysMS     float64 `json:"cpu_sys_ms"`
+	PeakMemoryKB int64   `json:"peak_memory_kb"`
+	OutputBytes  int     `json:"output_bytes"`
+	Termination  string  `json:"termination"`
+	Signal       int     `json:"signal,omitempty"`
+}
@@ -0,0 +1,30 @@
+package model
+
+// Mutant is one candidate bug produced by applying a mutation operator to a
+// snippet's CorrectCode. SnippetID is set when it was kept as a draft.
+type Mutant struct {
+	Operator    string `json:"operator"`
+	Line        int    `json:"line"`
+	Original    string `json:"original"`
+	Replacement string `json:"replacement"`
+	Killed      bool   `json:"killed"`
+	SnippetID   string `json:"snippet_id,omitempty"`
+	Error       string `json:"error,omitempty"`
+}
+
+// ReviewDraftRequest approves or rejects a pending_review draft
+type ReviewDraftRequest struct {
+	Decision string `json:"decision" binding:"required,oneof=approve reject"`
+}
+
+// MutationReport summarizes a mutation run. Only killed mutants become
+// pending_review drafts; survivors are behaviorally equivalent as far as the
+// snippet's tests can tell.
+type MutationReport struct {
+	SnippetID string   `json:"snippet_id"`
+	Generated int      `json:"generated"`
+	Killed    int      `json:"killed"`
+	Survived  int      `json:"survived"`
+	Mutants   []Mutant `json:"mutants"`
+	Error     string   `json:"error,omitempty"`
+}
@@ -0,0 +1,64 @@
+package model
+
+import (
+	"time"
+)
+
+type Attempt struct {
+	ID                 int64     `json:"id" db:"id"`
+	UserID             string    `json:"user_id" db:"user_id"`
+	SnippetID          string    `json:"snippet_id" db:"snippet_id"`
+	SubmittedCode      string    `json:"submitted_code" db:"submitted_code"`
+	IsCorrect          bool      `json:"is_correct" db:"is_correct"`
+	ExecutionTimeMS    int       `json:"execution_time_ms" db:"execution_time_ms"`
+	TestCasesPassed    int       `json:"test_cases_passed" db:"test_cases_passed"`
+	TestCasesTotal     int       `json:"test_cases_total" db:"test_cases_total"`
+	HintsUsed          int       `json:"hints_used" db:"hints_used"`
+	AttemptNumber      int       `json:"attempt_number" db:"attempt_number"`
+	LocalizationResult *string   `json:"localization_result,omitempty" db:"localization_result"`
+	GaveUp             bool      `json:"gave_up" db:"gave_up"`
+	CreatedAt          time.Time `json:"created_at" db:"created_at"`
+}
+
+// PatternStats aggregates a user's attempts on one pattern's snippets
+type PatternStats struct {
+	SnippetsAttempted    int
+	SnippetsSolved       int
+	TotalAttempts        int
+	Localizations        int
+	LocalizationAccuracy float64
+}
+
+type PatternProgress struct {
+	PatternID            int        `json:"pattern_id" db:"pattern_id"`
+	PatternName          string     `json:"pattern_name" db:"pattern_name"`
+	Attempted            int        `json:"attempted" db:"snippets_attempted"`
+	Solved               int        `json:"solved" db:"snippets_solved"`
+	TotalAttempts        int        `json:"total_attempts" db:"total_attempts"`
+	AvgAttemptsPerSolve  *float64   `json:"avg_attempts_per_solve,omitempty" db:"avg_attempts_per_solve"`
+	LocalizationAccuracy *float64   `json:"localization_accuracy,omitempty" db:"localization_accuracy"`
+	MasteryLevel         int        `json:"mastery_level" db:"mastery_level"`
+	LastPracticedAt      *time.Time `json:"last_practiced_at,omitempty" db:"last_practiced_at"`
+}
+
+type UserProgress struct {
+	TotalSnippetsAttempted int               `json:"total_snippets_attempted"`
+	TotalSnippetsSolved    int               `json:"total_snippets_solved"`
+	Patterns               []PatternProgress `json:"patterns"`
+	BugTypes               []BugTypeProgress `json:"bug_types"`
+}
+
+// LocalizeRequest is the learner's guess at the buggy lines of BuggyCode
+type LocalizeRequest struct {
+	StartLine int `json:"start_line" binding:"required,min=1"`
+	EndLine   int `json:"end_line" binding:"required,min=1"`
+}
+
+// LocalizationResult scores a guess as "exact", "near" or "wrong"
+type LocalizationResult struct {
+	StartLine int       `json:"start_line"`
+	EndLine   int       `json:"end_line"`
+	Result    string    `json:"result"`
+	Accuracy  float64   `json:"accuracy"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -16,28 +16,99 @@ type PatternCategory struct {
 }
 
 type Snippet struct {
-	ID             string    `json:"id" db:"id"`
-	PatternID      int       `json:"pattern_id" db:"pattern_id"`
-	Title          string    `json:"title" db:"title"`
-	Description    string    `json:"description" db:"description"`
-	Difficulty     string    `json:"difficulty" db:"difficulty"`
-	Language       string    `json:"language" db:"language"`
-	CorrectCode    string    `json:"correct_code" db:"correct_code"`
-	BuggyCode      string    `json:"buggy_code" db:"buggy_code"`
-	BugType        string    `json:"bug_type" db:"bug_type"`
-	BugExplanation string    `json:"bug_explanation" db:"bug_explanation"`
-	TestCases      TestCases `json:"test_cases" db:"test_cases"`
-	Hint1          string    `json:"hint_1" db:"hint_1"`
-	Hint2          string    `json:"hint_2" db:"hint_2"`
-	Hint3          string    `json:"hint_3" db:"hint_3"`
-	CreatedBy      *string   `json:"created_by,omitempty" db:"created_by"`
-	Status         string    `json:"status" db:"status"`
-	CreatedAt      time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+	ID             string           `json:"id" db:"id"`
+	PatternID      int              `json:"pattern_id" db:"pattern_id"`
+	Title          string           `json:"title" db:"title"`
+	Description    string           `json:"description" db:"description"`
+	Difficulty     string           `json:"difficulty" db:"difficulty"`
+	Language       string           `json:"language" db:"language"`
+	CorrectCode    string           `json:"correct_code" db:"correct_code"`
+	BuggyCode      string           `json:"buggy_code" db:"buggy_code"`
+	Files          SnippetFiles     `json:"files,omitempty" db:"files"`
+	BugType        string           `json:"bug_type" db:"bug_type"`
+	BugTypeID      *int             `json:"bug_type_id,omitempty" db:"bug_type_id"`
+	BugExplanation string           `json:"bug_explanation" db:"bug_explanation"`
+	TestCases      TestCases        `json:"test_cases" db:"test_cases"`
+	FuzzSpec       *FuzzSpec        `json:"fuzz_spec,omitempty" db:"fuzz_spec"`
+	PerfSpec       *PerfSpec        `json:"perf_spec,omitempty" db:"perf_spec"`
+	EditPolicy     *EditPolicy      `json:"edit_policy,omitempty" db:"edit_policy"`
+	Limits         *ExecutionLimits `json:"limits,omitempty" db:"limits"`
+	TestFormat     string           `json:"test_format" db:"test_format"`
+	TestCode       string           `json:"test_code,omitempty" db:"test_code"`
+	GoTest         *GoTestSpec      `json:"go_test,omitempty" db:"go_test"`
+	SQLSpec        *SQLSpec         `json:"sql_spec,omitempty" db:"sql_spec"`
+	Stdio          *StdioSpec       `json:"stdio,omitempty" db:"stdio"`
+	// RequireLocalization makes learners guess the buggy lines before
+	// running any code
+	RequireLocalization bool      `json:"require_localization" db:"require_localization"`
+	Hint1               string    `json:"hint_1" db:"hint_1"`
+	Hint2               string    `json:"hint_2" db:"hint_2"`
+	Hint3               string    `json:"hint_3" db:"hint_3"`
+	CreatedBy           *string   `json:"created_by,omitempty" db:"created_by"`
+	Status              string    `json:"status" db:"status"`
+	CreatedAt           time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time `json:"updated_at" db:"updated_at"`
 }
 
+// Test formats, in Snippet.TestFormat. "cases" grades by TestCases, calling
+// the function with each Input; "stdio" also grades by TestCases but runs
+// the code as a program, feeding it each Stdin and comparing what it prints.
+// The others run TestCode, a pytest or _test.go file, against the
+// submission, which can express what one call can't, such as a class used
+// over several calls.
+const (
+	TestFormatCases  = "cases"
+	TestFormatStdio  = "stdio"
+	TestFormatPytest = "pytest"
+	TestFormatGoTest = "go_test"
+)
+
+// SnippetFile is a file of a multi-file snippet. The code, BuggyCode or
+// CorrectCode, is the entrypoint and runs with the files next to it, so it
+// can import them. A submission may only change editable files, whose fixed
+// version is CorrectContent, or Content when the bug lies elsewhere.
+type SnippetFile struct {
+	Path           string `json:"path"`
+	Content        string `json:"content"`
+	CorrectContent string `json:"correct_content,omitempty"`
+	Editable       bool   `json:"editable"`
+}
+
+// Correct is the file's content in the reference solution
+func (f SnippetFile) Correct() string {
+	if f.Editable && f.CorrectContent != "" {
+		return f.CorrectContent
+	}
+	return f.Content
+}
+
+type SnippetFiles []SnippetFile
+
+// Scan implements sql.Scanner for JSONB
+func (f *SnippetFiles) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, f)
+}
+
+// Value implements driver.Valuer for JSONB
+func (f SnippetFiles) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+// FileContent is a file by path, as submitted or as revealed
+type FileContent struct {
+	Path    string `json:"path" binding:"required"`
+	Content string `json:"content"`
+}
+
+// TestCase is a call and its result, or for stdio snippets the input on
+// Stdin and the expected output as a string
 type TestCase struct {
-	Input    map[string]interface{} `json:"input"`
+	Input    map[string]interface{} `json:"input,omitempty"`
+	Stdin    string                 `json:"stdin,omitempty"`
 	Expected interface{}            `json:"expected"`
 }
 
@@ -57,9 +128,203 @@ func (tc TestCases) Value() (driver.Value, error) {
 	return json.Marshal(tc)
 }
 
+// FuzzSpec describes how to generate random inputs for differential testing
+// against CorrectCode. Params are listed in the order the function takes them.
+type FuzzSpec struct {
+	Runs   int         `json:"runs"`
+	Params []FuzzParam `json:"params"`
+}
+
+// FuzzParam describes a single generated argument. Type is one of "int",
+// "float", "bool", "string", "int_list" or "string_list". Min and Max bound
+// numbers and list items; an unset bound is 100 away from the other, or
+// [0, 100] with neither. MinLen and MaxLen bound strings and lists, and both
+// the list and each string of a string_list.
+type FuzzParam struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Min      *float64 `json:"min,omitempty"`
+	Max      *float64 `json:"max,omitempty"`
+	MinLen   int      `json:"min_len,omitempty"`
+	MaxLen   int      `json:"max_len,omitempty"`
+	Alphabet string   `json:"alphabet,omitempty"`
+	Sorted   bool     `json:"sorted,omitempty"`
+	Unique   bool     `json:"unique,omitempty"`
+}
+
+// Scan implements sql.Scanner for JSONB
+func (f *FuzzSpec) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, f)
+}
+
+// Value implements driver.Valuer for JSONB
+func (f FuzzSpec) Value() (driver.Value, error) {
+	return json.Marshal(f)
+}
+
+// PerfSpec describes performance checks timed against CorrectCode on the same
+// executor. Inputs are generated from Params; list and string params get
+// their length from the case or scaling size.
+type PerfSpec struct {
+	Params  []FuzzParam  `json:"params"`
+	Seed    int64        `json:"seed,omitempty"`
+	Cases   []PerfCase   `json:"cases,omitempty"`
+	Scaling *ScalingSpec `json:"scaling,omitempty"`
+}
+
+// PerfCase allows BudgetFactor times the reference solution's time, but never
+// less than MinBudgetMS.
+type PerfCase struct {
+	Size         int     `json:"size"`
+	BudgetFactor float64 `json:"budget_factor,omitempty"`
+	MinBudgetMS  float64 `json:"min_budget_ms,omitempty"`
+}
+
+// ScalingSpec runs increasing input sizes and compares the measured growth
+// rate with Complexity, e.g. "O(log n)", "O(n)" or "O(n^2)".
+type ScalingSpec struct {
+	Sizes      []int  `json:"sizes"`
+	Complexity string `json:"complexity"`
+}
+
+// Scan implements sql.Scanner for JSONB
+func (p *PerfSpec) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// Value implements driver.Valuer for JSONB
+func (p PerfSpec) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// EditPolicy decides what happens when a passing submission rewrites the
+// snippet instead of fixing it. Mode is "off", "warn", "penalize" or
+// "reject"; Granularity is "line" or "token". MaxEditDistance defaults to
+// twice the distance between BuggyCode and CorrectCode.
+type EditPolicy struct {
+	Mode                  string `json:"mode"`
+	Granularity           string `json:"granularity,omitempty"`
+	MaxEditDistance       int    `json:"max_edit_distance,omitempty"`
+	Penalty               int    `json:"penalty,omitempty"`
+	AllowOutsideBugRegion bool   `json:"allow_outside_bug_region,omitempty"`
+}
+
+// Scan implements sql.Scanner for JSONB
+func (p *EditPolicy) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+// Value implements driver.Valuer for JSONB
+func (p EditPolicy) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// StdioSpec says how a program's output is compared with the expected
+// output. Whitespace is "exact", "lines" (the default: line endings,
+// trailing spaces and trailing blank lines don't matter) or "tokens" (only
+// the whitespace-separated tokens do). FloatTolerance accepts numeric tokens
+// within that absolute or relative error, as programming contest judges do.
+type StdioSpec struct {
+	Whitespace     string  `json:"whitespace,omitempty"`
+	FloatTolerance float64 `json:"float_tolerance,omitempty"`
+}
+
+// Whitespace modes, in StdioSpec.Whitespace
+const (
+	StdioExact  = "exact"
+	StdioLines  = "lines"
+	StdioTokens = "tokens"
+)
+
+// Scan implements sql.Scanner for JSONB
+func (s *StdioSpec) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+// Value implements driver.Valuer for JSONB
+func (s StdioSpec) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// GoTestSpec tunes the go test run of a Go snippet. Count reruns the tests
+// to shake out flaky races; TimeoutSec is the -timeout for the test binary.
+type GoTestSpec struct {
+	Count      int `json:"count,omitempty"`
+	TimeoutSec int `json:"timeout_sec,omitempty"`
+}
+
+// Scan implements sql.Scanner for JSONB
+func (g *GoTestSpec) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, g)
+}
+
+// Value implements driver.Valuer for JSONB
+func (g GoTestSpec) Value() (driver.Value, error) {
+	return json.Marshal(g)
+}
+
+// SQLSpec sets up the database of a SQL snippet, whose code is a single
+// query. Schema and Seed are scripts run on a fresh in-memory SQLite database
+// for each query; the submission's rows are compared with CorrectCode's, in
+// order when Ordered is set and as a multiset otherwise. MaxRows and
+// TimeoutMS bound each query.
+type SQLSpec struct {
+	Schema    string `json:"schema"`
+	Seed      string `json:"seed,omitempty"`
+	Ordered   bool   `json:"ordered,omitempty"`
+	MaxRows   int    `json:"max_rows,omitempty"`
+	TimeoutMS int    `json:"timeout_ms,omitempty"`
+}
+
+// Scan implements sql.Scanner for JSONB
+func (q *SQLSpec) Scan(value interface{}) error {
+	bytes, ok := value.([]byte)
+	if !ok {
+		return nil
+	}
+	return json.Unmarshal(bytes, q)
+}
+
+// Value implements driver.Valuer for JSONB
+func (q SQLSpec) Value() (driver.Value, error) {
+	return json.Marshal(q)
+}
+
 type ExecuteCodeRequest struct {
 	Code     string `json:"code" binding:"required"`
 	Language string `json:"language" binding:"required"`
+	// IsTrial selects the trial plan's limits; set from the token, not the body
+	IsTrial bool `json:"-"`
+	// FuzzSeed replays a previous fuzz run when set
+	FuzzSeed *int64 `json:"fuzz_seed,omitempty"`
+	// Mode "localize" requires the bug to be localized before running, as
+	// snippets with RequireLocalization always do
+	Mode string `json:"mode,omitempty"`
+	// Files are the editable files of a multi-file snippet as edited; any
+	// left out are submitted unchanged
+	Files []FileContent `json:"files,omitempty" binding:"dive"`
+	// Coverage asks which lines of the code the tests ran
+	Coverage bool `json:"coverage,omitempty"`
 }
 
 type ExecuteCodeResponse struct {
@@ -70,6 +335,98 @@ type ExecuteCodeResponse struct {
 	TotalTimeMS int          `json:"total_time_ms"`
 	Stdout      string       `json:"stdout"`
 	Stderr      string       `json:"stderr"`
+	Fuzz        *FuzzResult  `json:"fuzz,omitempty"`
+	Performance *PerfResult  `json:"performance,omitempty"`
+	Edit        *EditResult  `json:"edit,omitempty"`
+	// Score is 100 for a correct submission less any edit penalty
+	Score        int                 `json:"score"`
+	Localization *LocalizationResult `json:"localization,omitempty"`
+	// Limits are the execution limits the submission ran under
+	Limits *ExecutionLimits `json:"limits,omitempty"`
+	// Cached is set when the result was reused from an identical earlier run
+	Cached bool `json:"cached"`
+	// PolicyViolations explain why the code was refused without running
+	PolicyViolations []PolicyViolation `json:"policy_violations,omitempty"`
+	// Coverage is what the tests covered together, when asked for
+	Coverage *Coverage `json:"coverage,omitempty"`
+}
+
+// EditResult compares a submission with BuggyCode. BugRegion and
+// RemovedLines are BuggyCode lines; ChangedLines are submission lines.
+// Action is what the snippet's policy did: "none", "warned", "penalized" or
+// "rejected". For multi-file snippets Files breaks the edit down by editable
+// file, and the distance and bug region cover them all. The bug region says
+// where the bug is, so it is only shown to those who may see the solution.
+type EditResult struct {
+	Granularity      string `json:"granularity"`
+	EditDistance     int    `json:"edit_distance"`
+	MaxEditDistance  int    `json:"max_edit_distance,omitempty"`
+	ChangedLines     []int  `json:"changed_lines"`
+	RemovedLines     []int  `json:"removed_lines"`
+	BugRegion        []int  `json:"bug_region,omitempty"`
+	TouchesBugRegion bool   `json:"touches_bug_region,omitempty"`
+	IsRewrite        bool   `json:"is_rewrite"`
+	Action           string `json:"action"`
+	Penalty          int    `json:"penalty,omitempty"`
+	Message          string `json:"message,omitempty"`
+	// Files is empty for single-file snippets
+	Files []FileEditResult `json:"files,omitempty"`
+}
+
+// FileEditResult is the part of an edit in one editable file, with lines
+// numbered as in EditResult
+type FileEditResult struct {
+	Path             string `json:"path"`
+	EditDistance     int    `json:"edit_distance"`
+	ChangedLines     []int  `json:"changed_lines"`
+	RemovedLines     []int  `json:"removed_lines"`
+	BugRegion        []int  `json:"bug_region,omitempty"`
+	TouchesBugRegion bool   `json:"touches_bug_region,omitempty"`
+}
+
+// FuzzResult reports a differential run against the reference solution.
+// Seed reproduces the exact inputs when sent back as fuzz_seed.
+type FuzzResult struct {
+	Seed           int64       `json:"seed"`
+	Runs           int         `json:"runs"`
+	Passed         bool        `json:"passed"`
+	Counterexample *TestResult `json:"counterexample,omitempty"`
+	Error          string      `json:"error,omitempty"`
+}
+
+// PerfResult reports timed cases and the scaling check. Passed is false when
+// any case exceeds its budget, the growth rate exceeds the expected class or
+// the check could not be made, which Error explains.
+type PerfResult struct {
+	Passed  bool             `json:"passed"`
+	Cases   []PerfCaseResult `json:"cases,omitempty"`
+	Scaling *ScalingResult   `json:"scaling,omitempty"`
+	Error   string           `json:"error,omitempty"`
+}
+
+type PerfCaseResult struct {
+	Size        int     `json:"size"`
+	TimeMS      float64 `json:"time_ms"`
+	ReferenceMS float64 `json:"reference_ms"`
+	BudgetMS    float64 `json:"budget_ms"`
+	Passed      bool    `json:"passed"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// ScalingResult holds the fitted log-log slope of time against input size for
+// the submission and the reference. Inconclusive means the reference itself
+// did not scale as expected, so the measurement was not held against the
+// submission.
+type ScalingResult struct {
+	Sizes             []int     `json:"sizes"`
+	TimesMS           []float64 `json:"times_ms"`
+	ReferenceMS       []float64 `json:"reference_ms"`
+	Exponent          float64   `json:"exponent"`
+	ReferenceExponent float64   `json:"reference_exponent"`
+	Estimated         string    `json:"estimated"`
+	Expected          string    `json:"expected"`
+	Inconclusive      bool      `json:"inconclusive,omitempty"`
+	Passed            bool      `json:"passed"`
 }
 
 type TestResult struct {
@@ -79,4 +436,38 @@ type TestResult struct {
 	Actual          interface{} `json:"actual"`
 	Passed          bool        `json:"passed"`
 	ExecutionTimeMS int         `json:"execution_time_ms"`
+	// Usage explains what the run consumed and why it stopped
+	Usage *ResourceUsage `json:"usage,omitempty"`
+	// Name and Errors are set for tests from a test file
+	Name   string      `json:"name,omitempty"`
+	Errors []TestError `json:"errors,omitempty"`
+	// Coverage is what this test case covered, when asked for
+	Coverage *Coverage `json:"coverage,omitempty"`
+}
+
+// ResultSet is what a SQL query returned. Values are numbers, strings or
+// null; blobs are hex strings.
+type ResultSet struct {
+	Columns []string        `json:"columns"`
+	Rows    [][]interface{} `json:"rows"`
+}
+
+// TestError is a structured failure from a test run. Kind is "failure" for
+// a failed assertion, "exception", "error" (pytest collection or fixtures),
+// "race", "leak", "panic" or "timeout".
+type TestError struct {
+	Kind      string          `json:"kind"`
+	Message   string          `json:"message"`
+	Locations []ErrorLocation `json:"locations,omitempty"`
+}
+
+// ErrorLocation points into the snippet. For races Op is the access, such as
+// "read", "previous write" or "goroutine created"; for leaked goroutines it
+// is what they are blocked on.
+type ErrorLocation struct {
+	Op        string `json:"op,omitempty"`
+	Goroutine int    `json:"goroutine,omitempty"`
+	Function  string `json:"function,omitempty"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
 }
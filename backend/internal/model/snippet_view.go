@@ -0,0 +1,88 @@
+package model
+
+import (
+	"time"
+)
+
+// Solve states of a snippet for one learner
+const (
+	SolveStateUnsolved = "unsolved"
+	SolveStateSolved   = "solved"
+	SolveStateGivenUp  = "given_up"
+)
+
+// LearnerSnippet is what a learner sees before solving or giving up: the
+// buggy code and its tests, but nothing that names or explains the bug
+type LearnerSnippet struct {
+	ID                  string        `json:"id"`
+	PatternID           int           `json:"pattern_id"`
+	Title               string        `json:"title"`
+	Description         string        `json:"description"`
+	Difficulty          string        `json:"difficulty"`
+	Language            string        `json:"language"`
+	BuggyCode           string        `json:"buggy_code,omitempty"`
+	Files               []SnippetFile `json:"files,omitempty"`
+	TestCases           TestCases     `json:"test_cases,omitempty"`
+	TestFormat          string        `json:"test_format,omitempty"`
+	TestCode            string        `json:"test_code,omitempty"`
+	SQLSpec             *SQLSpec      `json:"sql_spec,omitempty"`
+	Stdio               *StdioSpec    `json:"stdio,omitempty"`
+	RequireLocalization bool          `json:"require_localization,omitempty"`
+	HintsAvailable      int           `json:"hints_available,omitempty"`
+	SolveState          string        `json:"solve_state"`
+}
+
+// SolvedSnippet adds the answer once the learner has solved the snippet or
+// given up on it. CorrectFiles are the fixed editable files of a multi-file
+// snippet.
+type SolvedSnippet struct {
+	LearnerSnippet
+	CorrectCode    string        `json:"correct_code,omitempty"`
+	CorrectFiles   []FileContent `json:"correct_files,omitempty"`
+	BugType        string        `json:"bug_type"`
+	BugTypeID      *int          `json:"bug_type_id,omitempty"`
+	BugExplanation string        `json:"bug_explanation,omitempty"`
+	Hint1          string        `json:"hint_1,omitempty"`
+	Hint2          string        `json:"hint_2,omitempty"`
+	Hint3          string        `json:"hint_3,omitempty"`
+}
+
+// AuthorSnippet is the author's view of their own snippet, including the
+// checks that run on submissions
+type AuthorSnippet struct {
+	SolvedSnippet
+	FuzzSpec   *FuzzSpec   `json:"fuzz_spec,omitempty"`
+	PerfSpec   *PerfSpec   `json:"perf_spec,omitempty"`
+	EditPolicy *EditPolicy `json:"edit_policy,omitempty"`
+	GoTest     *GoTestSpec `json:"go_test,omitempty"`
+	Status     string      `json:"status"`
+	CreatedAt  time.Time   `json:"created_at"`
+	UpdatedAt  time.Time   `json:"updated_at"`
+}
+
+// RevealRequest asks for the answer. Forfeit gives up on an unsolved snippet
+// to see it, which counts as an unsolved attempt.
+type RevealRequest struct {
+	Forfeit bool `json:"forfeit"`
+}
+
+// Reveal is the reference solution with a unified diff from BuggyCode, and
+// one per editable file of a multi-file snippet
+type Reveal struct {
+	SnippetID      string     `json:"snippet_id"`
+	SolveState     string     `json:"solve_state"`
+	CorrectCode    string     `json:"correct_code"`
+	Diff           string     `json:"diff"`
+	Files          []FileDiff `json:"files,omitempty"`
+	BugType        string     `json:"bug_type"`
+	BugTypeID      *int       `json:"bug_type_id,omitempty"`
+	BugExplanation string     `json:"bug_explanation"`
+}
+
+// FileDiff is the fixed version of an editable file and its diff from the
+// buggy one; the diff is empty for files the fix doesn't touch
+type FileDiff struct {
+	Path           string `json:"path"`
+	CorrectContent string `json:"correct_content"`
+	Diff           string `json:"diff"`
+}
@@ -0,0 +1,63 @@
+package model
+
+// TraceRequest traces one test case of the submitted code. With
+// CompareReference the correct code is traced too and the first divergence
+// reported.
+type TraceRequest struct {
+	Code             string `json:"code" binding:"required"`
+	Language         string `json:"language" binding:"required"`
+	TestCase         int    `json:"test_case" binding:"required,min=1"`
+	CompareReference bool   `json:"compare_reference"`
+	// Mode "localize" requires the bug to be localized before running, as
+	// snippets with RequireLocalization always do
+	Mode string `json:"mode,omitempty"`
+	// IsTrial selects the trial plan's limits; set from the token, not the body
+	IsTrial bool `json:"-"`
+}
+
+// TraceStep is one event in a traced function: "call", "line", "return" or
+// "exception". Locals hold repr()s of the local variables; Value is the
+// returned value or the raised exception.
+type TraceStep struct {
+	Step     int               `json:"step"`
+	Line     int               `json:"line"`
+	Event    string            `json:"event"`
+	Function string            `json:"function"`
+	Locals   map[string]string `json:"locals"`
+	Value    string            `json:"value,omitempty"`
+}
+
+// Trace is the timeline of one run. Truncated is set when the step or size
+// budget ran out first.
+type Trace struct {
+	Steps     []TraceStep `json:"steps"`
+	Truncated bool        `json:"truncated"`
+	Output    string      `json:"output"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// TraceDivergence is the first step where learner and reference state
+// differ. Reason is "flow", "variable", "value" or "ended"; Variable
+// names the differing local for "variable".
+type TraceDivergence struct {
+	Step          int    `json:"step"`
+	Reason        string `json:"reason"`
+	LearnerLine   int    `json:"learner_line"`
+	ReferenceLine int    `json:"reference_line"`
+	Variable      string `json:"variable,omitempty"`
+	Learner       string `json:"learner,omitempty"`
+	Reference     string `json:"reference,omitempty"`
+}
+
+// TraceResult is returned by the trace endpoint. The reference timeline is
+// only included for viewers allowed to see the solution; the divergence is
+// always reported when requested.
+type TraceResult struct {
+	SnippetID  string           `json:"snippet_id"`
+	TestCase   int              `json:"test_case"`
+	Input      interface{}      `json:"input"`
+	Expected   interface{}      `json:"expected"`
+	Learner    Trace            `json:"learner"`
+	Reference  *Trace           `json:"reference,omitempty"`
+	Divergence *TraceDivergence `json:"divergence,omitempty"`
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/bugdrill/backend/internal/database"
+	"github.com/bugdrill/backend/internal/model"
+	"github.com/lib/pq"
+)
+
+type AttemptRepository struct {
+	db *database.DB
+}
+
+func NewAttemptRepository(db *database.DB) *AttemptRepository {
+	return &AttemptRepository{db: db}
+}
+
+func (r *AttemptRepository) Create(attempt *model.Attempt) error {
+	query := `
+		INSERT INTO user_snippet_attempts (
+			user_id, snippet_id, submitted_code, is_correct, execution_time_ms,
+			test_cases_passed, test_cases_total, hints_used, attempt_number,
+			localization_result, gave_up
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8,
+			(SELECT COUNT(*) + 1 FROM user_snippet_attempts WHERE user_id = $1 AND snippet_id = $2),
+			$9, $10
+		)
+		RETURNING id, attempt_number, created_at
+	`
+	return r.db.QueryRow(
+		query,
+		attempt.UserID, attempt.SnippetID, attempt.SubmittedCode, attempt.IsCorrect,
+		attempt.ExecutionTimeMS, attempt.TestCasesPassed, attempt.TestCasesTotal,
+		attempt.HintsUsed, attempt.LocalizationResult, attempt.GaveUp,
+	).Scan(&attempt.ID, &attempt.AttemptNumber, &attempt.CreatedAt)
+}
+
+// CreateLocalization records a scored guess at a snippet's buggy lines as
+// the active one. It reports false without storing anything when another
+// guess is already active.
+func (r *AttemptRepository) CreateLocalization(userID, snippetID string, l *model.LocalizationResult) (bool, error) {
+	query := `
+		INSERT INTO user_snippet_localizations (user_id, snippet_id, start_line, end_line, result, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (user_id, snippet_id) WHERE cleared_at IS NULL DO NOTHING
+	`
+	res, err := r.db.Exec(query, userID, snippetID, l.StartLine, l.EndLine, l.Result, l.CreatedAt)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// GetActiveLocalization returns the user's active guess for a snippet, or nil
+// if there is none. Accuracy is left to the caller.
+func (r *AttemptRepository) GetActiveLocalization(userID, snippetID string) (*model.LocalizationResult, error) {
+	query := `
+		SELECT COALESCE(start_line, 0), COALESCE(end_line, 0), result, created_at
+		FROM user_snippet_localizations
+		WHERE user_id = $1 AND snippet_id = $2 AND cleared_at IS NULL
+	`
+	var l model.LocalizationResult
+	err := r.db.QueryRow(query, userID, snippetID).Scan(&l.StartLine, &l.EndLine, &l.Result, &l.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// ClearLocalization ends the user's active guess for a snippet, so the next
+// drill starts afresh
+func (r *AttemptRepository) ClearLocalization(userID, snippetID string) error {
+	query := `
+		UPDATE user_snippet_localizations SET cleared_at = NOW()
+		WHERE user_id = $1 AND snippet_id = $2 AND cleared_at IS NULL
+	`
+	_, err := r.db.Exec(query, userID, snippetID)
+	return err
+}
+
+// GetSolveStates returns the user's solve state for each of the snippets they
+// have attempted. A correct submission counts as solved even after giving up.
+func (r *AttemptRepository) GetSolveStates(userID string, snippetIDs []string) (map[string]string, error) {
+	query := `
+		SELECT snippet_id, BOOL_OR(is_correct), BOOL_OR(gave_up)
+		FROM user_snippet_attempts
+		WHERE user_id = $1 AND snippet_id = ANY($2)
+		GROUP BY snippet_id
+	`
+	rows, err := r.db.Query(query, userID, pq.Array(snippetIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	states := make(map[string]string, len(snippetIDs))
+	for rows.Next() {
+		var snippetID string
+		var solved, gaveUp bool
+		if err := rows.Scan(&snippetID, &solved, &gaveUp); err != nil {
+			return nil, err
+		}
+		switch {
+		case solved:
+			states[snippetID] = model.SolveStateSolved
+		case gaveUp:
+			states[snippetID] = model.SolveStateGivenUp
+		}
+	}
+	return states, rows.Err()
+}
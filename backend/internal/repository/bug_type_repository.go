@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"database/sql"
+
+	"github.com/bugdrill/backend/internal/database"
+	"github.com/bugdrill/backend/internal/model"
+)
+
+type BugTypeRepository struct {
+	db *database.DB
+}
+
+func NewBugTypeRepository(db *database.DB) *BugTypeRepository {
+	return &BugTypeRepository{db: db}
+}
+
+func (r *BugTypeRepository) GetAll() ([]model.BugType, error) {
+	query := `
+		SELECT id, slug, name, description, parent_id
+		FROM bug_types
+		ORDER BY COALESCE(parent_id, id), parent_id NULLS FIRST, name
+	`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bugTypes []model.BugType
+	for rows.Next() {
+		var b model.BugType
+		if err := rows.Scan(&b.ID, &b.Slug, &b.Name, &b.Description, &b.ParentID); err != nil {
+			return nil, err
+		}
+		bugTypes = append(bugTypes, b)
+	}
+	return bugTypes, rows.Err()
+}
+
+func (r *BugTypeRepository) GetBySlug(slug string) (*model.BugType, error) {
+	query := `
+		SELECT id, slug, name, description, parent_id
+		FROM bug_types
+		WHERE slug = $1
+	`
+	var b model.BugType
+	err := r.db.QueryRow(query, slug).Scan(&b.ID, &b.Slug, &b.Name, &b.Description, &b.ParentID)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// CreateAnswer stores the first answer for a user and snippet. It reports
+// false without storing anything when the quiz was already answered.
+func (r *BugTypeRepository) CreateAnswer(answer *model.QuizAnswer) (bool, error) {
+	query := `
+		INSERT INTO bug_type_quiz_answers (
+			user_id, snippet_id, chosen_bug_type_id, correct_bug_type_id, is_correct
+		) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, snippet_id) DO NOTHING
+		RETURNING id, created_at
+	`
+	err := r.db.QueryRow(
+		query,
+		answer.UserID, answer.SnippetID, answer.ChosenBugTypeID,
+		answer.CorrectBugTypeID, answer.IsCorrect,
+	).Scan(&answer.ID, &answer.CreatedAt)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (r *BugTypeRepository) GetAnswer(userID, snippetID string) (*model.QuizAnswer, error) {
+	query := `
+		SELECT id, user_id, snippet_id, chosen_bug_type_id, correct_bug_type_id, is_correct, created_at
+		FROM bug_type_quiz_answers
+		WHERE user_id = $1 AND snippet_id = $2
+	`
+	var a model.QuizAnswer
+	err := r.db.QueryRow(query, userID, snippetID).Scan(
+		&a.ID, &a.UserID, &a.SnippetID, &a.ChosenBugTypeID,
+		&a.CorrectBugTypeID, &a.IsCorrect, &a.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
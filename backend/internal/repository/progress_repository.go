@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"github.com/bugdrill/backend/internal/database"
+	"github.com/bugdrill/backend/internal/model"
+)
+
+type ProgressRepository struct {
+	db *database.DB
+}
+
+func NewProgressRepository(db *database.DB) *ProgressRepository {
+	return &ProgressRepository{db: db}
+}
+
+// GetPatternStats aggregates a user's attempts and localization guesses on
+// the snippets of a pattern. Each guess scores exact as 1, near as 0.5 and
+// wrong as 0, however many attempts follow it. A snippet the user gave up on
+// doesn't count as solved, even if they submit the revealed answer
+// afterwards.
+func (r *ProgressRepository) GetPatternStats(userID string, patternID int) (*model.PatternStats, error) {
+	query := `
+		SELECT COUNT(DISTINCT a.snippet_id),
+		       COUNT(DISTINCT a.snippet_id) FILTER (WHERE a.is_correct AND NOT EXISTS (
+		           SELECT 1 FROM user_snippet_attempts g
+		           WHERE g.user_id = a.user_id AND g.snippet_id = a.snippet_id
+		             AND g.gave_up AND g.created_at < a.created_at
+		       )),
+		       COUNT(*)
+		FROM user_snippet_attempts a
+		JOIN snippets s ON s.id = a.snippet_id
+		WHERE a.user_id = $1 AND s.pattern_id = $2
+	`
+	var st model.PatternStats
+	err := r.db.QueryRow(query, userID, patternID).Scan(
+		&st.SnippetsAttempted, &st.SnippetsSolved, &st.TotalAttempts,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	query = `
+		SELECT COUNT(*),
+		       COALESCE(AVG(CASE l.result
+		           WHEN 'exact' THEN 1.0
+		           WHEN 'near' THEN 0.5
+		           WHEN 'wrong' THEN 0.0
+		       END), 0)
+		FROM user_snippet_localizations l
+		JOIN snippets s ON s.id = l.snippet_id
+		WHERE l.user_id = $1 AND s.pattern_id = $2
+	`
+	err = r.db.QueryRow(query, userID, patternID).Scan(&st.Localizations, &st.LocalizationAccuracy)
+	if err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (r *ProgressRepository) Upsert(userID string, p *model.PatternProgress) error {
+	query := `
+		INSERT INTO user_pattern_progress (
+			user_id, pattern_id, snippets_attempted, snippets_solved, total_attempts,
+			avg_attempts_per_solve, localization_accuracy, mastery_level, last_practiced_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, NOW())
+		ON CONFLICT (user_id, pattern_id) DO UPDATE SET
+			snippets_attempted = EXCLUDED.snippets_attempted,
+			snippets_solved = EXCLUDED.snippets_solved,
+			total_attempts = EXCLUDED.total_attempts,
+			avg_attempts_per_solve = EXCLUDED.avg_attempts_per_solve,
+			localization_accuracy = EXCLUDED.localization_accuracy,
+			mastery_level = EXCLUDED.mastery_level,
+			last_practiced_at = EXCLUDED.last_practiced_at
+		RETURNING last_practiced_at
+	`
+	return r.db.QueryRow(
+		query,
+		userID, p.PatternID, p.Attempted, p.Solved, p.TotalAttempts,
+		p.AvgAttemptsPerSolve, p.LocalizationAccuracy, p.MasteryLevel,
+	).Scan(&p.LastPracticedAt)
+}
+
+func (r *ProgressRepository) GetByUser(userID string) ([]model.PatternProgress, error) {
+	query := `
+		SELECT p.pattern_id, c.name, p.snippets_attempted, p.snippets_solved,
+		       p.total_attempts, p.avg_attempts_per_solve, p.localization_accuracy,
+		       p.mastery_level, p.last_practiced_at
+		FROM user_pattern_progress p
+		JOIN pattern_categories c ON c.id = p.pattern_id
+		WHERE p.user_id = $1
+		ORDER BY c.order_index
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []model.PatternProgress{}
+	for rows.Next() {
+		var p model.PatternProgress
+		if err := rows.Scan(
+			&p.PatternID, &p.PatternName, &p.Attempted, &p.Solved,
+			&p.TotalAttempts, &p.AvgAttemptsPerSolve, &p.LocalizationAccuracy,
+			&p.MasteryLevel, &p.LastPracticedAt,
+		); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
+
+// GetBugTypeAccuracy returns quiz accuracy per bug type the user was asked about
+func (r *ProgressRepository) GetBugTypeAccuracy(userID string) ([]model.BugTypeProgress, error) {
+	query := `
+		SELECT b.id, b.slug, b.name,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE q.is_correct),
+		       AVG(CASE WHEN q.is_correct THEN 1.0 ELSE 0.0 END)
+		FROM bug_type_quiz_answers q
+		JOIN bug_types b ON b.id = q.correct_bug_type_id
+		WHERE q.user_id = $1
+		GROUP BY b.id, b.slug, b.name
+		ORDER BY b.name
+	`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	progress := []model.BugTypeProgress{}
+	for rows.Next() {
+		var p model.BugTypeProgress
+		if err := rows.Scan(&p.BugTypeID, &p.Slug, &p.Name, &p.Answered, &p.Correct, &p.Accuracy); err != nil {
+			return nil, err
+		}
+		progress = append(progress, p)
+	}
+	return progress, rows.Err()
+}
@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"fmt"
+
 	"github.com/bugdrill/backend/internal/database"
 	"github.com/bugdrill/backend/internal/model"
 )
@@ -13,20 +15,38 @@ func NewSnippetRepository(db *database.DB) *SnippetRepository {
 	return &SnippetRepository{db: db}
 }
 
+// GetByID returns an active snippet
 func (r *SnippetRepository) GetByID(id string) (*model.Snippet, error) {
+	return r.getByID(id, true)
+}
+
+// GetByIDAnyStatus returns a snippet whatever its status, for admins
+// reviewing drafts such as pending_review mutants
+func (r *SnippetRepository) GetByIDAnyStatus(id string) (*model.Snippet, error) {
+	return r.getByID(id, false)
+}
+
+func (r *SnippetRepository) getByID(id string, activeOnly bool) (*model.Snippet, error) {
 	query := `
 		SELECT id, pattern_id, title, description, difficulty, language,
-		       correct_code, buggy_code, bug_type, bug_explanation,
-		       test_cases, hint_1, hint_2, hint_3,
+		       correct_code, buggy_code, files, bug_type, bug_type_id, bug_explanation,
+		       test_cases, fuzz_spec, perf_spec, edit_policy, limits,
+		       test_format, test_code, go_test, sql_spec, stdio, require_localization,
+		       hint_1, hint_2, hint_3,
 		       created_by, status, created_at, updated_at
 		FROM snippets
-		WHERE id = $1 AND status = 'active'
+		WHERE id = $1
 	`
+	if activeOnly {
+		query += ` AND status = 'active'`
+	}
 	var s model.Snippet
 	err := r.db.QueryRow(query, id).Scan(
 		&s.ID, &s.PatternID, &s.Title, &s.Description, &s.Difficulty, &s.Language,
-		&s.CorrectCode, &s.BuggyCode, &s.BugType, &s.BugExplanation,
-		&s.TestCases, &s.Hint1, &s.Hint2, &s.Hint3,
+		&s.CorrectCode, &s.BuggyCode, &s.Files, &s.BugType, &s.BugTypeID, &s.BugExplanation,
+		&s.TestCases, &s.FuzzSpec, &s.PerfSpec, &s.EditPolicy, &s.Limits,
+		&s.TestFormat, &s.TestCode, &s.GoTest, &s.SQLSpec, &s.Stdio, &s.RequireLocalization,
+		&s.Hint1, &s.Hint2, &s.Hint3,
 		&s.CreatedBy, &s.Status, &s.CreatedAt, &s.UpdatedAt,
 	)
 	if err != nil {
@@ -35,18 +55,30 @@ func (r *SnippetRepository) GetByID(id string) (*model.Snippet, error) {
 	return &s, nil
 }
 
-func (r *SnippetRepository) GetByPatternID(patternID int, difficulty string) ([]model.Snippet, error) {
+// GetByPatternID lists a pattern's active snippets. bugType filters by a
+// bug type slug, matching the type itself or any type in that category;
+// callers decide whose bug types a viewer may filter by.
+func (r *SnippetRepository) GetByPatternID(patternID int, difficulty, bugType string) ([]model.Snippet, error) {
 	query := `
 		SELECT id, pattern_id, title, description, difficulty, language,
-		       bug_type, created_at
+		       bug_type, bug_type_id, created_by, created_at
 		FROM snippets
 		WHERE pattern_id = $1 AND status = 'active'
 	`
 	args := []interface{}{patternID}
 
 	if difficulty != "" {
-		query += ` AND difficulty = $2`
 		args = append(args, difficulty)
+		query += fmt.Sprintf(` AND difficulty = $%d`, len(args))
+	}
+
+	if bugType != "" {
+		args = append(args, bugType)
+		query += fmt.Sprintf(` AND bug_type_id IN (
+			SELECT b.id FROM bug_types b
+			LEFT JOIN bug_types p ON p.id = b.parent_id
+			WHERE b.slug = $%d OR p.slug = $%d
+		)`, len(args), len(args))
 	}
 
 	query += ` ORDER BY difficulty, title`
@@ -60,7 +92,7 @@ func (r *SnippetRepository) GetByPatternID(patternID int, difficulty string) ([]
 	var snippets []model.Snippet
 	for rows.Next() {
 		var s model.Snippet
-		if err := rows.Scan(&s.ID, &s.PatternID, &s.Title, &s.Description, &s.Difficulty, &s.Language, &s.BugType, &s.CreatedAt); err != nil {
+		if err := rows.Scan(&s.ID, &s.PatternID, &s.Title, &s.Description, &s.Difficulty, &s.Language, &s.BugType, &s.BugTypeID, &s.CreatedBy, &s.CreatedAt); err != nil {
 			return nil, err
 		}
 		snippets = append(snippets, s)
@@ -68,20 +100,39 @@ func (r *SnippetRepository) GetByPatternID(patternID int, difficulty string) ([]
 	return snippets, rows.Err()
 }
 
+// UpdateStatus moves a snippet from one status to another. It reports false
+// when the snippet is not in status from.
+func (r *SnippetRepository) UpdateStatus(id, from, to string) (bool, error) {
+	query := `
+		UPDATE snippets SET status = $3, updated_at = NOW()
+		WHERE id = $1 AND status = $2
+	`
+	res, err := r.db.Exec(query, id, from, to)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
 func (r *SnippetRepository) Create(snippet *model.Snippet) error {
 	query := `
 		INSERT INTO snippets (
 			id, pattern_id, title, description, difficulty, language,
-			correct_code, buggy_code, bug_type, bug_explanation,
-			test_cases, hint_1, hint_2, hint_3, created_by, status
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+			correct_code, buggy_code, files, bug_type, bug_type_id, bug_explanation,
+			test_cases, fuzz_spec, perf_spec, edit_policy, limits,
+			test_format, test_code, go_test, sql_spec, stdio, require_localization,
+			hint_1, hint_2, hint_3, created_by, status
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28)
 		RETURNING created_at, updated_at
 	`
 	return r.db.QueryRow(
 		query,
 		snippet.ID, snippet.PatternID, snippet.Title, snippet.Description,
-		snippet.Difficulty, snippet.Language, snippet.CorrectCode, snippet.BuggyCode,
-		snippet.BugType, snippet.BugExplanation, snippet.TestCases,
+		snippet.Difficulty, snippet.Language, snippet.CorrectCode, snippet.BuggyCode, snippet.Files,
+		snippet.BugType, snippet.BugTypeID, snippet.BugExplanation,
+		snippet.TestCases, snippet.FuzzSpec, snippet.PerfSpec, snippet.EditPolicy, snippet.Limits,
+		snippet.TestFormat, snippet.TestCode, snippet.GoTest, snippet.SQLSpec, snippet.Stdio, snippet.RequireLocalization,
 		snippet.Hint1, snippet.Hint2, snippet.Hint3, snippet.CreatedBy, snippet.Status,
 	).Scan(&snippet.CreatedAt, &snippet.UpdatedAt)
 }
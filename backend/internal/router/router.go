@@ -13,6 +13,12 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// maxRequestBytes caps every request body, including admin snippet uploads
+	maxRequestBytes = 1 << 20
+	codeBodyFactor  = 4
+)
+
 func SetupRouter(cfg *config.Config, db *database.DB, redis *redis.Client) *gin.Engine {
 	if cfg.Server.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -23,6 +29,7 @@ func SetupRouter(cfg *config.Config, db *database.DB, redis *redis.Client) *gin.
 	// Middleware
 	r.Use(middleware.CORS())
 	r.Use(middleware.RequestID())
+	r.Use(middleware.BodyLimit(maxRequestBytes))
 
 	// Health check
 	r.GET("/health", func(c *gin.Context) {
@@ -48,15 +55,29 @@ func SetupRouter(cfg *config.Config, db *database.DB, redis *redis.Client) *gin.
 	userRepo := repository.NewUserRepository(db)
 	patternRepo := repository.NewPatternRepository(db)
 	snippetRepo := repository.NewSnippetRepository(db)
+	attemptRepo := repository.NewAttemptRepository(db)
+	progressRepo := repository.NewProgressRepository(db)
+	bugTypeRepo := repository.NewBugTypeRepository(db)
 
 	// Initialize services
-	executorService := service.NewExecutorService()
+	executorService := service.NewExecutorService(cfg.Executor)
 	authService := service.NewAuthService(cfg, userRepo, redis)
-	snippetService := service.NewSnippetService(snippetRepo, patternRepo, redis, executorService)
+	snippetService := service.NewSnippetService(cfg, snippetRepo, patternRepo, redis, executorService)
+	progressService := service.NewProgressService(attemptRepo, progressRepo)
+	quizService := service.NewQuizService(bugTypeRepo, redis)
+	mutationService := service.NewMutationService(snippetService, snippetRepo, bugTypeRepo)
+	draftService := service.NewDraftService(cfg, redis)
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(authService)
-	snippetHandler := handler.NewSnippetHandler(snippetService)
+	snippetHandler := handler.NewSnippetHandler(snippetService, progressService, draftService)
+	quizHandler := handler.NewQuizHandler(snippetService, quizService)
+	mutationHandler := handler.NewMutationHandler(snippetService, mutationService)
+	draftHandler := handler.NewDraftHandler(snippetService, draftService)
+
+	// Code travels JSON-escaped, which can grow it, so code routes allow a
+	// multiple of MaxSnippetSize; the code itself is checked exactly later
+	codeLimit := middleware.BodyLimit(int64(codeBodyFactor * cfg.App.MaxSnippetSize))
 
 	// API routes
 	v1 := r.Group("/api/v1")
@@ -83,9 +104,27 @@ func SetupRouter(cfg *config.Config, db *database.DB, redis *redis.Client) *gin.
 
 			// Snippets
 			protected.GET("/snippets/:id", snippetHandler.GetSnippet)
-			protected.POST("/snippets/:id/execute", snippetHandler.ExecuteCode)
-			protected.POST("/snippets/:id/submit", snippetHandler.SubmitSolution)
+			protected.POST("/snippets/:id/execute", codeLimit, snippetHandler.ExecuteCode)
+			protected.POST("/snippets/:id/submit", codeLimit, snippetHandler.SubmitSolution)
+			protected.POST("/snippets/:id/trace", codeLimit, snippetHandler.TraceCode)
+			protected.POST("/snippets/:id/run-custom", codeLimit, snippetHandler.RunCustom)
+			protected.POST("/snippets/:id/localize", snippetHandler.LocalizeBug)
 			protected.POST("/snippets/:id/hints/:tier", snippetHandler.GetHint)
+			protected.POST("/snippets/:id/give-up", snippetHandler.GiveUp)
+			protected.POST("/snippets/:id/reveal", snippetHandler.RevealSolution)
+
+			// Editor
+			protected.POST("/diagnostics", codeLimit, snippetHandler.Diagnose)
+
+			// Drafts
+			protected.GET("/snippets/:id/draft", draftHandler.GetDraft)
+			protected.PUT("/snippets/:id/draft", codeLimit, draftHandler.SaveDraft)
+			protected.DELETE("/snippets/:id/draft", draftHandler.DeleteDraft)
+
+			// Bug type quiz
+			protected.GET("/bug-types", quizHandler.ListBugTypes)
+			protected.GET("/snippets/:id/bug-quiz", quizHandler.GetQuiz)
+			protected.POST("/snippets/:id/bug-quiz", quizHandler.AnswerQuiz)
 
 			// Progress
 			protected.GET("/users/progress", snippetHandler.GetUserProgress)
@@ -96,8 +135,12 @@ func SetupRouter(cfg *config.Config, db *database.DB, redis *redis.Client) *gin.
 		admin.Use(middleware.AuthMiddleware(cfg))
 		admin.Use(middleware.AdminMiddleware())
 		{
+			admin.GET("/snippets/:id", snippetHandler.GetSnippetAdmin)
 			admin.POST("/snippets", snippetHandler.CreateSnippet)
 			admin.PUT("/snippets/:id", snippetHandler.UpdateSnippet)
+			admin.GET("/snippets/:id/coverage", snippetHandler.GetCoverage)
+			admin.POST("/snippets/:id/mutants", mutationHandler.GenerateMutants)
+			admin.POST("/snippets/:id/review", mutationHandler.ReviewDraft)
 		}
 	}
 
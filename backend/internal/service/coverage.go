@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+var ErrCoverageUnsupported = errors.New("coverage is only available for python function and program snippets and go tests")
+
+// executorCoverage is the executor's account of a run: the lines that ran,
+// and those that could have
+type executorCoverage struct {
+	Covered    []int `json:"covered"`
+	Executable []int `json:"executable"`
+}
+
+// supportsCoverage reports whether the snippet's runner collects coverage;
+// pytest runs the code in a process of its own, and SQL has no lines to run
+func supportsCoverage(snippet *model.Snippet) bool {
+	switch snippet.TestFormat {
+	case model.TestFormatCases, model.TestFormatStdio:
+		return snippet.Language == "python"
+	case model.TestFormatGoTest:
+		return true
+	}
+	return false
+}
+
+// lineCoverage keeps the lines up to maxLine, which leaves out the harness
+// appended to the code, and works out which were missed. A maxLine of 0
+// keeps every line.
+func lineCoverage(c *executorCoverage, maxLine int) *model.Coverage {
+	if c == nil {
+		return nil
+	}
+	covered := map[int]bool{}
+	result := &model.Coverage{Covered: []int{}, Uncovered: []int{}}
+	for _, line := range c.Covered {
+		if maxLine == 0 || line <= maxLine {
+			covered[line] = true
+			result.Covered = append(result.Covered, line)
+		}
+	}
+	for _, line := range c.Executable {
+		if (maxLine == 0 || line <= maxLine) && !covered[line] {
+			result.Uncovered = append(result.Uncovered, line)
+		}
+	}
+	return result
+}
+
+// mergeCoverage is the coverage of the test cases together: a line is
+// covered if any case covered it
+func mergeCoverage(results []model.TestResult) *model.Coverage {
+	covered, missed := map[int]bool{}, map[int]bool{}
+	for _, r := range results {
+		if r.Coverage == nil {
+			continue
+		}
+		for _, line := range r.Coverage.Covered {
+			covered[line] = true
+		}
+		for _, line := range r.Coverage.Uncovered {
+			missed[line] = true
+		}
+	}
+	if len(covered) == 0 && len(missed) == 0 {
+		return nil
+	}
+	for line := range covered {
+		delete(missed, line)
+	}
+	return &model.Coverage{Covered: sortedLines(covered), Uncovered: sortedLines(missed)}
+}
+
+// codeLines is the number of lines in code, past which a harness starts
+func codeLines(code string) int {
+	return strings.Count(strings.TrimRight(code, "\n"), "\n") + 1
+}
+
+// CorrectCodeCoverage runs CorrectCode through the snippet's tests and
+// reports the lines they never reach
+func (s *SnippetService) CorrectCodeCoverage(ctx context.Context, snippet *model.Snippet) (*model.CoverageReport, error) {
+	if !supportsCoverage(snippet) {
+		return nil, ErrCoverageUnsupported
+	}
+	files, err := submissionFiles(snippet, correctFiles(snippet), s.cfg.App.MaxSnippetSize)
+	if err != nil {
+		return nil, err
+	}
+
+	run, err := s.runTests(ctx, snippet, snippet.CorrectCode, snippet.Language, files, s.resolveLimits(snippet, false), true)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.CoverageReport{
+		SnippetID:     snippet.ID,
+		Coverage:      model.Coverage{Covered: []int{}, Uncovered: []int{}},
+		UncoveredCode: []model.SourceLine{},
+		Passed:        run.allPassed,
+	}
+	if run.coverage != nil {
+		report.Coverage = *run.coverage
+	}
+	lines := strings.Split(snippet.CorrectCode, "\n")
+	for _, n := range report.Uncovered {
+		if n <= len(lines) {
+			report.UncoveredCode = append(report.UncoveredCode, model.SourceLine{Line: n, Code: strings.TrimRight(lines[n-1], "\r")})
+		}
+	}
+	for _, r := range run.results {
+		if r.Coverage != nil {
+			report.Tests = append(report.Tests, model.CaseCoverage{TestCase: r.TestCase, Name: r.Name, Covered: r.Coverage.Covered})
+		}
+	}
+	return report, nil
+}
@@ -0,0 +1,83 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+func TestLineCoverage(t *testing.T) {
+	run := &executorCoverage{Covered: []int{1, 2, 5, 9}, Executable: []int{1, 2, 3, 5, 9, 10}}
+	tests := []struct {
+		name     string
+		coverage *executorCoverage
+		maxLine  int
+		want     *model.Coverage
+	}{
+		{"every line", run, 0, &model.Coverage{Covered: []int{1, 2, 5, 9}, Uncovered: []int{3, 10}}},
+		{"harness lines dropped", run, 5, &model.Coverage{Covered: []int{1, 2, 5}, Uncovered: []int{3}}},
+		{"nothing ran", &executorCoverage{Executable: []int{2}}, 0, &model.Coverage{Covered: []int{}, Uncovered: []int{2}}},
+		{"no coverage", nil, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lineCoverage(tt.coverage, tt.maxLine); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMergeCoverage(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []model.TestResult
+		want    *model.Coverage
+	}{
+		{
+			name: "a line covered by any case is covered",
+			results: []model.TestResult{
+				{Coverage: &model.Coverage{Covered: []int{1, 2}, Uncovered: []int{3, 4}}},
+				{Coverage: &model.Coverage{Covered: []int{1, 3}, Uncovered: []int{2, 4}}},
+			},
+			want: &model.Coverage{Covered: []int{1, 2, 3}, Uncovered: []int{4}},
+		},
+		{
+			name: "cases without coverage are skipped",
+			results: []model.TestResult{
+				{},
+				{Coverage: &model.Coverage{Covered: []int{2}, Uncovered: []int{}}},
+			},
+			want: &model.Coverage{Covered: []int{2}, Uncovered: []int{}},
+		},
+		{
+			name:    "no coverage at all",
+			results: []model.TestResult{{}, {}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := mergeCoverage(tt.results); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTestFileResultsCoverage(t *testing.T) {
+	resp := &ExecuteResponse{Success: true, TestResults: []TestResult{
+		{Name: "TestA", Passed: true, Coverage: &executorCoverage{Covered: []int{3}, Executable: []int{3, 4}}},
+		{Name: "TestA/sub", Passed: true},
+	}}
+	results, allPassed := testFileResults(resp)
+	if !allPassed {
+		t.Fatalf("expected every test to pass")
+	}
+	if want := (&model.Coverage{Covered: []int{3}, Uncovered: []int{4}}); !reflect.DeepEqual(results[0].Coverage, want) {
+		t.Fatalf("expected the test's own coverage %+v, got %+v", want, results[0].Coverage)
+	}
+	if results[1].Coverage != nil {
+		t.Fatalf("expected no coverage for a subtest, got %+v", results[1].Coverage)
+	}
+}
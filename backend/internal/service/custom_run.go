@@ -0,0 +1,241 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+var ErrInvalidCustomInput = errors.New("invalid custom input")
+
+// maxCustomInputs keeps one custom run to a single batch per side
+const maxCustomInputs = 10
+
+// SnippetParams is the snippet's parameter schema: the fuzz spec's
+// parameters when it has one, otherwise the reference solution's parameters
+// that the first test case names, typed by its values
+func SnippetParams(snippet *model.Snippet) []model.SnippetParam {
+	if snippet.FuzzSpec != nil && len(snippet.FuzzSpec.Params) > 0 {
+		params := make([]model.SnippetParam, len(snippet.FuzzSpec.Params))
+		for i, p := range snippet.FuzzSpec.Params {
+			params[i] = model.SnippetParam{Name: p.Name, Type: p.Type}
+		}
+		return params
+	}
+	if len(snippet.TestCases) == 0 {
+		return nil
+	}
+
+	// Arguments are passed positionally, in testCaseArgs order
+	input := snippet.TestCases[0].Input
+	names := functionParams(snippet.CorrectCode, snippet.Language)
+	legacy := !coversInput(names, input)
+	if legacy {
+		names = legacyParams
+	}
+	var params []model.SnippetParam
+	for _, name := range names {
+		v, ok := input[name]
+		if !ok && legacy {
+			continue
+		}
+		if !ok {
+			// The rest take their defaults
+			break
+		}
+		params = append(params, model.SnippetParam{Name: name, Type: inferParamType(v)})
+	}
+	return params
+}
+
+func inferParamType(v interface{}) string {
+	switch v.(type) {
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	default:
+		return "object"
+	}
+}
+
+// customArgs checks one learner input against the schema and orders its
+// values as the function's arguments
+func customArgs(params []model.SnippetParam, input map[string]interface{}) ([]interface{}, error) {
+	for name := range input {
+		if !hasParam(params, name) {
+			return nil, fmt.Errorf("%w: unknown parameter %q", ErrInvalidCustomInput, name)
+		}
+	}
+	args := make([]interface{}, len(params))
+	for i, p := range params {
+		v, ok := input[p.Name]
+		if !ok {
+			return nil, fmt.Errorf("%w: missing parameter %q", ErrInvalidCustomInput, p.Name)
+		}
+		if !matchesParamType(p.Type, v) {
+			return nil, fmt.Errorf("%w: parameter %q must be %s", ErrInvalidCustomInput, p.Name, p.Type)
+		}
+		args[i] = v
+	}
+	return args, nil
+}
+
+func hasParam(params []model.SnippetParam, name string) bool {
+	for _, p := range params {
+		if p.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesParamType(typ string, v interface{}) bool {
+	switch typ {
+	case "int":
+		return isWholeNumber(v)
+	case "float", "number":
+		_, ok := v.(float64)
+		return ok
+	case "bool":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "int_list":
+		list, ok := v.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range list {
+			if !isWholeNumber(item) {
+				return false
+			}
+		}
+		return true
+	case "string_list":
+		list, ok := v.([]interface{})
+		if !ok {
+			return false
+		}
+		for _, item := range list {
+			if _, ok := item.(string); !ok {
+				return false
+			}
+		}
+		return true
+	case "list":
+		_, ok := v.([]interface{})
+		return ok
+	case "object":
+		_, ok := v.(map[string]interface{})
+		return ok
+	}
+	return false
+}
+
+func isWholeNumber(v interface{}) bool {
+	f, ok := v.(float64)
+	return ok && f == math.Trunc(f) && !math.IsInf(f, 0)
+}
+
+// RunCustom runs req.Code and the reference solution on the learner's own
+// inputs. Only the reference's outputs are returned, never its code.
+func (s *SnippetService) RunCustom(ctx context.Context, snippet *model.Snippet, req *model.CustomRunRequest) (*model.CustomRunResult, error) {
+	if len(req.Code) > s.cfg.App.MaxSnippetSize {
+		return nil, ErrCodeTooLarge
+	}
+	if req.Language != "python" || req.Language != snippet.Language {
+		return nil, fmt.Errorf("%w: custom runs are not supported for %s", ErrInvalidCustomInput, req.Language)
+	}
+	if len(snippet.Files) > 0 {
+		return nil, fmt.Errorf("%w: custom runs are %v", ErrInvalidCustomInput, ErrMultiFileUnsupported)
+	}
+	if len(req.Inputs) > maxCustomInputs {
+		return nil, fmt.Errorf("%w: at most %d inputs per run", ErrInvalidCustomInput, maxCustomInputs)
+	}
+	params := SnippetParams(snippet)
+	if len(params) == 0 {
+		return nil, fmt.Errorf("%w: snippet has no parameter schema", ErrInvalidCustomInput)
+	}
+
+	inputs := make([][]interface{}, len(req.Inputs))
+	for i, input := range req.Inputs {
+		args, err := customArgs(params, input)
+		if err != nil {
+			return nil, fmt.Errorf("input %d: %w", i+1, err)
+		}
+		inputs[i] = args
+	}
+	limits := s.resolveLimits(snippet, req.IsTrial)
+	log.Printf("[CUSTOM] snippet=%s inputs=%d", snippet.ID, len(inputs))
+
+	// As with fuzzing, a crash part way through keeps the outputs before it
+	refOutputs, refErr := s.runFuzzBatch(ctx, snippet.CorrectCode, snippet.Language, inputs, limits)
+	if err := infrastructureError(ctx, refErr); err != nil {
+		return nil, err
+	}
+	if refErr != nil {
+		log.Printf("[ERROR] Custom reference run failed: %v", refErr)
+	}
+	userOutputs, userErr := s.runFuzzBatch(ctx, req.Code, req.Language, inputs, limits)
+	if err := infrastructureError(ctx, userErr); err != nil {
+		return nil, err
+	}
+
+	result := &model.CustomRunResult{
+		SnippetID: snippet.ID,
+		Params:    params,
+		Cases:     make([]model.CustomRunCase, len(inputs)),
+	}
+	for i := range inputs {
+		c := model.CustomRunCase{Input: req.Inputs[i]}
+		// The reference's own failure output could quote its source
+		c.Reference, c.ReferenceError = customOutput(refOutputs, i, "reference solution failed on this input")
+		learnerFailure := "no output for this input"
+		if userErr != nil {
+			// Keep the exception line, as a traceback ends with it
+			if line := lastLine(strings.TrimPrefix(userErr.Error(), "fuzz execution failed: ")); line != "" {
+				learnerFailure = line
+			}
+		}
+		c.Learner, c.LearnerError = customOutput(userOutputs, i, learnerFailure)
+		c.Match = c.LearnerError == "" && c.ReferenceError == "" && compareOutputs(refOutputs[i], userOutputs[i])
+		result.Cases[i] = c
+	}
+	return result, nil
+}
+
+// customOutput decodes output i of a batch, or explains why there is none
+func customOutput(outputs []string, i int, failure string) (interface{}, string) {
+	if i >= len(outputs) {
+		return nil, failure
+	}
+	if isFuzzError(outputs[i]) {
+		return nil, fmt.Sprint(decodeFuzzOutput(outputs[i]))
+	}
+	return decodeFuzzOutput(outputs[i]), ""
+}
+
+// infrastructureError picks out failures that aren't the code's fault: the
+// client going away or no executor to run it
+func infrastructureError(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	var unavailable *ExecutorUnavailableError
+	if ctx.Err() != nil || errors.As(err, &unavailable) {
+		return err
+	}
+	return nil
+}
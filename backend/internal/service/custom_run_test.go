@@ -0,0 +1,65 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+func TestSnippetParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		snippet model.Snippet
+		want    []model.SnippetParam
+	}{
+		{
+			name: "signature order",
+			snippet: model.Snippet{
+				Language:    "python",
+				CorrectCode: "def merge(intervals, gap):\n    return intervals\n",
+				TestCases:   model.TestCases{{Input: map[string]interface{}{"gap": 1.0, "intervals": []interface{}{}}}},
+			},
+			want: []model.SnippetParam{{Name: "intervals", Type: "list"}, {Name: "gap", Type: "number"}},
+		},
+		{
+			name: "defaults left out",
+			snippet: model.Snippet{
+				Language:    "python",
+				CorrectCode: "def find(s, sub, start=0):\n    return s.find(sub, start)\n",
+				TestCases:   model.TestCases{{Input: map[string]interface{}{"s": "abc", "sub": "b"}}},
+			},
+			want: []model.SnippetParam{{Name: "s", Type: "string"}, {Name: "sub", Type: "string"}},
+		},
+		{
+			name: "legacy names",
+			snippet: model.Snippet{
+				Language:    "python",
+				CorrectCode: "def two_sum(arr, goal):\n    return []\n",
+				TestCases:   model.TestCases{{Input: map[string]interface{}{"target": 9.0, "nums": []interface{}{2.0, 7.0}}}},
+			},
+			want: []model.SnippetParam{{Name: "nums", Type: "list"}, {Name: "target", Type: "number"}},
+		},
+		{
+			name: "fuzz spec",
+			snippet: model.Snippet{
+				Language:    "python",
+				CorrectCode: "def f(a, b):\n    return a\n",
+				FuzzSpec:    &model.FuzzSpec{Params: []model.FuzzParam{{Name: "b", Type: "int"}}},
+				TestCases:   model.TestCases{{Input: map[string]interface{}{"a": 1.0, "b": 2.0}}},
+			},
+			want: []model.SnippetParam{{Name: "b", Type: "int"}},
+		},
+		{
+			name:    "no test cases",
+			snippet: model.Snippet{Language: "python", CorrectCode: "def f(a):\n    return a\n"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SnippetParams(&tt.snippet); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+var ErrDiagnosticsUnsupported = errors.New("diagnostics are only available for python and go")
+
+// diagnosticsResponse is the executor's answer to a diagnostics request
+type diagnosticsResponse struct {
+	Diagnostics []model.Diagnostic `json:"diagnostics"`
+	Error       string             `json:"error,omitempty"`
+}
+
+// Diagnose checks code for syntax errors and, for Go, type errors and vet
+// findings, without running it. It is meant to be called as the learner
+// types, so it skips the sandbox and the result cache.
+func (s *SnippetService) Diagnose(ctx context.Context, req *model.DiagnosticsRequest) (*model.DiagnosticsResult, error) {
+	if len(req.Code) > s.cfg.App.MaxSnippetSize {
+		return nil, ErrCodeTooLarge
+	}
+	if req.Language != "python" && req.Language != "go" {
+		return nil, ErrDiagnosticsUnsupported
+	}
+	if len(req.Files) > maxSnippetFiles {
+		return nil, fmt.Errorf("%w: at most %d files", ErrInvalidFileEdit, maxSnippetFiles)
+	}
+	for _, f := range req.Files {
+		if !validFilePath(f.Path) || reservedFiles[req.Language][f.Path] {
+			return nil, fmt.Errorf("%w: invalid file path %q", ErrInvalidFileEdit, f.Path)
+		}
+		if len(f.Content) > s.cfg.App.MaxSnippetSize {
+			return nil, ErrCodeTooLarge
+		}
+	}
+
+	resp, err := s.executorService.Diagnose(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("diagnostics failed: %w", err)
+	}
+	if resp.Error != "" {
+		return nil, fmt.Errorf("diagnostics failed: %s", resp.Error)
+	}
+	result := &model.DiagnosticsResult{Diagnostics: resp.Diagnostics}
+	if result.Diagnostics == nil {
+		result.Diagnostics = []model.Diagnostic{}
+	}
+	return result, nil
+}
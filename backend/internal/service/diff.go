@@ -0,0 +1,200 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffOp is one step of an edit script turning a into b. For '=' and '-'
+// A indexes a; for '=' and '+' B indexes b. An insertion's A is the index in
+// a it lands before, and a deletion's B is the index in b it would sit at.
+type diffOp struct {
+	Kind byte
+	A, B int
+	Text string
+}
+
+// diffStrings computes a minimal edit script via longest common subsequence.
+// Snippets are capped by MaxSnippetSize, so the quadratic table stays small.
+func diffStrings(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	ops := make([]diffOp, 0, n+m)
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case a[i] == b[j]:
+			ops = append(ops, diffOp{Kind: '=', A: i, B: j, Text: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			ops = append(ops, diffOp{Kind: '-', A: i, B: j, Text: a[i]})
+			i++
+		default:
+			ops = append(ops, diffOp{Kind: '+', A: i, B: j, Text: b[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		ops = append(ops, diffOp{Kind: '-', A: i, B: m, Text: a[i]})
+	}
+	for ; j < m; j++ {
+		ops = append(ops, diffOp{Kind: '+', A: n, B: j, Text: b[j]})
+	}
+	return ops
+}
+
+// editDistance counts inserted and deleted elements
+func editDistance(ops []diffOp) int {
+	count := 0
+	for _, op := range ops {
+		if op.Kind != '=' {
+			count++
+		}
+	}
+	return count
+}
+
+// splitLines splits code into lines, ignoring trailing whitespace and a
+// trailing newline so editor noise doesn't count as an edit
+func splitLines(code string) []string {
+	code = strings.ReplaceAll(code, "\r\n", "\n")
+	lines := strings.Split(strings.TrimRight(code, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return lines
+}
+
+// touchedLines maps an edit script onto the 1-based lines of a it touches.
+// aLines gives the line of each element of a and numLines the line count of
+// a. A hunk that replaces elements touches their lines; a pure insertion
+// touches the lines on either side of where it lands.
+func touchedLines(ops []diffOp, aLines []int, numLines int) map[int]bool {
+	touched := make(map[int]bool)
+	mark := func(line int) {
+		if line >= 1 && line <= numLines {
+			touched[line] = true
+		}
+	}
+
+	for start := 0; start < len(ops); {
+		if ops[start].Kind == '=' {
+			start++
+			continue
+		}
+
+		end, replaced := start, false
+		for ; end < len(ops) && ops[end].Kind != '='; end++ {
+			if ops[end].Kind == '-' {
+				mark(aLines[ops[end].A])
+				replaced = true
+			}
+		}
+
+		if !replaced {
+			at := ops[start].A
+			if at < len(aLines) {
+				mark(aLines[at])
+			} else {
+				mark(numLines)
+			}
+			if at > 0 {
+				mark(aLines[at-1])
+			}
+		}
+		start = end
+	}
+	return touched
+}
+
+// lineNumbers returns the identity line mapping used for line-based diffs
+func lineNumbers(n int) []int {
+	lines := make([]int, n)
+	for i := range lines {
+		lines[i] = i + 1
+	}
+	return lines
+}
+
+// unifiedDiff renders the line diff of a and b in unified format with the
+// given number of context lines around each hunk
+func unifiedDiff(aName, bName, a, b string, context int) string {
+	ops := diffStrings(splitLines(a), splitLines(b))
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", aName, bName)
+
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == '=' {
+			i++
+			continue
+		}
+
+		// Grow the hunk until the changes are more than 2*context lines apart
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].Kind != '=' {
+				end++
+				continue
+			}
+			run := end
+			for run < len(ops) && ops[run].Kind == '=' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end += min(context, run-end)
+				break
+			}
+			end = run
+		}
+
+		var aStart, aCount, bStart, bCount int
+		aStart, bStart = ops[start].A+1, ops[start].B+1
+		var body strings.Builder
+		for _, op := range ops[start:end] {
+			switch op.Kind {
+			case '=':
+				aCount++
+				bCount++
+				body.WriteString(" " + op.Text + "\n")
+			case '-':
+				aCount++
+				body.WriteString("-" + op.Text + "\n")
+			case '+':
+				bCount++
+				body.WriteString("+" + op.Text + "\n")
+			}
+		}
+		// An empty side starts at the line before it, as in GNU diff
+		if aCount == 0 {
+			aStart--
+		}
+		if bCount == 0 {
+			bStart--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount)
+		sb.WriteString(body.String())
+		i = end
+	}
+	return sb.String()
+}
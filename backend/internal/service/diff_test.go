@@ -0,0 +1,135 @@
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	ten := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	fifteen := ten + "11\n12\n13\n14\n15\n"
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    "a\nb\nc\n",
+			b:    "a\nb\nc\n",
+			want: "--- a\n+++ b\n",
+		},
+		{
+			name: "one change with context",
+			a:    ten,
+			b:    strings.Replace(ten, "5\n", "five\n", 1),
+			want: "--- a\n+++ b\n@@ -2,7 +2,7 @@\n 2\n 3\n 4\n-5\n+five\n 6\n 7\n 8\n",
+		},
+		{
+			name: "distant changes make two hunks",
+			a:    fifteen,
+			b:    strings.Replace(strings.Replace(fifteen, "2\n", "two\n", 1), "14\n", "fourteen\n", 1),
+			want: "--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n@@ -11,5 +11,5 @@\n 11\n 12\n 13\n-14\n+fourteen\n 15\n",
+		},
+		{
+			name: "nearby changes share a hunk",
+			a:    ten,
+			b:    strings.Replace(strings.Replace(ten, "2\n", "two\n", 1), "8\n", "eight\n", 1),
+			want: "--- a\n+++ b\n@@ -1,10 +1,10 @@\n 1\n-2\n+two\n 3\n 4\n 5\n 6\n 7\n-8\n+eight\n 9\n 10\n",
+		},
+		{
+			name: "insertion at the start",
+			a:    "b\nc\n",
+			b:    "a\nb\nc\n",
+			want: "--- a\n+++ b\n@@ -1,2 +1,3 @@\n+a\n b\n c\n",
+		},
+		{
+			name: "deletion at the end",
+			a:    "a\nb\nc\n",
+			b:    "a\nb\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,2 @@\n a\n b\n-c\n",
+		},
+		{
+			name: "pure insertion starts the empty side at the line before",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n",
+			want: "--- a\n+++ b\n@@ -6,3 +6,4 @@\n 6\n 7\n 8\n+9\n",
+		},
+		{
+			name: "line endings and trailing whitespace are not changes",
+			a:    "a  \r\nb\r\n",
+			b:    "a\nb",
+			want: "--- a\n+++ b\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("a", "b", tt.a, tt.b, revealDiffContext); got != tt.want {
+				t.Fatalf("expected\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDiffStrings(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []string
+		distance int
+	}{
+		{"equal", []string{"a", "b"}, []string{"a", "b"}, 0},
+		{"replace", []string{"a", "b", "c"}, []string{"a", "x", "c"}, 2},
+		{"insert", []string{"a", "c"}, []string{"a", "b", "c"}, 1},
+		{"delete", []string{"a", "b", "c"}, []string{"a", "c"}, 1},
+		{"from empty", nil, []string{"a", "b"}, 2},
+		{"to empty", []string{"a", "b"}, nil, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops := diffStrings(tt.a, tt.b)
+			if got := editDistance(ops); got != tt.distance {
+				t.Fatalf("expected distance %d, got %d", tt.distance, got)
+			}
+			// Replaying the script must give back both sides
+			var a, b []string
+			for _, op := range ops {
+				if op.Kind != '+' {
+					a = append(a, op.Text)
+				}
+				if op.Kind != '-' {
+					b = append(b, op.Text)
+				}
+			}
+			if strings.Join(a, "\n") != strings.Join(tt.a, "\n") || strings.Join(b, "\n") != strings.Join(tt.b, "\n") {
+				t.Fatalf("script %v does not turn %v into %v", ops, tt.a, tt.b)
+			}
+		})
+	}
+}
+
+func TestTouchedLines(t *testing.T) {
+	buggy := "a\nb\nc\nd\n"
+	tests := []struct {
+		name    string
+		correct string
+		want    []int
+	}{
+		{"unchanged", buggy, []int{}},
+		{"replaced line", "a\nB\nc\nd\n", []int{2}},
+		{"replaced lines", "a\nB\nC\nd\n", []int{2, 3}},
+		{"insertion touches both neighbours", "a\nb\nx\nc\nd\n", []int{2, 3}},
+		{"insertion at the start", "x\na\nb\nc\nd\n", []int{1}},
+		{"insertion at the end", "a\nb\nc\nd\nx\n", []int{4}},
+		{"deletion", "a\nc\nd\n", []int{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, b := lineUnits(buggy), lineUnits(tt.correct)
+			got := sortedLines(touchedLines(diffStrings(a.units, b.units), a.lines, a.numLines))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
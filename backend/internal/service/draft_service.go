@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/bugdrill/backend/internal/config"
+	"github.com/bugdrill/backend/internal/model"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrDraftConflict = errors.New("draft was changed on another device")
+	ErrDraftTooLarge = errors.New("draft exceeds the maximum snippet size")
+)
+
+type DraftService struct {
+	cfg   *config.Config
+	redis *redis.Client
+}
+
+func NewDraftService(cfg *config.Config, redis *redis.Client) *DraftService {
+	return &DraftService{
+		cfg:   cfg,
+		redis: redis,
+	}
+}
+
+func draftKey(userID, snippetID string) string {
+	return fmt.Sprintf("draft:%s:%s", userID, snippetID)
+}
+
+// draftVersionKey counts a user's draft saves. It never expires, so a
+// version is never handed out twice, even after a draft expires or is
+// deleted and a stale device still holds its version.
+func draftVersionKey(userID string) string {
+	return fmt.Sprintf("draft-version:%s", userID)
+}
+
+// GetDraft returns nil when there is no draft. Reading counts as activity,
+// so it pushes back the expiry.
+func (s *DraftService) GetDraft(userID, snippetID string) (*model.Draft, error) {
+	ctx := context.Background()
+	key := draftKey(userID, snippetID)
+
+	draft, err := s.load(ctx, s.redis, key)
+	if err != nil || draft == nil {
+		return draft, err
+	}
+	s.redis.Expire(ctx, key, s.cfg.App.DraftTTL)
+	return draft, nil
+}
+
+// SaveDraft stores code, and the edited files of a multi-file snippet, if
+// req.Version matches the stored version. On a mismatch it returns
+// ErrDraftConflict along with the stored draft.
+func (s *DraftService) SaveDraft(userID string, snippet *model.Snippet, req *model.SaveDraftRequest) (*model.Draft, error) {
+	if len(req.Code) > s.cfg.App.MaxSnippetSize {
+		return nil, ErrDraftTooLarge
+	}
+	// Files are held to the rules of a submission
+	if _, err := submissionFiles(snippet, req.Files, s.cfg.App.MaxSnippetSize); err != nil {
+		if errors.Is(err, ErrCodeTooLarge) {
+			return nil, ErrDraftTooLarge
+		}
+		return nil, err
+	}
+
+	ctx := context.Background()
+	snippetID := snippet.ID
+	key := draftKey(userID, snippetID)
+
+	var saved, current *model.Draft
+	err := s.redis.Watch(ctx, func(tx *redis.Tx) error {
+		var err error
+		current, err = s.load(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+
+		version := 0
+		if current != nil {
+			version = current.Version
+		}
+		if req.Version != version {
+			return ErrDraftConflict
+		}
+
+		next, err := tx.Incr(ctx, draftVersionKey(userID)).Result()
+		if err != nil {
+			return err
+		}
+		saved = &model.Draft{
+			SnippetID: snippetID,
+			Code:      req.Code,
+			Files:     req.Files,
+			Version:   int(next),
+			UpdatedAt: time.Now(),
+		}
+		data, err := json.Marshal(saved)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, s.cfg.App.DraftTTL)
+			return nil
+		})
+		return err
+	}, key)
+
+	// Losing the race to a concurrent save is a conflict too
+	if errors.Is(err, redis.TxFailedErr) {
+		current, _ = s.load(ctx, s.redis, key)
+		err = ErrDraftConflict
+	}
+	if errors.Is(err, ErrDraftConflict) {
+		return current, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+func (s *DraftService) DeleteDraft(userID, snippetID string) error {
+	return s.redis.Del(context.Background(), draftKey(userID, snippetID)).Err()
+}
+
+func (s *DraftService) load(ctx context.Context, client redis.Cmdable, key string) (*model.Draft, error) {
+	data, err := client.Get(ctx, key).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var draft model.Draft
+	if err := json.Unmarshal([]byte(data), &draft); err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
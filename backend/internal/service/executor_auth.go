@@ -0,0 +1,29 @@
+package service
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/bugdrill/backend/internal/execauth"
+)
+
+// sign adds the signature headers to req and returns the nonce the response
+// must be signed over
+func (s *ExecutorService) sign(req *http.Request, body []byte) (string, error) {
+	nonce, err := execauth.NewNonce()
+	if err != nil {
+		return "", err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(execauth.HeaderTimestamp, timestamp)
+	req.Header.Set(execauth.HeaderNonce, nonce)
+	req.Header.Set(execauth.HeaderSignature, execauth.SignRequest(s.secret, req.Method, req.URL.Path, timestamp, nonce, body))
+	return nonce, nil
+}
+
+// verify checks that a response was signed by an executor holding the secret
+func (s *ExecutorService) verify(resp *http.Response, nonce string, body []byte) bool {
+	return execauth.VerifyResponse(s.secret, nonce, body, resp.Header.Get(execauth.HeaderSignature))
+}
@@ -0,0 +1,177 @@
+package service
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// healthTimeout bounds a single health probe
+const healthTimeout = 5 * time.Second
+
+type executorOutcome int
+
+const (
+	outcomeSuccess executorOutcome = iota
+	outcomeFailure
+	// outcomeNeutral says nothing about the instance's health, e.g. a
+	// cancelled call or a rejected signature
+	outcomeNeutral
+)
+
+// executorBackend is one executor instance with its circuit breaker. The
+// breaker opens after breakerThreshold consecutive failures; once the
+// cooldown is over a single trial call is let through, which closes it on
+// success and reopens it on failure.
+type executorBackend struct {
+	url string
+
+	mu        sync.Mutex
+	healthy   bool
+	inflight  int
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+// pick reserves the healthy instance with the fewest calls in flight,
+// preferring ones not tried yet for this run. With none available it returns
+// how long until one might be.
+func (s *ExecutorService) pick(tried map[*executorBackend]bool) (*executorBackend, time.Duration) {
+	now := time.Now()
+	var best *executorBackend
+	bestScore, wait := 0, time.Duration(0)
+
+	for _, b := range s.backends {
+		b.mu.Lock()
+		available, bWait := s.available(b, now)
+		if available {
+			score := b.inflight
+			if tried[b] {
+				score += 1 << 20
+			}
+			if best == nil || score < bestScore {
+				best, bestScore = b, score
+			}
+		} else if wait == 0 || bWait < wait {
+			wait = bWait
+		}
+		b.mu.Unlock()
+	}
+
+	if best == nil {
+		return nil, max(wait, time.Second)
+	}
+
+	best.mu.Lock()
+	best.inflight++
+	if best.failures >= s.breakerThreshold {
+		best.probing = true
+	}
+	best.mu.Unlock()
+	return best, 0
+}
+
+// available must be called with b.mu held
+func (s *ExecutorService) available(b *executorBackend, now time.Time) (bool, time.Duration) {
+	if !b.healthy {
+		return false, s.healthInterval
+	}
+	if s.breakerThreshold <= 0 || b.failures < s.breakerThreshold {
+		return true, 0
+	}
+	if now.Before(b.openUntil) {
+		return false, b.openUntil.Sub(now)
+	}
+	if b.probing {
+		return false, time.Second
+	}
+	return true, 0
+}
+
+// release returns a reservation made by pick and feeds the breaker
+func (s *ExecutorService) release(b *executorBackend, outcome executorOutcome) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.inflight--
+	wasProbing := b.probing
+	b.probing = false
+
+	switch outcome {
+	case outcomeSuccess:
+		if b.failures >= s.breakerThreshold && s.breakerThreshold > 0 {
+			log.Printf("✅ Executor %s recovered, closing circuit", b.url)
+		}
+		b.failures = 0
+	case outcomeFailure:
+		b.failures++
+		if s.breakerThreshold > 0 && b.failures >= s.breakerThreshold {
+			if b.failures == s.breakerThreshold || wasProbing {
+				log.Printf("🔌 Executor %s failed %d times in a row, opening circuit for %s", b.url, b.failures, s.breakerCooldown)
+			}
+			b.openUntil = time.Now().Add(s.breakerCooldown)
+		}
+	}
+}
+
+// retryAfter is the shortest wait until any instance's breaker closes
+func (s *ExecutorService) retryAfter() time.Duration {
+	now := time.Now()
+	wait := time.Duration(0)
+	for _, b := range s.backends {
+		b.mu.Lock()
+		if b.openUntil.After(now) && (wait == 0 || b.openUntil.Sub(now) < wait) {
+			wait = b.openUntil.Sub(now)
+		}
+		b.mu.Unlock()
+	}
+	return max(wait, time.Second)
+}
+
+func (s *ExecutorService) watchHealth() {
+	ticker := time.NewTicker(s.healthInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		s.checkHealth()
+	}
+}
+
+// checkHealth probes /health on every instance in parallel
+func (s *ExecutorService) checkHealth() {
+	client := &http.Client{Timeout: healthTimeout}
+	var wg sync.WaitGroup
+	for _, b := range s.backends {
+		wg.Add(1)
+		go func(b *executorBackend) {
+			defer wg.Done()
+			healthy := false
+			resp, err := client.Get(b.url + "/health")
+			if err == nil {
+				healthy = resp.StatusCode == http.StatusOK
+				var health struct {
+					RunnerVersion string `json:"runner_version"`
+				}
+				if healthy && json.NewDecoder(io.LimitReader(resp.Body, maxExecutorResponseBytes)).Decode(&health) == nil {
+					s.noteRunnerVersion(health.RunnerVersion)
+				}
+				resp.Body.Close()
+			}
+
+			b.mu.Lock()
+			if healthy != b.healthy {
+				if healthy {
+					log.Printf("✅ Executor %s is healthy again", b.url)
+				} else {
+					log.Printf("❌ Executor %s failed its health check", b.url)
+				}
+			}
+			b.healthy = healthy
+			b.mu.Unlock()
+		}(b)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestPool(urls ...string) *ExecutorService {
+	s := &ExecutorService{breakerThreshold: 3, breakerCooldown: time.Minute, healthInterval: time.Second}
+	for _, url := range urls {
+		s.backends = append(s.backends, &executorBackend{url: url, healthy: true})
+	}
+	return s
+}
+
+// fail trips b's breaker with consecutive failures
+func fail(s *ExecutorService, b *executorBackend, times int) {
+	for i := 0; i < times; i++ {
+		b.mu.Lock()
+		b.inflight++
+		b.mu.Unlock()
+		s.release(b, outcomeFailure)
+	}
+}
+
+func TestPickPrefersIdleUntriedInstances(t *testing.T) {
+	s := newTestPool("a", "b", "c")
+	a, b, c := s.backends[0], s.backends[1], s.backends[2]
+	a.inflight, b.inflight, c.inflight = 2, 0, 1
+
+	got, _ := s.pick(nil)
+	if got != b {
+		t.Fatalf("expected the idle instance, got %s", got.url)
+	}
+	s.release(got, outcomeNeutral)
+
+	got, _ = s.pick(map[*executorBackend]bool{b: true})
+	if got != c {
+		t.Fatalf("expected the least busy untried instance, got %s", got.url)
+	}
+	s.release(got, outcomeNeutral)
+
+	got, _ = s.pick(map[*executorBackend]bool{a: true, b: true, c: true})
+	if got != b {
+		t.Fatalf("expected a retry on the least busy instance once all were tried, got %s", got.url)
+	}
+}
+
+func TestPickSkipsUnhealthyInstances(t *testing.T) {
+	s := newTestPool("a", "b")
+	s.backends[0].healthy = false
+
+	if got, _ := s.pick(nil); got != s.backends[1] {
+		t.Fatalf("expected the healthy instance, got %s", got.url)
+	}
+	s.backends[1].healthy = false
+	got, wait := s.pick(nil)
+	if got != nil {
+		t.Fatalf("expected no instance, got %s", got.url)
+	}
+	if wait != s.healthInterval {
+		t.Fatalf("expected to wait for the next health check, got %s", wait)
+	}
+}
+
+func TestBreaker(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		cooledDown   bool
+		probe        executorOutcome
+		wantOpen     bool
+		wantFailures int
+	}{
+		{name: "below threshold stays closed", failures: 2, wantFailures: 2},
+		{name: "threshold opens", failures: 3, wantOpen: true, wantFailures: 3},
+		{name: "probe success closes", failures: 3, cooledDown: true, probe: outcomeSuccess, wantFailures: 0},
+		{name: "probe failure reopens", failures: 3, cooledDown: true, probe: outcomeFailure, wantOpen: true, wantFailures: 4},
+		{name: "neutral probe leaves it half-open", failures: 3, cooledDown: true, probe: outcomeNeutral, wantFailures: 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestPool("a")
+			b := s.backends[0]
+			fail(s, b, tt.failures)
+
+			if tt.cooledDown {
+				b.openUntil = time.Now().Add(-time.Second)
+				probe, _ := s.pick(nil)
+				if probe != b || !b.probing {
+					t.Fatalf("expected a trial call once the cooldown is over")
+				}
+				// Only one trial call at a time
+				if other, _ := s.pick(nil); other != nil {
+					t.Fatalf("expected no second call while probing")
+				}
+				s.release(b, tt.probe)
+			}
+
+			got, wait := s.pick(nil)
+			if tt.wantOpen {
+				if got != nil {
+					t.Fatalf("expected the circuit to be open")
+				}
+				if wait <= 0 || wait > s.breakerCooldown {
+					t.Fatalf("expected to wait out the cooldown, got %s", wait)
+				}
+			} else if got != b {
+				t.Fatalf("expected the circuit to let calls through")
+			}
+			if b.failures != tt.wantFailures {
+				t.Fatalf("expected %d failures, got %d", tt.wantFailures, b.failures)
+			}
+		})
+	}
+}
+
+func TestBreakerDisabled(t *testing.T) {
+	s := newTestPool("a")
+	s.breakerThreshold = 0
+	fail(s, s.backends[0], 10)
+	if got, _ := s.pick(nil); got == nil {
+		t.Fatalf("expected calls to go through with the breaker disabled")
+	}
+}
+
+func TestRetryAfterIsShortestCooldown(t *testing.T) {
+	s := newTestPool("a", "b")
+	now := time.Now()
+	s.backends[0].openUntil = now.Add(30 * time.Second)
+	s.backends[1].openUntil = now.Add(10 * time.Second)
+
+	if wait := s.retryAfter(); wait > 10*time.Second || wait < 9*time.Second {
+		t.Fatalf("expected about 10s, got %s", wait)
+	}
+	s.backends[1].openUntil = now.Add(-time.Second)
+	s.backends[0].openUntil = now.Add(-time.Second)
+	if wait := s.retryAfter(); wait != time.Second {
+		t.Fatalf("expected the one second floor, got %s", wait)
+	}
+}
+
+func TestPickReleaseConcurrently(t *testing.T) {
+	s := newTestPool("a", "b", "c")
+	s.breakerThreshold = 1000
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				b, _ := s.pick(nil)
+				if b == nil {
+					t.Error("expected an instance")
+					return
+				}
+				outcome := outcomeSuccess
+				if (i+j)%3 == 0 {
+					outcome = outcomeFailure
+				}
+				s.release(b, outcome)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, b := range s.backends {
+		if b.inflight != 0 || b.probing {
+			t.Fatalf("%s: expected every reservation released, got inflight=%d probing=%v", b.url, b.inflight, b.probing)
+		}
+	}
+}
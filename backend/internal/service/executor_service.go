@@ -2,17 +2,71 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
-	"os"
+	"sync/atomic"
 	"time"
+
+	"github.com/bugdrill/backend/internal/config"
+	"github.com/bugdrill/backend/internal/model"
+)
+
+const (
+	// maxExecutorResponseBytes bounds what is read back from the executor,
+	// which truncates stdout and stderr well below this
+	maxExecutorResponseBytes = 4 << 20
+	// retryBackoff is the wait before the first retry; it doubles after that
+	retryBackoff = 200 * time.Millisecond
+	// diagnosticsBudget is the longest the executor spends checking code,
+	// which is its go vet timeout
+	diagnosticsBudget = 30 * time.Second
+	// policyTrusted exempts the API's own scripts, which only parse the code
+	// they carry, from the executor's import and builtin policy
+	policyTrusted = "trusted"
 )
 
+// ExecutorService runs code on a pool of executor instances. Runs have no
+// side effects, so a run that fails for infrastructure reasons is retried,
+// preferably on another instance.
 type ExecutorService struct {
-	baseURL string
-	client  *http.Client
+	backends []*executorBackend
+	client   *http.Client
+	// overhead is allowed on top of each run's own time limit, and
+	// goBuildTimeout on top of that for compiling Go
+	overhead       time.Duration
+	goBuildTimeout time.Duration
+	// secret signs requests to the executor and verifies its responses
+	secret  []byte
+	retries int
+
+	breakerThreshold int
+	breakerCooldown  time.Duration
+	healthInterval   time.Duration
+
+	// runnerVersion is the last runner version an instance reported
+	runnerVersion atomic.Value
+}
+
+// ExecutorUnavailableError means no executor instance could take the run.
+// RetryAfter is when one is expected to be back.
+type ExecutorUnavailableError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *ExecutorUnavailableError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("executor unavailable: %v", e.Err)
+	}
+	return "executor unavailable"
+}
+
+func (e *ExecutorUnavailableError) Unwrap() error {
+	return e.Err
 }
 
 type ExecuteRequest struct {
@@ -20,6 +74,25 @@ type ExecuteRequest struct {
 	Language   string   `json:"language"`
 	TestCases  []string `json:"test_cases,omitempty"`
 	TimeoutSec int      `json:"timeout_sec,omitempty"`
+	// Limits are enforced by the executor; TimeoutSec mirrors Limits.TimeoutSec
+	Limits *model.ExecutionLimits `json:"limits,omitempty"`
+	Policy string                 `json:"policy,omitempty"`
+	Trace  *TraceOptions          `json:"trace,omitempty"`
+	// TestCode is a test file to run against Code, per TestFormat
+	TestCode   string            `json:"test_code,omitempty"`
+	TestFormat string            `json:"test_format,omitempty"`
+	GoTest     *model.GoTestSpec `json:"go_test,omitempty"`
+	// Files are written next to Code for multi-file snippets
+	Files []model.FileContent `json:"files,omitempty"`
+	// SQL sets up the database a "sql" query runs against
+	SQL *model.SQLSpec `json:"sql,omitempty"`
+	// Stdin is streamed to the program
+	Stdin string `json:"stdin,omitempty"`
+	// Coverage asks which lines of Code ran
+	Coverage bool `json:"coverage,omitempty"`
+	// Harness is Python the executor runs next to Code, out of its reach;
+	// what it returns comes back in ExecuteResponse.Harness
+	Harness string `json:"harness,omitempty"`
 }
 
 type ExecuteResponse struct {
@@ -30,73 +103,258 @@ type ExecuteResponse struct {
 	ExecutionTime int          `json:"execution_time_ms"`
 	Error         string       `json:"error,omitempty"`
 	TestResults   []TestResult `json:"test_results,omitempty"`
+	// Limits are the limits the executor actually applied
+	Limits *model.ExecutionLimits `json:"limits,omitempty"`
+	Usage  *model.ResourceUsage   `json:"usage,omitempty"`
+	// PolicyViolations are set when the executor refused to run the code
+	PolicyViolations []model.PolicyViolation `json:"policy_violations,omitempty"`
+	Trace            *executorTrace          `json:"trace,omitempty"`
+	ResultSet        *model.ResultSet        `json:"result_set,omitempty"`
+	Coverage         *executorCoverage       `json:"coverage,omitempty"`
+	// Harness is what the request's harness returned, if the run got that far
+	Harness json.RawMessage `json:"harness,omitempty"`
+	// RunnerVersion identifies the images, scripts and policy that ran Code
+	RunnerVersion string `json:"runner_version,omitempty"`
 }
 
 type TestResult struct {
-	Input    string `json:"input"`
-	Expected string `json:"expected"`
-	Actual   string `json:"actual"`
-	Passed   bool   `json:"passed"`
+	Input     string            `json:"input"`
+	Expected  string            `json:"expected"`
+	Actual    string            `json:"actual"`
+	Passed    bool              `json:"passed"`
+	Name      string            `json:"name,omitempty"`
+	Output    string            `json:"output,omitempty"`
+	ElapsedMS int               `json:"elapsed_ms,omitempty"`
+	Errors    []model.TestError `json:"errors,omitempty"`
+	Coverage  *executorCoverage `json:"coverage,omitempty"`
 }
 
-func NewExecutorService() *ExecutorService {
-	baseURL := os.Getenv("EXECUTOR_URL")
-	if baseURL == "" {
-		baseURL = "http://localhost:8082" // Fallback for local development
+func NewExecutorService(cfg config.ExecutorConfig) *ExecutorService {
+	if cfg.Secret == "" {
+		log.Println("⚠️  EXECUTOR_SECRET is not set; executor requests are unsigned")
+	}
+
+	s := &ExecutorService{
+		// Each call gets its own deadline, see deadline
+		client:           &http.Client{},
+		overhead:         cfg.Timeout,
+		goBuildTimeout:   cfg.GoBuildTimeout,
+		secret:           []byte(cfg.Secret),
+		retries:          cfg.Retries,
+		breakerThreshold: cfg.BreakerThreshold,
+		breakerCooldown:  cfg.BreakerCooldown,
+		healthInterval:   cfg.HealthInterval,
+	}
+	for _, url := range cfg.URLs {
+		s.backends = append(s.backends, &executorBackend{url: url, healthy: true})
 	}
+	log.Printf("🔌 Executor pool: %v", cfg.URLs)
 
-	return &ExecutorService{
-		baseURL: baseURL,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+	if s.healthInterval > 0 {
+		go s.watchHealth()
 	}
+	return s
 }
 
-func (s *ExecutorService) Execute(req ExecuteRequest) (*ExecuteResponse, error) {
+// Execute runs req on the least busy healthy instance. Cancelling ctx aborts
+// the call, which makes the executor stop the run.
+func (s *ExecutorService) Execute(ctx context.Context, req ExecuteRequest) (*ExecuteResponse, error) {
 	// Default timeout
 	if req.TimeoutSec == 0 {
 		req.TimeoutSec = 10
 	}
 
+	var execResp ExecuteResponse
+	if err := s.post(ctx, "/execute", req, s.deadline(req), &execResp); err != nil {
+		return nil, err
+	}
+	s.noteRunnerVersion(execResp.RunnerVersion)
+	return &execResp, nil
+}
+
+// RunnerVersion identifies how the executors run code, as they last
+// reported it, or is empty before any has
+func (s *ExecutorService) RunnerVersion() string {
+	v, _ := s.runnerVersion.Load().(string)
+	return v
+}
+
+func (s *ExecutorService) noteRunnerVersion(v string) {
+	if v != "" && v != s.RunnerVersion() {
+		log.Printf("🔖 Executor runner version %s", v)
+		s.runnerVersion.Store(v)
+	}
+}
+
+// Diagnose asks an instance to check code without running it
+func (s *ExecutorService) Diagnose(ctx context.Context, req *model.DiagnosticsRequest) (*diagnosticsResponse, error) {
+	var resp diagnosticsResponse
+	if err := s.post(ctx, "/diagnostics", req, diagnosticsBudget+s.overhead, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// deadline is how long the executor may take to answer req: the run's own
+// time limit, doubled when Go reruns each test for its coverage, plus the Go
+// build and the overhead of starting the sandbox
+func (s *ExecutorService) deadline(req ExecuteRequest) time.Duration {
+	timeoutSec := req.TimeoutSec
+	if req.Limits != nil && req.Limits.TimeoutSec > 0 {
+		timeoutSec = req.Limits.TimeoutSec
+	}
+	run := time.Duration(timeoutSec) * time.Second
+	if req.Language == "go" {
+		if req.Coverage {
+			run *= 2
+		}
+		run += s.goBuildTimeout
+	}
+	return run + s.overhead
+}
+
+// post sends req to path on the least busy healthy instance and decodes the
+// response into out, retrying infrastructure failures. Each attempt gets
+// timeout to answer.
+func (s *ExecutorService) post(ctx context.Context, path string, req interface{}, timeout time.Duration, out interface{}) error {
 	jsonData, err := json.Marshal(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	tried := make(map[*executorBackend]bool)
+	var lastErr error
+	for attempt := 0; attempt <= s.retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(retryBackoff << (attempt - 1)):
+			}
+		}
+
+		backend, wait := s.pick(tried)
+		if backend == nil {
+			log.Printf("❌ No executor available, retry after %s", wait)
+			return &ExecutorUnavailableError{RetryAfter: wait, Err: lastErr}
+		}
+		tried[backend] = true
+
+		retryable, err := s.call(ctx, backend, path, jsonData, timeout, out)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if !retryable {
+			return err
+		}
+		lastErr = err
+		log.Printf("⚠️  Executor %s failed (attempt %d/%d): %v", backend.url, attempt+1, s.retries+1, err)
+	}
+
+	return &ExecutorUnavailableError{RetryAfter: s.retryAfter(), Err: lastErr}
+}
+
+// call makes one attempt against backend and reports whether a failure is
+// worth retrying elsewhere
+func (s *ExecutorService) call(ctx context.Context, backend *executorBackend, path string, jsonData []byte, timeout time.Duration, out interface{}) (bool, error) {
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	httpReq, err := http.NewRequestWithContext(callCtx, http.MethodPost, backend.url+path, bytes.NewReader(jsonData))
+	if err != nil {
+		s.release(backend, outcomeNeutral)
+		return false, fmt.Errorf("failed to build request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	var nonce string
+	if len(s.secret) > 0 {
+		if nonce, err = s.sign(httpReq, jsonData); err != nil {
+			s.release(backend, outcomeNeutral)
+			return false, fmt.Errorf("failed to sign request: %w", err)
+		}
 	}
 
-	resp, err := s.client.Post(
-		s.baseURL+"/execute",
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	resp, err := s.client.Do(httpReq)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call executor: %w", err)
+		if ctx.Err() != nil || callCtx.Err() != nil {
+			return s.callTimedOut(ctx, backend, timeout, err)
+		}
+		s.release(backend, outcomeFailure)
+		return true, fmt.Errorf("failed to call executor: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxExecutorResponseBytes))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		if ctx.Err() != nil || callCtx.Err() != nil {
+			return s.callTimedOut(ctx, backend, timeout, err)
+		}
+		s.release(backend, outcomeFailure)
+		return true, fmt.Errorf("failed to read response: %w", err)
 	}
 
-	var execResp ExecuteResponse
-	if err := json.Unmarshal(body, &execResp); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal response: %w", err)
+	if resp.StatusCode >= http.StatusInternalServerError {
+		s.release(backend, outcomeFailure)
+		return true, fmt.Errorf("executor returned status %d", resp.StatusCode)
+	}
+	// Rejections can come before the executor has checked the signature, so
+	// they carry none, and they are about the request rather than the instance
+	if resp.StatusCode >= http.StatusBadRequest {
+		s.release(backend, outcomeNeutral)
+		switch resp.StatusCode {
+		case http.StatusUnauthorized:
+			log.Println("❌ Executor rejected the request signature")
+			return false, fmt.Errorf("executor rejected request: unauthorized")
+		case http.StatusRequestEntityTooLarge:
+			return false, fmt.Errorf("%w: the executor refused a %d byte request", ErrCodeTooLarge, len(jsonData))
+		}
+		log.Printf("❌ Executor rejected the request (status %d): %s", resp.StatusCode, truncateForLog(string(body)))
+		return false, fmt.Errorf("executor rejected request (status %d)", resp.StatusCode)
+	}
+	if len(s.secret) > 0 && !s.verify(resp, nonce, body) {
+		s.release(backend, outcomeNeutral)
+		log.Printf("❌ Executor response failed signature verification (status %d)", resp.StatusCode)
+		return false, fmt.Errorf("executor response signature mismatch (status %d)", resp.StatusCode)
 	}
 
-	return &execResp, nil
+	if err := json.Unmarshal(body, out); err != nil {
+		s.release(backend, outcomeNeutral)
+		return false, fmt.Errorf("failed to unmarshal response: %w", err)
+	}
+
+	s.release(backend, outcomeSuccess)
+	return false, nil
 }
 
-func (s *ExecutorService) HealthCheck() error {
-	resp, err := s.client.Get(s.baseURL + "/health")
-	if err != nil {
-		return err
+// callTimedOut ends a call that was cancelled or outlasted its deadline.
+// Neither says anything about the instance, which health checks watch
+// instead, and a run that timed out would only time out again elsewhere.
+func (s *ExecutorService) callTimedOut(ctx context.Context, backend *executorBackend, timeout time.Duration, err error) (bool, error) {
+	s.release(backend, outcomeNeutral)
+	if ctx.Err() != nil {
+		return false, fmt.Errorf("failed to call executor: %w", err)
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("executor service unhealthy: %d", resp.StatusCode)
+	log.Printf("⏱️  Executor %s did not answer within %s", backend.url, timeout)
+	return false, &ExecutorUnavailableError{
+		RetryAfter: time.Second,
+		Err:        fmt.Errorf("no answer within %s: %w", timeout, err),
 	}
+}
 
-	return nil
+// HealthCheck probes every instance and fails if none is healthy
+func (s *ExecutorService) HealthCheck() error {
+	s.checkHealth()
+	for _, b := range s.backends {
+		b.mu.Lock()
+		healthy := b.healthy
+		b.mu.Unlock()
+		if healthy {
+			return nil
+		}
+	}
+	return fmt.Errorf("no healthy executor among %d", len(s.backends))
 }
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bugdrill/backend/internal/config"
+	"github.com/bugdrill/backend/internal/model"
+)
+
+func TestExecutorServiceStatuses(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		wantErr      error
+		wantCalls    int32
+		wantFailures int
+	}{
+		{name: "success", status: http.StatusOK, body: `{"success": true}`, wantCalls: 1},
+		{name: "server errors are retried and count against the instance", status: http.StatusBadGateway, wantCalls: 2, wantFailures: 2},
+		{name: "oversized request", status: http.StatusRequestEntityTooLarge, body: `{"error": "too large"}`, wantErr: ErrCodeTooLarge, wantCalls: 1},
+		{name: "unauthorized", status: http.StatusUnauthorized, wantCalls: 1},
+		{name: "bad request", status: http.StatusBadRequest, body: `{"error": "Invalid request"}`, wantCalls: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				calls.Add(1)
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			s := NewExecutorService(config.ExecutorConfig{URLs: []string{server.URL}, Timeout: 5 * time.Second, Retries: 1, BreakerThreshold: 5, BreakerCooldown: time.Minute})
+			resp, err := s.Execute(context.Background(), ExecuteRequest{Code: "print(1)", Language: "python"})
+
+			switch {
+			case tt.status == http.StatusOK:
+				if err != nil || !resp.Success {
+					t.Fatalf("expected a successful run, got %+v, %v", resp, err)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			case tt.status >= http.StatusInternalServerError:
+				var unavailable *ExecutorUnavailableError
+				if !errors.As(err, &unavailable) {
+					t.Fatalf("expected the executor to be unavailable, got %v", err)
+				}
+			default:
+				var unavailable *ExecutorUnavailableError
+				if err == nil || errors.As(err, &unavailable) {
+					t.Fatalf("expected a rejection, got %v", err)
+				}
+			}
+			if calls.Load() != tt.wantCalls {
+				t.Fatalf("expected %d calls, got %d", tt.wantCalls, calls.Load())
+			}
+			b := s.backends[0]
+			if b.failures != tt.wantFailures || b.inflight != 0 {
+				t.Fatalf("expected %d failures and nothing in flight, got %d and %d", tt.wantFailures, b.failures, b.inflight)
+			}
+		})
+	}
+}
+
+func TestExecutorServiceCancelIsNeutral(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	s := NewExecutorService(config.ExecutorConfig{URLs: []string{server.URL}, Timeout: 5 * time.Second, BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := s.Execute(ctx, ExecuteRequest{Code: "print(1)", Language: "python"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline, got %v", err)
+	}
+	if b := s.backends[0]; b.failures != 0 || b.inflight != 0 {
+		t.Fatalf("expected a cancelled call to leave the breaker alone, got %d failures", b.failures)
+	}
+}
+
+func TestExecutorServiceTimeoutIsNeutral(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	s := NewExecutorService(config.ExecutorConfig{URLs: []string{server.URL, server.URL}, Retries: 2, BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	var resp ExecuteResponse
+	err := s.post(context.Background(), "/execute", ExecuteRequest{Code: "print(1)", Language: "python"}, 50*time.Millisecond, &resp)
+
+	var unavailable *ExecutorUnavailableError
+	if !errors.As(err, &unavailable) {
+		t.Fatalf("expected the executor to be unavailable, got %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("expected a run that timed out not to be retried, got %d calls", calls.Load())
+	}
+	for _, b := range s.backends {
+		if b.failures != 0 || b.inflight != 0 {
+			t.Fatalf("expected a timeout to leave the breaker alone, got %d failures", b.failures)
+		}
+	}
+}
+
+func TestExecutorServiceDeadline(t *testing.T) {
+	s := &ExecutorService{overhead: 5 * time.Second, goBuildTimeout: time.Minute}
+	tests := []struct {
+		name string
+		req  ExecuteRequest
+		want time.Duration
+	}{
+		{"limits", ExecuteRequest{Language: "python", TimeoutSec: 10, Limits: &model.ExecutionLimits{TimeoutSec: 30}}, 35 * time.Second},
+		{"timeout without limits", ExecuteRequest{Language: "python", TimeoutSec: 10}, 15 * time.Second},
+		{"go build", ExecuteRequest{Language: "go", TimeoutSec: 30}, 95 * time.Second},
+		{"go coverage reruns", ExecuteRequest{Language: "go", TimeoutSec: 30, Coverage: true}, 125 * time.Second},
+		{"python coverage", ExecuteRequest{Language: "python", TimeoutSec: 30, Coverage: true}, 35 * time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := s.deadline(tt.req); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestExecutorServiceRunnerVersion(t *testing.T) {
+	version := "v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			w.Write([]byte(`{"status": "healthy", "runner_version": "` + version + `"}`))
+			return
+		}
+		w.Write([]byte(`{"success": true, "runner_version": "` + version + `"}`))
+	}))
+	defer server.Close()
+
+	s := NewExecutorService(config.ExecutorConfig{URLs: []string{server.URL}, Timeout: 5 * time.Second, BreakerThreshold: 5, BreakerCooldown: time.Minute})
+	if got := s.RunnerVersion(); got != "" {
+		t.Fatalf("expected no runner version before any was reported, got %q", got)
+	}
+	if err := s.HealthCheck(); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.RunnerVersion(); got != "v1" {
+		t.Fatalf("expected the version from the health check, got %q", got)
+	}
+
+	version = "v2"
+	resp, err := s.Execute(context.Background(), ExecuteRequest{Code: "print(1)", Language: "python"})
+	if err != nil || resp.RunnerVersion != "v2" {
+		t.Fatalf("expected a run reporting v2, got %+v, %v", resp, err)
+	}
+	if got := s.RunnerVersion(); got != "v2" {
+		t.Fatalf("expected the version from the latest run, got %q", got)
+	}
+}
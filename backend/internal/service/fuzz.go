@@ -0,0 +1,323 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+const (
+	defaultFuzzRuns     = 20
+	maxFuzzRuns         = 200
+	defaultFuzzMaxLen   = 10
+	defaultFuzzAlphabet = "abcdefghijklmnopqrstuvwxyz"
+	// defaultFuzzSpan separates a bound the spec leaves unset from the other
+	defaultFuzzSpan = 100
+	// maxUniqueTries bounds the draws for a unique string_list, whose
+	// alphabet and lengths may not allow enough distinct strings
+	maxUniqueTries   = 20
+	fuzzOutputPrefix = "__FUZZ__"
+)
+
+// validateFuzzSpec rejects specs the generator cannot satisfy
+func validateFuzzSpec(spec *model.FuzzSpec) error {
+	if spec.Runs < 0 || spec.Runs > maxFuzzRuns {
+		return fmt.Errorf("fuzz runs must be between 0 and %d", maxFuzzRuns)
+	}
+	if len(spec.Params) == 0 {
+		return fmt.Errorf("fuzz spec needs at least one param")
+	}
+	for _, p := range spec.Params {
+		if p.Name == "" {
+			return fmt.Errorf("fuzz param name is required")
+		}
+		switch p.Type {
+		case "int", "float", "bool", "string", "int_list", "string_list":
+		default:
+			return fmt.Errorf("fuzz param %s: unsupported type %q", p.Name, p.Type)
+		}
+		if min, max := fuzzRange(p); max < min {
+			return fmt.Errorf("fuzz param %s: max is less than min", p.Name)
+		}
+		if p.MinLen < 0 || (p.MaxLen != 0 && p.MaxLen < p.MinLen) {
+			return fmt.Errorf("fuzz param %s: invalid length range", p.Name)
+		}
+	}
+	return nil
+}
+
+// generateFuzzInputs builds spec.Runs positional argument lists. The same
+// seed always produces the same inputs.
+func generateFuzzInputs(spec *model.FuzzSpec, seed int64) [][]interface{} {
+	rng := rand.New(rand.NewSource(seed))
+
+	runs := spec.Runs
+	if runs == 0 {
+		runs = defaultFuzzRuns
+	}
+
+	inputs := make([][]interface{}, runs)
+	for i := range inputs {
+		args := make([]interface{}, len(spec.Params))
+		for j, p := range spec.Params {
+			args[j] = generateFuzzValue(rng, p)
+		}
+		inputs[i] = args
+	}
+	return inputs
+}
+
+func generateFuzzValue(rng *rand.Rand, p model.FuzzParam) interface{} {
+	switch p.Type {
+	case "int":
+		return fuzzInt(rng, p)
+	case "float":
+		min, max := fuzzRange(p)
+		return min + rng.Float64()*(max-min)
+	case "bool":
+		return rng.Intn(2) == 1
+	case "string":
+		return fuzzString(rng, p)
+	case "int_list":
+		n := fuzzLen(rng, p)
+		min, max := fuzzRange(p)
+		if span := int(max-min) + 1; p.Unique && n > span {
+			n = span
+		}
+
+		seen := make(map[int]bool, n)
+		list := make([]int, 0, n)
+		for len(list) < n {
+			v := fuzzInt(rng, p)
+			if p.Unique {
+				if seen[v] {
+					continue
+				}
+				seen[v] = true
+			}
+			list = append(list, v)
+		}
+		if p.Sorted {
+			sort.Ints(list)
+		}
+		return list
+	case "string_list":
+		n := fuzzLen(rng, p)
+		seen := make(map[string]bool, n)
+		list := make([]string, 0, n)
+		for tries := 0; len(list) < n && tries < n*maxUniqueTries; tries++ {
+			v := fuzzString(rng, p)
+			if p.Unique {
+				if seen[v] {
+					continue
+				}
+				seen[v] = true
+			}
+			list = append(list, v)
+		}
+		if p.Sorted {
+			sort.Strings(list)
+		}
+		return list
+	}
+	return nil
+}
+
+func fuzzString(rng *rand.Rand, p model.FuzzParam) string {
+	alphabet := p.Alphabet
+	if alphabet == "" {
+		alphabet = defaultFuzzAlphabet
+	}
+	runes := []rune(alphabet)
+	var sb strings.Builder
+	for n := fuzzLen(rng, p); n > 0; n-- {
+		sb.WriteRune(runes[rng.Intn(len(runes))])
+	}
+	return sb.String()
+}
+
+func fuzzInt(rng *rand.Rand, p model.FuzzParam) int {
+	min, max := fuzzRange(p)
+	return int(min) + rng.Intn(int(max-min)+1)
+}
+
+// fuzzRange fills in the bounds the spec leaves unset: one is
+// defaultFuzzSpan away from the other, and with neither the range is
+// [0, defaultFuzzSpan]
+func fuzzRange(p model.FuzzParam) (float64, float64) {
+	switch {
+	case p.Min != nil && p.Max != nil:
+		return *p.Min, *p.Max
+	case p.Min != nil:
+		return *p.Min, *p.Min + defaultFuzzSpan
+	case p.Max != nil:
+		return *p.Max - defaultFuzzSpan, *p.Max
+	}
+	return 0, defaultFuzzSpan
+}
+
+func fuzzLen(rng *rand.Rand, p model.FuzzParam) int {
+	maxLen := p.MaxLen
+	if maxLen == 0 {
+		maxLen = max(defaultFuzzMaxLen, p.MinLen)
+	}
+	return p.MinLen + rng.Intn(maxLen-p.MinLen+1)
+}
+
+// buildPythonFuzzHarness calls funcName once per input in a single process and
+// prints one prefixed JSON line per call, so user prints don't get mixed in
+func buildPythonFuzzHarness(userCode, funcName string, inputs [][]interface{}) (string, error) {
+	inputsJSON, err := json.Marshal(inputs)
+	if err != nil {
+		return "", err
+	}
+	// A JSON string literal is also a valid Python string literal
+	literal, err := json.Marshal(string(inputsJSON))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`%s
+
+# Fuzz harness
+import json
+for __args in json.loads(%s):
+    try:
+        __out = json.dumps(%s(*__args))
+    except Exception as __e:
+        __out = json.dumps({"__error__": repr(__e)})
+    print(%q + __out)
+`, userCode, literal, funcName, fuzzOutputPrefix), nil
+}
+
+// parseFuzzOutput returns the harness lines in call order
+func parseFuzzOutput(stdout string) []string {
+	var outputs []string
+	for _, line := range strings.Split(stdout, "\n") {
+		if strings.HasPrefix(line, fuzzOutputPrefix) {
+			outputs = append(outputs, strings.TrimPrefix(line, fuzzOutputPrefix))
+		}
+	}
+	return outputs
+}
+
+func isFuzzError(output string) bool {
+	return strings.HasPrefix(output, `{"__error__":`)
+}
+
+// runFuzz runs the reference and the submitted code on the same seeded inputs
+// and reports the first input where their outputs differ
+func (s *SnippetService) runFuzz(ctx context.Context, snippet *model.Snippet, code, language string, seed int64, limits model.ExecutionLimits) *model.FuzzResult {
+	spec := snippet.FuzzSpec
+	result := &model.FuzzResult{Seed: seed}
+
+	if language != "python" || language != snippet.Language {
+		result.Error = fmt.Sprintf("fuzz testing is not supported for %s", language)
+		return result
+	}
+	if err := validateFuzzSpec(spec); err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	inputs := generateFuzzInputs(spec, seed)
+	result.Runs = len(inputs)
+	log.Printf("[FUZZ] snippet=%s seed=%d runs=%d", snippet.ID, seed, len(inputs))
+
+	refOutputs, err := s.runFuzzBatch(ctx, snippet.CorrectCode, language, inputs, limits)
+	if err != nil {
+		log.Printf("[ERROR] Fuzz reference run failed: %v", err)
+		result.Error = "reference solution failed on generated inputs"
+		return result
+	}
+
+	// A crash or timeout part way through still yields the outputs produced
+	// before it, so the first missing output is the counterexample
+	userOutputs, userErr := s.runFuzzBatch(ctx, code, language, inputs, limits)
+	if userErr != nil && userOutputs == nil {
+		result.Error = userErr.Error()
+		return result
+	}
+
+	for i, args := range inputs {
+		// Inputs the reference rejects are outside the problem's domain
+		if isFuzzError(refOutputs[i]) {
+			continue
+		}
+		if i < len(userOutputs) && compareOutputs(refOutputs[i], userOutputs[i]) {
+			continue
+		}
+
+		input := make(map[string]interface{}, len(args))
+		for j, p := range spec.Params {
+			input[p.Name] = args[j]
+		}
+
+		var expected, actual interface{}
+		_ = json.Unmarshal([]byte(refOutputs[i]), &expected)
+		if i < len(userOutputs) {
+			actual = decodeFuzzOutput(userOutputs[i])
+		} else {
+			actual = userErr.Error()
+		}
+
+		result.Counterexample = &model.TestResult{
+			TestCase: i + 1,
+			Input:    input,
+			Expected: expected,
+			Actual:   actual,
+			Passed:   false,
+		}
+		log.Printf("[FUZZ] Counterexample at run %d (seed=%d)", i+1, seed)
+		return result
+	}
+
+	result.Passed = true
+	return result
+}
+
+func (s *SnippetService) runFuzzBatch(ctx context.Context, code, language string, inputs [][]interface{}, limits model.ExecutionLimits) ([]string, error) {
+	funcName := extractFunctionName(code, language)
+	harness, err := buildPythonFuzzHarness(code, funcName, inputs)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.executorService.Execute(ctx, execRequest(harness, language, limits))
+	if err != nil {
+		return nil, fmt.Errorf("fuzz execution failed: %w", err)
+	}
+
+	outputs := parseFuzzOutput(resp.Stdout)
+	if outputs == nil {
+		outputs = []string{}
+	}
+	if !resp.Success {
+		return outputs, fmt.Errorf("fuzz execution failed: %s", strings.TrimSpace(resp.Stderr))
+	}
+	if len(outputs) != len(inputs) {
+		return outputs, fmt.Errorf("fuzz execution produced %d of %d results", len(outputs), len(inputs))
+	}
+	return outputs, nil
+}
+
+// decodeFuzzOutput turns a harness line back into a value, surfacing raised
+// exceptions as their repr
+func decodeFuzzOutput(output string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(output), &v); err != nil {
+		return output
+	}
+	if m, ok := v.(map[string]interface{}); ok {
+		if e, ok := m["__error__"]; ok && len(m) == 1 {
+			return e
+		}
+	}
+	return v
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"math/rand"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+func bound(v float64) *float64 {
+	return &v
+}
+
+func TestValidateFuzzSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    model.FuzzSpec
+		wantErr string
+	}{
+		{"int", model.FuzzSpec{Params: []model.FuzzParam{{Name: "n", Type: "int", Min: bound(-5), Max: bound(5)}}}, ""},
+		{"string_list", model.FuzzSpec{Params: []model.FuzzParam{{Name: "words", Type: "string_list", Unique: true}}}, ""},
+		{"explicit zero bounds", model.FuzzSpec{Params: []model.FuzzParam{{Name: "n", Type: "int", Min: bound(0), Max: bound(0)}}}, ""},
+		{"only min above the default range", model.FuzzSpec{Params: []model.FuzzParam{{Name: "n", Type: "int", Min: bound(500)}}}, ""},
+		{"only max below the default range", model.FuzzSpec{Params: []model.FuzzParam{{Name: "n", Type: "float", Max: bound(-500)}}}, ""},
+		{"min_len above the default max", model.FuzzSpec{Params: []model.FuzzParam{{Name: "s", Type: "string", MinLen: 15}}}, ""},
+		{"too many runs", model.FuzzSpec{Runs: maxFuzzRuns + 1, Params: []model.FuzzParam{{Name: "n", Type: "int"}}}, "fuzz runs"},
+		{"negative runs", model.FuzzSpec{Runs: -1, Params: []model.FuzzParam{{Name: "n", Type: "int"}}}, "fuzz runs"},
+		{"no params", model.FuzzSpec{}, "at least one param"},
+		{"unnamed param", model.FuzzSpec{Params: []model.FuzzParam{{Type: "int"}}}, "name is required"},
+		{"unsupported type", model.FuzzSpec{Params: []model.FuzzParam{{Name: "m", Type: "dict"}}}, "unsupported type"},
+		{"max below min", model.FuzzSpec{Params: []model.FuzzParam{{Name: "n", Type: "int", Min: bound(5), Max: bound(0)}}}, "max is less than min"},
+		{"max_len below min_len", model.FuzzSpec{Params: []model.FuzzParam{{Name: "s", Type: "string", MinLen: 5, MaxLen: 2}}}, "length range"},
+		{"negative min_len", model.FuzzSpec{Params: []model.FuzzParam{{Name: "s", Type: "string", MinLen: -1}}}, "length range"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateFuzzSpec(&tt.spec)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected the spec to be valid, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected an error about %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFuzzRange(t *testing.T) {
+	tests := []struct {
+		name     string
+		param    model.FuzzParam
+		min, max float64
+	}{
+		{"both bounds", model.FuzzParam{Min: bound(-3), Max: bound(3)}, -3, 3},
+		{"explicit zeros", model.FuzzParam{Min: bound(0), Max: bound(0)}, 0, 0},
+		{"only min", model.FuzzParam{Min: bound(1000)}, 1000, 1000 + defaultFuzzSpan},
+		{"only max", model.FuzzParam{Max: bound(-1000)}, -1000 - defaultFuzzSpan, -1000},
+		{"neither", model.FuzzParam{}, 0, defaultFuzzSpan},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if min, max := fuzzRange(tt.param); min != tt.min || max != tt.max {
+				t.Fatalf("expected [%v, %v], got [%v, %v]", tt.min, tt.max, min, max)
+			}
+		})
+	}
+}
+
+func TestGenerateFuzzValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		param model.FuzzParam
+		check func(v interface{}) bool
+	}{
+		{"int in range", model.FuzzParam{Type: "int", Min: bound(-2), Max: bound(2)}, func(v interface{}) bool {
+			n := v.(int)
+			return n >= -2 && n <= 2
+		}},
+		{"zero range", model.FuzzParam{Type: "int", Min: bound(0), Max: bound(0)}, func(v interface{}) bool {
+			return v.(int) == 0
+		}},
+		{"float in range", model.FuzzParam{Type: "float", Min: bound(1.5), Max: bound(2.5)}, func(v interface{}) bool {
+			f := v.(float64)
+			return f >= 1.5 && f <= 2.5
+		}},
+		{"bool", model.FuzzParam{Type: "bool"}, func(v interface{}) bool {
+			_, ok := v.(bool)
+			return ok
+		}},
+		{"string from alphabet", model.FuzzParam{Type: "string", Alphabet: "ab", MinLen: 2, MaxLen: 4}, func(v interface{}) bool {
+			s := v.(string)
+			return len(s) >= 2 && len(s) <= 4 && strings.Trim(s, "ab") == ""
+		}},
+		{"string longer than the default max", model.FuzzParam{Type: "string", MinLen: 15}, func(v interface{}) bool {
+			return len(v.(string)) == 15
+		}},
+		{"sorted unique int_list", model.FuzzParam{Type: "int_list", Min: bound(0), Max: bound(50), MinLen: 5, MaxLen: 8, Sorted: true, Unique: true}, func(v interface{}) bool {
+			list := v.([]int)
+			if len(list) < 5 || len(list) > 8 || !sort.IntsAreSorted(list) {
+				return false
+			}
+			for i := 1; i < len(list); i++ {
+				if list[i] == list[i-1] {
+					return false
+				}
+			}
+			return true
+		}},
+		{"unique int_list capped by its range", model.FuzzParam{Type: "int_list", Min: bound(1), Max: bound(3), MinLen: 10, MaxLen: 10, Unique: true}, func(v interface{}) bool {
+			return len(v.([]int)) == 3
+		}},
+		{"sorted unique string_list", model.FuzzParam{Type: "string_list", Alphabet: "xyz", MinLen: 1, MaxLen: 3, Sorted: true, Unique: true}, func(v interface{}) bool {
+			list := v.([]string)
+			if len(list) > 3 || !sort.StringsAreSorted(list) {
+				return false
+			}
+			seen := map[string]bool{}
+			for _, s := range list {
+				if seen[s] || len(s) < 1 || len(s) > 3 || strings.Trim(s, "xyz") != "" {
+					return false
+				}
+				seen[s] = true
+			}
+			return true
+		}},
+		{"unique string_list with too few distinct strings", model.FuzzParam{Type: "string_list", Alphabet: "a", MinLen: 5, MaxLen: 5, Unique: true}, func(v interface{}) bool {
+			return reflect.DeepEqual(v, []string{"aaaaa"})
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rng := rand.New(rand.NewSource(1))
+			for i := 0; i < 200; i++ {
+				if v := generateFuzzValue(rng, tt.param); !tt.check(v) {
+					t.Fatalf("unexpected value %v", v)
+				}
+			}
+		})
+	}
+}
+
+func TestGenerateFuzzInputs(t *testing.T) {
+	spec := &model.FuzzSpec{Params: []model.FuzzParam{
+		{Name: "nums", Type: "int_list"},
+		{Name: "target", Type: "int"},
+	}}
+
+	first := generateFuzzInputs(spec, 42)
+	if len(first) != defaultFuzzRuns {
+		t.Fatalf("expected %d runs by default, got %d", defaultFuzzRuns, len(first))
+	}
+	for _, args := range first {
+		if len(args) != len(spec.Params) {
+			t.Fatalf("expected one argument per param, got %v", args)
+		}
+	}
+	if again := generateFuzzInputs(spec, 42); !reflect.DeepEqual(first, again) {
+		t.Fatalf("expected the same seed to give the same inputs")
+	}
+	if other := generateFuzzInputs(spec, 43); reflect.DeepEqual(first, other) {
+		t.Fatalf("expected another seed to give other inputs")
+	}
+}
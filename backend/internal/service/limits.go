@@ -0,0 +1,78 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+// validateLimits rejects negative limits and snippet overrides above the
+// configured maximum
+func validateLimits(limits, max *model.ExecutionLimits) error {
+	if limits.TimeoutSec < 0 || limits.MemoryMB < 0 || limits.CPUs < 0 || limits.OutputBytes < 0 || limits.Pids < 0 {
+		return fmt.Errorf("execution limits must not be negative")
+	}
+	if limits.TimeoutSec > max.TimeoutSec || limits.MemoryMB > max.MemoryMB || limits.CPUs > max.CPUs ||
+		limits.OutputBytes > max.OutputBytes || limits.Pids > max.Pids {
+		return fmt.Errorf("execution limits exceed the maximum of %+v", *max)
+	}
+	return nil
+}
+
+// resolveLimits layers the snippet's overrides on the global defaults. Trial
+// users are then capped at the trial plan's limits.
+func (s *SnippetService) resolveLimits(snippet *model.Snippet, isTrial bool) model.ExecutionLimits {
+	limits := s.cfg.App.Limits
+	if o := snippet.Limits; o != nil {
+		if o.TimeoutSec > 0 {
+			limits.TimeoutSec = o.TimeoutSec
+		}
+		if o.MemoryMB > 0 {
+			limits.MemoryMB = o.MemoryMB
+		}
+		if o.CPUs > 0 {
+			limits.CPUs = o.CPUs
+		}
+		if o.OutputBytes > 0 {
+			limits.OutputBytes = o.OutputBytes
+		}
+		if o.Pids > 0 {
+			limits.Pids = o.Pids
+		}
+	}
+	limits = capLimits(limits, s.cfg.App.MaxLimits)
+	if isTrial {
+		limits = capLimits(limits, s.cfg.App.TrialLimits)
+	}
+	return limits
+}
+
+// capLimits lowers each limit to its cap; a zero cap leaves it unbounded
+func capLimits(limits, caps model.ExecutionLimits) model.ExecutionLimits {
+	if caps.TimeoutSec > 0 && limits.TimeoutSec > caps.TimeoutSec {
+		limits.TimeoutSec = caps.TimeoutSec
+	}
+	if caps.MemoryMB > 0 && limits.MemoryMB > caps.MemoryMB {
+		limits.MemoryMB = caps.MemoryMB
+	}
+	if caps.CPUs > 0 && limits.CPUs > caps.CPUs {
+		limits.CPUs = caps.CPUs
+	}
+	if caps.OutputBytes > 0 && limits.OutputBytes > caps.OutputBytes {
+		limits.OutputBytes = caps.OutputBytes
+	}
+	if caps.Pids > 0 && limits.Pids > caps.Pids {
+		limits.Pids = caps.Pids
+	}
+	return limits
+}
+
+// execRequest builds an executor request that runs under limits
+func execRequest(code, language string, limits model.ExecutionLimits) ExecuteRequest {
+	return ExecuteRequest{
+		Code:       code,
+		Language:   language,
+		TimeoutSec: limits.TimeoutSec,
+		Limits:     &limits,
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+const (
+	// A guess within this many lines of the bug counts as near
+	nearLocalizationLines = 2
+	// An exact guess may be this many lines wider than the bug itself
+	exactLocalizationSlack = 2
+)
+
+var (
+	ErrAlreadyLocalized     = errors.New("bug already localized for this snippet")
+	ErrLocalizationRequired = errors.New("localize the bug before running a fix")
+	ErrInvalidLineRange     = errors.New("invalid line range")
+)
+
+// localizationAccuracy is what each result counts for towards mastery
+var localizationAccuracy = map[string]float64{"exact": 1, "near": 0.5, "wrong": 0}
+
+// bugRegion returns the BuggyCode lines that differ from CorrectCode
+func bugRegion(snippet *model.Snippet) []int {
+	buggy := lineUnits(snippet.BuggyCode)
+	ops := diffStrings(buggy.units, lineUnits(snippet.CorrectCode).units)
+	return sortedLines(touchedLines(ops, buggy.lines, buggy.numLines))
+}
+
+// Localize scores and records the learner's guess at the buggy lines. Only
+// the first guess counts, and it stays active until the snippet is solved.
+func (s *ProgressService) Localize(userID string, snippet *model.Snippet, req *model.LocalizeRequest) (*model.LocalizationResult, error) {
+	numLines := len(splitLines(snippet.BuggyCode))
+	if req.EndLine < req.StartLine || req.EndLine > numLines {
+		return nil, ErrInvalidLineRange
+	}
+
+	existing, err := s.GetLocalization(userID, snippet.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, ErrAlreadyLocalized
+	}
+
+	result := scoreLocalization(req.StartLine, req.EndLine, bugRegion(snippet))
+	result.CreatedAt = time.Now()
+
+	ok, err := s.attemptRepo.CreateLocalization(userID, snippet.ID, result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store localization: %w", err)
+	}
+	if !ok {
+		// Lost a race with a concurrent guess - that one counts
+		existing, err := s.GetLocalization(userID, snippet.ID)
+		if err != nil {
+			return nil, err
+		}
+		return existing, ErrAlreadyLocalized
+	}
+
+	log.Printf("[LOCALIZE] user=%s snippet=%s lines=%d-%d result=%s", userID, snippet.ID, req.StartLine, req.EndLine, result.Result)
+
+	if err := s.refreshPatternProgress(userID, snippet.PatternID); err != nil {
+		log.Printf("[WARN] Failed to refresh progress: %v", err)
+	}
+	return result, nil
+}
+
+// GetLocalization returns the active guess for a snippet, or nil if none
+func (s *ProgressService) GetLocalization(userID, snippetID string) (*model.LocalizationResult, error) {
+	result, err := s.attemptRepo.GetActiveLocalization(userID, snippetID)
+	if err != nil || result == nil {
+		return nil, err
+	}
+	result.Accuracy = localizationAccuracy[result.Result]
+	return result, nil
+}
+
+func (s *ProgressService) clearLocalization(userID, snippetID string) {
+	if err := s.attemptRepo.ClearLocalization(userID, snippetID); err != nil {
+		log.Printf("[WARN] Failed to clear localization: %v", err)
+	}
+}
+
+// scoreLocalization grades a guess against the bug region. Exact means the
+// guess includes a buggy line and is at most slightly wider than the bug;
+// near means it is somewhat wider or misses by a couple of lines.
+func scoreLocalization(start, end int, region []int) *model.LocalizationResult {
+	result := &model.LocalizationResult{StartLine: start, EndLine: end, Result: "wrong"}
+	if len(region) == 0 {
+		return result
+	}
+
+	regionStart, regionEnd := region[0], region[len(region)-1]
+	hit := false
+	for _, line := range region {
+		if line >= start && line <= end {
+			hit = true
+			break
+		}
+	}
+
+	gap := 0
+	if end < regionStart {
+		gap = regionStart - end
+	} else if start > regionEnd {
+		gap = start - regionEnd
+	}
+
+	// Widening the guess to cover the whole function never scores
+	extra := (end - start) - (regionEnd - regionStart)
+	switch {
+	case hit && extra <= exactLocalizationSlack:
+		result.Result = "exact"
+	case gap <= nearLocalizationLines && extra <= exactLocalizationSlack+2*nearLocalizationLines:
+		result.Result = "near"
+	}
+	result.Accuracy = localizationAccuracy[result.Result]
+	return result
+}
@@ -0,0 +1,313 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+const (
+	defaultEditPenalty = 50
+	// Thresholds never drop below these, so a one-token bug still leaves
+	// room for a slightly different but equally small fix
+	minLineEditDistance  = 3
+	minTokenEditDistance = 6
+	tokenOutputPrefix    = "__TOKENS__"
+	minimalEditHint      = "Try fixing the bug with a minimal edit."
+)
+
+// defaultEditPolicy warns about rewrites without affecting correctness
+var defaultEditPolicy = model.EditPolicy{Mode: "warn", Granularity: "line"}
+
+// validateEditPolicy rejects unknown modes and granularities
+func validateEditPolicy(policy *model.EditPolicy) error {
+	switch policy.Mode {
+	case "off", "warn", "penalize", "reject":
+	default:
+		return fmt.Errorf("unsupported edit policy mode %q", policy.Mode)
+	}
+	switch policy.Granularity {
+	case "", "line", "token":
+	default:
+		return fmt.Errorf("unsupported edit granularity %q", policy.Granularity)
+	}
+	if policy.MaxEditDistance < 0 || policy.Penalty < 0 || policy.Penalty > 100 {
+		return fmt.Errorf("edit policy limits out of range")
+	}
+	return nil
+}
+
+// editUnits is a sequence of diffable units and the source line of each
+type editUnits struct {
+	units    []string
+	lines    []int
+	numLines int
+}
+
+func lineUnits(code string) editUnits {
+	lines := splitLines(code)
+	return editUnits{units: lines, lines: lineNumbers(len(lines)), numLines: len(lines)}
+}
+
+// analyzeEdit measures how far code strays from BuggyCode and whether it
+// touches the lines where BuggyCode and CorrectCode differ. For multi-file
+// snippets files is the submitted tree, and each editable file counts too.
+func (s *SnippetService) analyzeEdit(ctx context.Context, snippet *model.Snippet, code, language string, files []model.FileContent) *model.EditResult {
+	policy := defaultEditPolicy
+	if snippet.EditPolicy != nil {
+		policy = *snippet.EditPolicy
+	}
+	if policy.Mode == "off" {
+		return nil
+	}
+
+	entry := s.compareEdit(ctx, language, policy.Granularity, snippet.BuggyCode, snippet.CorrectCode, code)
+	granularity := entry.granularity
+	refDistance, distance := entry.refDistance, entry.distance
+	hasRegion, touches := len(entry.region) > 0, entry.touchesRegion
+
+	submitted := make(map[string]string, len(files))
+	for _, f := range files {
+		submitted[f.Path] = f.Content
+	}
+	var fileResults []model.FileEditResult
+	for _, f := range snippet.Files {
+		if !f.Editable {
+			continue
+		}
+		// Files follow the entrypoint's granularity, so distances add up
+		c := s.compareEdit(ctx, language, granularity, f.Content, f.Correct(), submitted[f.Path])
+		refDistance += c.refDistance
+		distance += c.distance
+		hasRegion = hasRegion || len(c.region) > 0
+		touches = touches || c.touchesRegion
+		fileResults = append(fileResults, model.FileEditResult{
+			Path:             f.Path,
+			EditDistance:     c.distance,
+			ChangedLines:     c.changed,
+			RemovedLines:     c.removed,
+			BugRegion:        sortedLines(c.region),
+			TouchesBugRegion: c.touchesRegion,
+		})
+	}
+
+	maxDistance := policy.MaxEditDistance
+	if maxDistance == 0 {
+		maxDistance = 2 * refDistance
+		if granularity == "token" && maxDistance < minTokenEditDistance {
+			maxDistance = minTokenEditDistance
+		} else if granularity == "line" && maxDistance < minLineEditDistance {
+			maxDistance = minLineEditDistance
+		}
+	}
+
+	result := &model.EditResult{
+		Granularity:      granularity,
+		EditDistance:     distance,
+		MaxEditDistance:  maxDistance,
+		BugRegion:        sortedLines(entry.region),
+		RemovedLines:     entry.removed,
+		ChangedLines:     entry.changed,
+		TouchesBugRegion: touches || !hasRegion,
+		Action:           "none",
+		Files:            fileResults,
+	}
+
+	var reasons []string
+	if result.EditDistance > maxDistance {
+		reasons = append(reasons, fmt.Sprintf("changes %d %ss (limit %d)", result.EditDistance, granularity, maxDistance))
+	}
+	if !result.TouchesBugRegion && !policy.AllowOutsideBugRegion {
+		reasons = append(reasons, "leaves the buggy lines untouched")
+	}
+	if len(reasons) > 0 {
+		result.IsRewrite = true
+		result.Message = "This submission " + strings.Join(reasons, " and ") + ". " + minimalEditHint
+	}
+	return result
+}
+
+// editComparison is one file of a submission compared with its buggy and
+// fixed versions
+type editComparison struct {
+	granularity   string
+	refDistance   int
+	distance      int
+	region        map[int]bool
+	touchesRegion bool
+	removed       []int
+	changed       []int
+}
+
+// compareEdit diffs a submitted file against the buggy one, by tokens when
+// asked and they can be had, and otherwise by lines
+func (s *SnippetService) compareEdit(ctx context.Context, language, granularity, buggyCode, correctCode, code string) editComparison {
+	c := editComparison{granularity: "line"}
+	buggy, correct, submitted := lineUnits(buggyCode), lineUnits(correctCode), lineUnits(code)
+	if granularity == "token" {
+		tokens, err := s.tokenize(ctx, language, buggyCode, correctCode, code)
+		if err != nil {
+			// Unparseable submissions are still compared line by line
+			log.Printf("[EDIT] Token diff unavailable, falling back to lines: %v", err)
+		} else {
+			c.granularity = "token"
+			buggy, correct, submitted = tokens[0], tokens[1], tokens[2]
+		}
+	}
+
+	refOps := diffStrings(buggy.units, correct.units)
+	subOps := diffStrings(buggy.units, submitted.units)
+	c.refDistance, c.distance = editDistance(refOps), editDistance(subOps)
+	c.region = touchedLines(refOps, buggy.lines, buggy.numLines)
+	for line := range touchedLines(subOps, buggy.lines, buggy.numLines) {
+		if c.region[line] {
+			c.touchesRegion = true
+			break
+		}
+	}
+	c.removed = sortedLines(removedLines(subOps, buggy.lines))
+	c.changed = sortedLines(insertedLines(subOps, submitted.lines))
+	return c
+}
+
+// applyEditPolicy enforces the snippet's policy on a passing submission and
+// returns whether it still counts as correct
+func applyEditPolicy(snippet *model.Snippet, edit *model.EditResult) bool {
+	if edit == nil || !edit.IsRewrite {
+		return true
+	}
+
+	policy := defaultEditPolicy
+	if snippet.EditPolicy != nil {
+		policy = *snippet.EditPolicy
+	}
+
+	switch policy.Mode {
+	case "reject":
+		edit.Action = "rejected"
+		return false
+	case "penalize":
+		edit.Action = "penalized"
+		edit.Penalty = policy.Penalty
+		if edit.Penalty == 0 {
+			edit.Penalty = defaultEditPenalty
+		}
+	case "warn":
+		edit.Action = "warned"
+	}
+	return true
+}
+
+// tokenize lexes each source with Python's tokenize module in the sandbox.
+// Nothing is executed, and comments and blank lines are dropped so only
+// code changes count.
+func (s *SnippetService) tokenize(ctx context.Context, language string, sources ...string) ([]editUnits, error) {
+	if language != "python" {
+		return nil, fmt.Errorf("token diff is not supported for %s", language)
+	}
+
+	script, err := buildTokenizeScript(sources...)
+	if err != nil {
+		return nil, err
+	}
+
+	req := execRequest(script, "python", s.cfg.App.Limits)
+	req.Policy = policyTrusted
+	resp, err := s.executorService.Execute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("tokenize failed: %s", lastLine(resp.Stderr))
+	}
+
+	var parsed []struct {
+		Tokens [][2]interface{} `json:"tokens"`
+		Lines  int              `json:"lines"`
+	}
+	for _, line := range strings.Split(resp.Stdout, "\n") {
+		if strings.HasPrefix(line, tokenOutputPrefix) {
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, tokenOutputPrefix)), &parsed); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if len(parsed) != len(sources) {
+		return nil, fmt.Errorf("tokenize returned %d of %d sources", len(parsed), len(sources))
+	}
+
+	result := make([]editUnits, len(parsed))
+	for i, p := range parsed {
+		u := editUnits{numLines: p.Lines}
+		for _, tok := range p.Tokens {
+			text, _ := tok[0].(string)
+			line, _ := tok[1].(float64)
+			u.units = append(u.units, text)
+			u.lines = append(u.lines, int(line))
+		}
+		result[i] = u
+	}
+	return result, nil
+}
+
+// buildTokenizeScript lexes each source and prints the tokens with their
+// lines as one prefixed JSON line
+func buildTokenizeScript(sources ...string) (string, error) {
+	sourcesJSON, err := json.Marshal(sources)
+	if err != nil {
+		return "", err
+	}
+	literal, err := json.Marshal(string(sourcesJSON))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`import io, json, tokenize
+__skip = (tokenize.COMMENT, tokenize.NL, tokenize.ENCODING, tokenize.ENDMARKER)
+__names = {tokenize.INDENT: "<INDENT>", tokenize.DEDENT: "<DEDENT>", tokenize.NEWLINE: "<NEWLINE>"}
+__out = []
+for __src in json.loads(%s):
+    __toks = []
+    for __t in tokenize.generate_tokens(io.StringIO(__src).readline):
+        if __t.type not in __skip:
+            __toks.append([__names.get(__t.type, __t.string), __t.start[0]])
+    __out.append({"tokens": __toks, "lines": len(__src.splitlines())})
+print(%q + json.dumps(__out))
+`, literal, tokenOutputPrefix), nil
+}
+
+// removedLines returns the lines of a holding elements removed by ops
+func removedLines(ops []diffOp, aLines []int) map[int]bool {
+	lines := make(map[int]bool)
+	for _, op := range ops {
+		if op.Kind == '-' {
+			lines[aLines[op.A]] = true
+		}
+	}
+	return lines
+}
+
+// insertedLines returns the lines of b holding elements inserted by ops
+func insertedLines(ops []diffOp, bLines []int) map[int]bool {
+	lines := make(map[int]bool)
+	for _, op := range ops {
+		if op.Kind == '+' {
+			lines[bLines[op.B]] = true
+		}
+	}
+	return lines
+}
+
+func sortedLines(set map[int]bool) []int {
+	lines := make([]int, 0, len(set))
+	for line := range set {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"strings"
+)
+
+// goFuncPrelude lets snippets that hold only functions parse as a file
+const goFuncPrelude = "package snippet\n"
+
+// mutateGo applies the mutation operators to Go source with go/ast. Edits are
+// made on the original text, so formatting outside the mutated span is kept.
+func mutateGo(code string) ([]mutantSource, error) {
+	src, offset := code, 0
+	if !strings.HasPrefix(strings.TrimSpace(code), "package ") {
+		src, offset = goFuncPrelude+code, len(goFuncPrelude)
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "snippet.go", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	tf := fset.File(file.Pos())
+
+	var sources []mutantSource
+	pos := func(p token.Pos) int { return tf.Offset(p) }
+	text := func(n ast.Node) string { return src[pos(n.Pos()):pos(n.End())] }
+	atom := func(n ast.Expr) string {
+		switch n.(type) {
+		case *ast.Ident, *ast.BasicLit, *ast.SelectorExpr, *ast.IndexExpr, *ast.CallExpr, *ast.ParenExpr:
+			return text(n)
+		}
+		return "(" + text(n) + ")"
+	}
+	add := func(op string, start, end int, repl string) {
+		orig := src[start:end]
+		if orig == repl {
+			return
+		}
+		mutated := src[:start] + repl + src[end:]
+		sources = append(sources, mutantSource{
+			Operator:    op,
+			Line:        tf.Line(tf.Pos(start)) - strings.Count(src[:offset], "\n"),
+			Original:    strings.TrimSpace(orig),
+			Replacement: strings.TrimSpace(repl),
+			Code:        mutated[offset:],
+		})
+	}
+	isOne := func(n ast.Expr) bool {
+		lit, ok := n.(*ast.BasicLit)
+		return ok && lit.Kind == token.INT && lit.Value == "1"
+	}
+
+	// early_return moves a return that follows a loop to the end of the loop body
+	earlyReturns := func(stmts []ast.Stmt) {
+		for i := 0; i+1 < len(stmts); i++ {
+			ret, ok := stmts[i+1].(*ast.ReturnStmt)
+			if !ok || tf.Line(ret.Pos()) != tf.Line(ret.End()) {
+				continue
+			}
+			var body *ast.BlockStmt
+			switch loop := stmts[i].(type) {
+			case *ast.ForStmt:
+				body = loop.Body
+			case *ast.RangeStmt:
+				body = loop.Body
+			}
+			if body == nil || len(body.List) == 0 {
+				continue
+			}
+			last := body.List[len(body.List)-1]
+			lineStart := strings.LastIndex(src[:pos(last.Pos())], "\n") + 1
+			indent := src[lineStart:pos(last.Pos())]
+			if strings.TrimSpace(indent) != "" {
+				continue
+			}
+			end := pos(last.End())
+			mutated := src[:end] + "\n" + indent + text(ret) + src[end:pos(stmts[i].End())] + src[pos(ret.End()):]
+			sources = append(sources, mutantSource{
+				Operator:    "early_return",
+				Line:        tf.Line(ret.Pos()) - strings.Count(src[:offset], "\n"),
+				Original:    text(ret),
+				Replacement: text(ret),
+				Code:        mutated[offset:],
+			})
+		}
+	}
+
+	ast.Inspect(file, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.BlockStmt:
+			earlyReturns(n.List)
+		case *ast.CaseClause:
+			earlyReturns(n.Body)
+		case *ast.SliceExpr:
+			if n.High != nil {
+				for _, d := range []string{" + 1", " - 1"} {
+					add("range_bound", pos(n.High.Pos()), pos(n.High.End()), atom(n.High)+d)
+				}
+			}
+		case *ast.RangeStmt:
+			// Ranging over an integer literal or expression, as in Go 1.22+
+			if _, ok := n.X.(*ast.BinaryExpr); ok || isIntLit(n.X) {
+				for _, d := range []string{" + 1", " - 1"} {
+					add("range_bound", pos(n.X.Pos()), pos(n.X.End()), atom(n.X)+d)
+				}
+			}
+		case *ast.BinaryExpr:
+			opStart := pos(n.OpPos)
+			switch n.Op {
+			case token.LSS:
+				add("compare_boundary", opStart, opStart+1, "<=")
+			case token.LEQ:
+				add("compare_boundary", opStart, opStart+2, "<")
+			case token.GTR:
+				add("compare_boundary", opStart, opStart+1, ">=")
+			case token.GEQ:
+				add("compare_boundary", opStart, opStart+2, ">")
+			case token.SUB, token.QUO, token.REM:
+				if text(n.X) != text(n.Y) {
+					add("swapped_operands", pos(n.Pos()), pos(n.End()), atom(n.Y)+" "+n.Op.String()+" "+atom(n.X))
+				}
+			}
+			if (n.Op == token.ADD || n.Op == token.SUB) && isOne(n.Y) {
+				add("dropped_plus_one", pos(n.Pos()), pos(n.End()), atom(n.X))
+			}
+		case *ast.AssignStmt:
+			if n.Tok == token.DEFINE || n.Tok == token.ASSIGN {
+				for _, rhs := range n.Rhs {
+					addInitialValue(add, pos, rhs)
+				}
+			}
+		case *ast.ValueSpec:
+			for _, v := range n.Values {
+				addInitialValue(add, pos, v)
+			}
+		}
+		return true
+	})
+	return sources, nil
+}
+
+// addInitialValue proposes the neighbours of an integer literal, including
+// negated ones
+func addInitialValue(add func(op string, start, end int, repl string), pos func(token.Pos) int, n ast.Expr) {
+	sign, start := 1, pos(n.Pos())
+	if u, ok := n.(*ast.UnaryExpr); ok && u.Op == token.SUB {
+		sign, n = -1, u.X
+	}
+	lit, ok := n.(*ast.BasicLit)
+	if !ok || lit.Kind != token.INT {
+		return
+	}
+	value, err := strconv.Atoi(lit.Value)
+	if err != nil {
+		return
+	}
+	value *= sign
+
+	end := pos(lit.End())
+	for _, d := range []int{1, -1} {
+		add("wrong_initial_value", start, end, strconv.Itoa(value+d))
+	}
+}
+
+func isIntLit(n ast.Expr) bool {
+	lit, ok := n.(*ast.BasicLit)
+	return ok && lit.Kind == token.INT
+}
@@ -0,0 +1,467 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/bugdrill/backend/internal/model"
+	"github.com/bugdrill/backend/internal/repository"
+	"github.com/google/uuid"
+)
+
+const (
+	maxMutants         = 20
+	mutantWorkers      = 4
+	mutantOutputPrefix = "__MUTANTS__"
+	mutantDraftStatus  = "pending_review"
+	// A reviewed draft goes live when approved and is archived otherwise
+	approvedStatus = "active"
+	rejectedStatus = "archived"
+)
+
+var (
+	// ErrNoTestCases is returned when a snippet has nothing to kill mutants with
+	ErrNoTestCases = errors.New("snippet has no test cases")
+	// ErrNotPendingReview is returned when reviewing a snippet that is not
+	// awaiting review
+	ErrNotPendingReview = errors.New("snippet is not pending review")
+)
+
+// mutationOperators maps each operator to the bug type its mutants get and
+// the first hint shown to learners
+var mutationOperators = map[string]struct {
+	Slug string
+	Hint string
+}{
+	"range_bound":         {"range-end", "Check where each range or slice stops."},
+	"compare_boundary":    {"inclusive-loop-bound", "Check whether each comparison should include its boundary."},
+	"swapped_operands":    {"swapped-operands", "Check the order of the operands in each subtraction, division and modulo."},
+	"dropped_plus_one":    {"index-shift", "Check whether an index or length is off by one."},
+	"wrong_initial_value": {"wrong-initial-value", "Check the value each variable starts from."},
+	"early_return":        {"early-return", "Check whether the function returns before it has seen every candidate."},
+}
+
+// mutantSource is one mutated copy of CorrectCode
+type mutantSource struct {
+	Operator    string `json:"operator"`
+	Line        int    `json:"line"`
+	Original    string `json:"original"`
+	Replacement string `json:"replacement"`
+	Code        string `json:"code"`
+}
+
+type MutationService struct {
+	snippetService *SnippetService
+	snippetRepo    *repository.SnippetRepository
+	bugTypeRepo    *repository.BugTypeRepository
+}
+
+func NewMutationService(
+	snippetService *SnippetService,
+	snippetRepo *repository.SnippetRepository,
+	bugTypeRepo *repository.BugTypeRepository,
+) *MutationService {
+	return &MutationService{
+		snippetService: snippetService,
+		snippetRepo:    snippetRepo,
+		bugTypeRepo:    bugTypeRepo,
+	}
+}
+
+// GenerateMutants mutates the snippet's CorrectCode, runs every mutant
+// against its test cases and saves the killed ones as pending_review drafts
+// created by authorID
+func (s *MutationService) GenerateMutants(ctx context.Context, snippet *model.Snippet, authorID string) (*model.MutationReport, error) {
+	if len(snippet.TestCases) == 0 && snippet.TestCode == "" {
+		return nil, ErrNoTestCases
+	}
+
+	if len(snippet.Files) > 0 {
+		return nil, fmt.Errorf("%w: mutation is %v", ErrInvalidSnippet, ErrMultiFileUnsupported)
+	}
+
+	report := &model.MutationReport{SnippetID: snippet.ID, Mutants: []model.Mutant{}}
+
+	var sources []mutantSource
+	var err error
+	switch snippet.Language {
+	case "python":
+		sources, err = s.mutatePython(ctx, snippet.CorrectCode)
+	case "go":
+		sources, err = mutateGo(snippet.CorrectCode)
+	default:
+		return nil, fmt.Errorf("%w: mutation is not supported for %s", ErrInvalidSnippet, snippet.Language)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: correct_code could not be mutated: %v", ErrInvalidSnippet, err)
+	}
+	sources = dedupeMutants(sources, snippet)
+	report.Generated = len(sources)
+
+	killed, err := s.killMutants(ctx, snippet, sources)
+	if err != nil {
+		report.Error = err.Error()
+	}
+
+	for i, src := range sources {
+		mutant := model.Mutant{
+			Operator:    src.Operator,
+			Line:        src.Line,
+			Original:    src.Original,
+			Replacement: src.Replacement,
+		}
+		if killed != nil {
+			mutant.Killed = killed[i].killed
+			mutant.Error = killed[i].err
+		}
+
+		switch {
+		case mutant.Killed:
+			draft, err := s.createDraft(snippet, src, authorID)
+			if err != nil {
+				log.Printf("[MUTATION] Failed to save draft for %s at line %d: %v", src.Operator, src.Line, err)
+				mutant.Error = "failed to save draft"
+			} else {
+				mutant.SnippetID = draft.ID
+			}
+			report.Killed++
+		case mutant.Error == "" && killed != nil:
+			report.Survived++
+		}
+		report.Mutants = append(report.Mutants, mutant)
+	}
+
+	log.Printf("[MUTATION] snippet=%s generated=%d killed=%d survived=%d",
+		snippet.ID, report.Generated, report.Killed, report.Survived)
+	return report, nil
+}
+
+// dedupeMutants drops duplicates and mutants that reproduce the snippet's own
+// BuggyCode or CorrectCode, then caps the batch
+func dedupeMutants(sources []mutantSource, snippet *model.Snippet) []mutantSource {
+	seen := map[string]bool{
+		strings.TrimSpace(snippet.CorrectCode): true,
+		strings.TrimSpace(snippet.BuggyCode):   true,
+	}
+	unique := make([]mutantSource, 0, len(sources))
+	for _, src := range sources {
+		key := strings.TrimSpace(src.Code)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, src)
+		if len(unique) == maxMutants {
+			break
+		}
+	}
+	return unique
+}
+
+// mutatePython parses code with Python's ast module in the sandbox and
+// returns one mutated source per mutation site. The code is only parsed,
+// never executed.
+func (s *MutationService) mutatePython(ctx context.Context, code string) ([]mutantSource, error) {
+	literal, err := json.Marshal(code)
+	if err != nil {
+		return nil, err
+	}
+
+	script := fmt.Sprintf(`import ast, json
+__src = %s
+__b = __src.encode()
+__starts = [0]
+for __l in __b.split(b"\n"):
+    __starts.append(__starts[-1] + len(__l) + 1)
+__out = []
+
+def __span(n):
+    return __starts[n.lineno - 1] + n.col_offset, __starts[n.end_lineno - 1] + n.end_col_offset
+
+def __seg(n):
+    a, b = __span(n)
+    return __b[a:b].decode()
+
+def __atom(n):
+    if isinstance(n, (ast.Name, ast.Constant, ast.Attribute, ast.Subscript, ast.Call)):
+        return __seg(n)
+    return "(" + __seg(n) + ")"
+
+def __add(op, line, a, b, repl):
+    orig = __b[a:b].decode()
+    if orig != repl:
+        code = (__b[:a] + repl.encode() + __b[b:]).decode()
+        __out.append({"operator": op, "line": line, "original": orig.strip(), "replacement": repl.strip(), "code": code})
+
+def __int(n):
+    if isinstance(n, ast.Constant) and type(n.value) is int:
+        return n.value
+    if isinstance(n, ast.UnaryOp) and isinstance(n.op, ast.USub) and isinstance(n.operand, ast.Constant) and type(n.operand.value) is int:
+        return -n.operand.value
+    return None
+
+__cmp = {ast.Lt: ("<", "<="), ast.LtE: ("<=", "<"), ast.Gt: (">", ">="), ast.GtE: (">=", ">")}
+__sym = {ast.Sub: "-", ast.Div: "/", ast.FloorDiv: "//", ast.Mod: "%%"}
+
+for n in ast.walk(ast.parse(__src)):
+    if isinstance(n, ast.Call) and isinstance(n.func, ast.Name) and n.func.id == "range" and 1 <= len(n.args) <= 3:
+        stop = n.args[0] if len(n.args) == 1 else n.args[1]
+        a, b = __span(stop)
+        for d in (" + 1", " - 1"):
+            __add("range_bound", stop.lineno, a, b, __atom(stop) + d)
+    if isinstance(n, ast.Subscript) and isinstance(n.slice, ast.Slice) and n.slice.upper is not None:
+        a, b = __span(n.slice.upper)
+        for d in (" + 1", " - 1"):
+            __add("range_bound", n.lineno, a, b, __atom(n.slice.upper) + d)
+    if isinstance(n, ast.Compare) and len(n.ops) == 1 and type(n.ops[0]) in __cmp:
+        old, new = __cmp[type(n.ops[0])]
+        a = __span(n.left)[1]
+        b = __span(n.comparators[0])[0]
+        __add("compare_boundary", n.lineno, a, b, __b[a:b].decode().replace(old, new, 1))
+    if isinstance(n, ast.BinOp) and type(n.op) in __sym and __seg(n.left) != __seg(n.right):
+        a, b = __span(n)
+        __add("swapped_operands", n.lineno, a, b, __atom(n.right) + " " + __sym[type(n.op)] + " " + __atom(n.left))
+    if isinstance(n, ast.BinOp) and isinstance(n.op, (ast.Add, ast.Sub)) and __int(n.right) == 1:
+        a, b = __span(n)
+        __add("dropped_plus_one", n.lineno, a, b, __atom(n.left))
+    if isinstance(n, (ast.Assign, ast.AnnAssign)) and n.value is not None:
+        values = n.value.elts if isinstance(n.value, ast.Tuple) else [n.value]
+        for v in values:
+            i = __int(v)
+            if i is not None:
+                a, b = __span(v)
+                for d in (1, -1):
+                    __add("wrong_initial_value", v.lineno, a, b, str(i + d))
+    for field in ("body", "orelse"):
+        stmts = getattr(n, field, None)
+        if not isinstance(stmts, list):
+            continue
+        for loop, ret in zip(stmts, stmts[1:]):
+            if isinstance(loop, (ast.For, ast.While)) and not loop.orelse and isinstance(ret, ast.Return) and ret.lineno == ret.end_lineno:
+                last = loop.body[-1]
+                a = __span(last)[1]
+                b = __span(ret)[1]
+                indent = __b[__starts[last.lineno - 1]:__starts[last.lineno - 1] + last.col_offset].decode()
+                if indent.strip() == "":
+                    __add("early_return", ret.lineno, a, b, "\n" + indent + __seg(ret))
+
+print(%q + json.dumps(__out))
+`, literal, mutantOutputPrefix)
+
+	req := execRequest(script, "python", s.snippetService.cfg.App.Limits)
+	req.Policy = policyTrusted
+	resp, err := s.snippetService.executorService.Execute(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("%s", lastLine(resp.Stderr))
+	}
+
+	var sources []mutantSource
+	for _, line := range strings.Split(resp.Stdout, "\n") {
+		if strings.HasPrefix(line, mutantOutputPrefix) {
+			if err := json.Unmarshal([]byte(strings.TrimPrefix(line, mutantOutputPrefix)), &sources); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return sources, nil
+}
+
+// mutantOutcome is whether the tests killed a mutant, or why it couldn't run
+type mutantOutcome struct {
+	killed bool
+	err    string
+}
+
+// killMutants runs the test cases against CorrectCode and then every mutant.
+// A mutant is killed when any test fails, raises or times out. Go snippets
+// always have a test file, so Go mutants run under go test.
+func (s *MutationService) killMutants(ctx context.Context, snippet *model.Snippet, sources []mutantSource) ([]mutantOutcome, error) {
+	if hasTestFile(snippet) {
+		return s.killTestFileMutants(ctx, snippet, sources)
+	}
+	if snippet.TestFormat == model.TestFormatStdio {
+		return s.killStdioMutants(ctx, snippet, sources)
+	}
+	if snippet.Language != "python" {
+		return nil, fmt.Errorf("%s snippets need a test file to run mutants", snippet.Language)
+	}
+
+	params := functionParams(snippet.CorrectCode, snippet.Language)
+	inputs := make([][]interface{}, len(snippet.TestCases))
+	expected := make([]string, len(snippet.TestCases))
+	for i, tc := range snippet.TestCases {
+		inputs[i] = testCaseArgs(params, tc.Input)
+		out, err := json.Marshal(tc.Expected)
+		if err != nil {
+			return nil, err
+		}
+		expected[i] = string(out)
+	}
+
+	passes := func(outputs []string) bool {
+		if len(outputs) != len(expected) {
+			return false
+		}
+		for i, out := range outputs {
+			if isFuzzError(out) || !compareOutputs(expected[i], out) {
+				return false
+			}
+		}
+		return true
+	}
+
+	limits := s.snippetService.resolveLimits(snippet, false)
+	ref, err := s.snippetService.runFuzzBatch(ctx, snippet.CorrectCode, snippet.Language, inputs, limits)
+	if err != nil || !passes(ref) {
+		return nil, fmt.Errorf("correct_code does not pass its own test cases")
+	}
+
+	outcomes := make([]mutantOutcome, len(sources))
+	forEachMutant(len(sources), func(i int) {
+		outputs, err := s.snippetService.runFuzzBatch(ctx, sources[i].Code, snippet.Language, inputs, limits)
+		switch {
+		case outputs == nil && err != nil:
+			outcomes[i].err = err.Error()
+		default:
+			outcomes[i].killed = err != nil || !passes(outputs)
+		}
+	})
+	return outcomes, nil
+}
+
+// killTestFileMutants runs the snippet's test file against every mutant. A
+// race or leaked goroutine the mutant introduces kills it like a failing test.
+func (s *MutationService) killTestFileMutants(ctx context.Context, snippet *model.Snippet, sources []mutantSource) ([]mutantOutcome, error) {
+	limits := s.snippetService.resolveLimits(snippet, false)
+	ref, err := s.snippetService.executorService.Execute(ctx, testFileRequest(snippet, snippet.CorrectCode, limits))
+	if err != nil {
+		return nil, err
+	}
+	if _, passed := testFileResults(ref); !passed {
+		return nil, fmt.Errorf("correct_code does not pass its own tests")
+	}
+
+	outcomes := make([]mutantOutcome, len(sources))
+	forEachMutant(len(sources), func(i int) {
+		resp, err := s.snippetService.executorService.Execute(ctx, testFileRequest(snippet, sources[i].Code, limits))
+		if err != nil {
+			outcomes[i].err = err.Error()
+			return
+		}
+		_, passed := testFileResults(resp)
+		outcomes[i].killed = !passed
+	})
+	return outcomes, nil
+}
+
+// killStdioMutants runs every program mutant on each test case's stdin
+func (s *MutationService) killStdioMutants(ctx context.Context, snippet *model.Snippet, sources []mutantSource) ([]mutantOutcome, error) {
+	limits := s.snippetService.resolveLimits(snippet, false)
+	_, passed, err := s.snippetService.gradeStdio(ctx, snippet, stdioRequest(snippet, snippet.CorrectCode, limits), nil)
+	if err != nil {
+		return nil, err
+	}
+	if !passed {
+		return nil, fmt.Errorf("correct_code does not pass its own tests")
+	}
+
+	outcomes := make([]mutantOutcome, len(sources))
+	forEachMutant(len(sources), func(i int) {
+		_, passed, err := s.snippetService.gradeStdio(ctx, snippet, stdioRequest(snippet, sources[i].Code, limits), nil)
+		if err != nil {
+			outcomes[i].err = err.Error()
+			return
+		}
+		outcomes[i].killed = !passed
+	})
+	return outcomes, nil
+}
+
+// forEachMutant calls fn for every mutant index on mutantWorkers goroutines
+func forEachMutant(n int, fn func(i int)) {
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < mutantWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// createDraft saves a killed mutant as a pending_review copy of snippet with
+// the mutant as its BuggyCode
+func (s *MutationService) createDraft(snippet *model.Snippet, src mutantSource, authorID string) (*model.Snippet, error) {
+	op := mutationOperators[src.Operator]
+
+	draft := *snippet
+	draft.ID = uuid.New().String()
+	draft.BuggyCode = src.Code
+	draft.BugType = src.Operator
+	draft.BugTypeID = nil
+	draft.CreatedBy = &authorID
+	draft.Status = mutantDraftStatus
+	draft.Hint1 = op.Hint
+	draft.Hint2 = fmt.Sprintf("Look closely at line %d.", src.Line)
+	draft.Hint3 = ""
+
+	if bt, err := s.bugTypeRepo.GetBySlug(op.Slug); err == nil {
+		draft.BugType = bt.Name
+		draft.BugTypeID = &bt.ID
+		draft.Title = fmt.Sprintf("%s (%s)", snippet.Title, strings.ToLower(bt.Name))
+	} else {
+		log.Printf("[MUTATION] Bug type %s not found: %v", op.Slug, err)
+	}
+	draft.BugExplanation = mutantExplanation(src)
+
+	if err := s.snippetRepo.Create(&draft); err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// ReviewDraft approves a pending_review draft, making it active, or rejects
+// it, archiving it. Reviewing anything else is ErrNotPendingReview.
+func (s *MutationService) ReviewDraft(snippetID string, approve bool) (*model.Snippet, error) {
+	status := rejectedStatus
+	if approve {
+		status = approvedStatus
+	}
+	ok, err := s.snippetRepo.UpdateStatus(snippetID, mutantDraftStatus, status)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotPendingReview
+	}
+	log.Printf("[MUTATION] Draft %s reviewed: %s", snippetID, status)
+	return s.snippetService.GetSnippetAdmin(snippetID)
+}
+
+// mutantExplanation describes the change that introduced the bug
+func mutantExplanation(src mutantSource) string {
+	switch src.Operator {
+	case "early_return":
+		return fmt.Sprintf("Line %d: `%s` was moved inside the loop, so the function returns after the first iteration.", src.Line, src.Original)
+	case "dropped_plus_one":
+		return fmt.Sprintf("Line %d: `%s` became `%s`, dropping the adjustment by one.", src.Line, src.Original, src.Replacement)
+	default:
+		return fmt.Sprintf("Line %d: `%s` was changed to `%s`.", src.Line, src.Original, src.Replacement)
+	}
+}
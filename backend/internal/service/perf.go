@@ -0,0 +1,378 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+const (
+	defaultPerfBudgetFactor = 3.0
+	defaultPerfMinBudgetMS  = 20.0
+	defaultPerfSeed         = 1
+
+	// scalingTolerance is how far the fitted exponent may exceed the expected
+	// class before the submission fails. It absorbs timing noise and log
+	// factors, so O(n log n) is not told apart from O(n).
+	scalingTolerance = 0.5
+)
+
+// complexityExponents maps supported classes to their polynomial degree
+var complexityExponents = map[string]float64{
+	"O(1)":       0,
+	"O(log n)":   0,
+	"O(n)":       1,
+	"O(n log n)": 1,
+	"O(n^2)":     2,
+	"O(n^3)":     3,
+}
+
+// complexityCurves are fitted against measured times to label the growth rate
+var complexityCurves = map[string]func(n float64) float64{
+	"O(1)":       func(n float64) float64 { return 1 },
+	"O(log n)":   func(n float64) float64 { return math.Log2(n) },
+	"O(n)":       func(n float64) float64 { return n },
+	"O(n log n)": func(n float64) float64 { return n * math.Log2(n) },
+	"O(n^2)":     func(n float64) float64 { return n * n },
+	"O(n^3)":     func(n float64) float64 { return n * n * n },
+}
+
+// validatePerfSpec rejects specs that cannot produce a meaningful measurement
+func validatePerfSpec(spec *model.PerfSpec) error {
+	if len(spec.Cases) == 0 && spec.Scaling == nil {
+		return fmt.Errorf("perf spec needs cases or a scaling check")
+	}
+	if err := validateFuzzSpec(&model.FuzzSpec{Params: spec.Params}); err != nil {
+		return fmt.Errorf("perf spec: %w", err)
+	}
+	for _, c := range spec.Cases {
+		if c.Size <= 0 {
+			return fmt.Errorf("perf case size must be positive")
+		}
+		if c.BudgetFactor < 0 || c.MinBudgetMS < 0 {
+			return fmt.Errorf("perf case budget must not be negative")
+		}
+	}
+	if sc := spec.Scaling; sc != nil {
+		if _, ok := complexityExponents[sc.Complexity]; !ok {
+			return fmt.Errorf("unsupported complexity %q", sc.Complexity)
+		}
+		if len(sc.Sizes) < 3 {
+			return fmt.Errorf("scaling check needs at least 3 sizes")
+		}
+		for i, size := range sc.Sizes {
+			if size < 2 || (i > 0 && size <= sc.Sizes[i-1]) {
+				return fmt.Errorf("scaling sizes must be increasing and at least 2")
+			}
+		}
+	}
+	return nil
+}
+
+// buildPythonPerfHarness generates inputs inside the sandbox instead of
+// embedding them, since large inputs would not fit on the command line. The
+// same seed and size give the reference and the submission identical inputs.
+// Each size reports the best of up to three calls.
+//
+// It runs as the executor's harness, out of the code's reach: the inputs, and
+// a copy for each call, are made and the clock taken before the code runs,
+// so code that patches random, copy or time can't change what is measured.
+func buildPythonPerfHarness(funcName string, params []model.FuzzParam, seed int64, sizes []int) (string, error) {
+	spec, err := json.Marshal(map[string]interface{}{
+		"params": params,
+		"seed":   seed,
+		"sizes":  sizes,
+	})
+	if err != nil {
+		return "", err
+	}
+	literal, err := json.Marshal(string(spec))
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf(`import copy, json, random, time
+__spec = json.loads(%[1]s)
+
+def __gen(p, size, rng):
+    lo, hi = p.get("min"), p.get("max")
+    if lo is None:
+        lo = 0 if hi is None else hi - %[4]d
+    if hi is None:
+        hi = lo + %[4]d
+    t = p["type"]
+    if t == "int":
+        return rng.randint(int(lo), int(hi))
+    if t == "float":
+        return rng.uniform(lo, hi)
+    if t == "bool":
+        return rng.random() < 0.5
+    alphabet = p.get("alphabet") or %[2]q
+    if t == "string":
+        return "".join(rng.choice(alphabet) for _ in range(size))
+    if t == "string_list":
+        lo_len = p.get("min_len", 0)
+        hi_len = p.get("max_len") or max(%[5]d, lo_len)
+        vals = ["".join(rng.choice(alphabet) for _ in range(rng.randint(lo_len, hi_len))) for _ in range(size)]
+        if p.get("sorted"):
+            vals.sort()
+        return vals
+    if p.get("unique"):
+        vals = rng.sample(range(int(lo), int(hi) + 1), min(size, int(hi - lo) + 1))
+    else:
+        vals = [rng.randint(int(lo), int(hi)) for _ in range(size)]
+    if p.get("sorted"):
+        vals.sort()
+    return vals
+
+__clock = time.perf_counter
+__inputs = []
+for __size in __spec["sizes"]:
+    __rng = random.Random(__spec["seed"] * 1000003 + __size)
+    __args = [__gen(__p, __size, __rng) for __p in __spec["params"]]
+    __inputs.append((__size, [copy.deepcopy(__args) for _ in range(3)]))
+
+def run(g):
+    fn = g[%[3]q]
+    timings = []
+    for size, calls in __inputs:
+        best = None
+        for args in calls:
+            t = __clock()
+            fn(*args)
+            d = (__clock() - t) * 1000
+            best = d if best is None else min(best, d)
+            if d > 100:
+                break
+        timings.append({"size": size, "ms": best})
+    return timings
+`, literal, defaultFuzzAlphabet, funcName, defaultFuzzSpan, defaultFuzzMaxLen), nil
+}
+
+// perfTiming is one measured size from the harness
+type perfTiming struct {
+	Size int     `json:"size"`
+	MS   float64 `json:"ms"`
+}
+
+// runPerfBatch times code on each size. The timings are taken only from the
+// harness's report, which the code cannot write, and only from a run that
+// finished: one that died part way fails with the reason it stopped.
+func (s *SnippetService) runPerfBatch(ctx context.Context, code, language string, spec *model.PerfSpec, sizes []int, limits model.ExecutionLimits) (map[int]float64, error) {
+	seed := spec.Seed
+	if seed == 0 {
+		seed = defaultPerfSeed
+	}
+
+	funcName := extractFunctionName(code, language)
+	harness, err := buildPythonPerfHarness(funcName, spec.Params, seed, sizes)
+	if err != nil {
+		return nil, err
+	}
+
+	req := execRequest(code, language, limits)
+	req.Harness = harness
+	resp, err := s.executorService.Execute(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("perf execution failed: %w", err)
+	}
+	if !resp.Success {
+		if resp.ExitCode == 124 {
+			return nil, fmt.Errorf("timed out")
+		}
+		return nil, fmt.Errorf("%s", lastLine(resp.Stderr))
+	}
+
+	var reported []perfTiming
+	if err := json.Unmarshal(resp.Harness, &reported); err != nil || len(reported) != len(sizes) {
+		return nil, fmt.Errorf("the run did not finish timing every size")
+	}
+	timings := make(map[int]float64, len(reported))
+	for _, t := range reported {
+		timings[t.Size] = t.MS
+	}
+	return timings, nil
+}
+
+// runPerf checks the per-case budgets and the scaling class of code, both
+// calibrated against CorrectCode timed on the same executor
+func (s *SnippetService) runPerf(ctx context.Context, snippet *model.Snippet, code, language string, limits model.ExecutionLimits) *model.PerfResult {
+	spec := snippet.PerfSpec
+	result := &model.PerfResult{Passed: true}
+
+	if language != "python" || language != snippet.Language {
+		result.Passed, result.Error = false, fmt.Sprintf("performance checks are not supported for %s", language)
+		return result
+	}
+	if err := validatePerfSpec(spec); err != nil {
+		result.Passed, result.Error = false, err.Error()
+		return result
+	}
+
+	if len(spec.Cases) > 0 {
+		sizes := make([]int, len(spec.Cases))
+		for i, c := range spec.Cases {
+			sizes[i] = c.Size
+		}
+
+		ref, err := s.runPerfBatch(ctx, snippet.CorrectCode, language, spec, sizes, limits)
+		if err != nil {
+			log.Printf("[ERROR] Perf reference run failed: %v", err)
+			result.Passed, result.Error = false, "reference solution failed the performance cases"
+			return result
+		}
+		user, userErr := s.runPerfBatch(ctx, code, language, spec, sizes, limits)
+
+		for _, c := range spec.Cases {
+			cr := evaluatePerfCase(c, ref[c.Size])
+			if userErr != nil {
+				cr.Error = userErr.Error()
+			} else {
+				cr.TimeMS = user[c.Size]
+				cr.Passed = cr.TimeMS <= cr.BudgetMS
+			}
+			if !cr.Passed {
+				result.Passed = false
+			}
+			result.Cases = append(result.Cases, cr)
+		}
+	}
+
+	if sc := spec.Scaling; sc != nil {
+		ref, err := s.runPerfBatch(ctx, snippet.CorrectCode, language, spec, sc.Sizes, limits)
+		if err != nil {
+			log.Printf("[ERROR] Scaling reference run failed: %v", err)
+			result.Passed, result.Error = false, "reference solution failed the scaling check"
+			return result
+		}
+		user, userErr := s.runPerfBatch(ctx, code, language, spec, sc.Sizes, limits)
+		if userErr != nil {
+			result.Passed, result.Error = false, userErr.Error()
+			return result
+		}
+
+		scaling := evaluateScaling(sc, ref, user)
+		if !scaling.Passed {
+			result.Passed = false
+		}
+		result.Scaling = scaling
+	}
+
+	log.Printf("[PERF] snippet=%s passed=%v", snippet.ID, result.Passed)
+	return result
+}
+
+func evaluatePerfCase(c model.PerfCase, referenceMS float64) model.PerfCaseResult {
+	factor := c.BudgetFactor
+	if factor == 0 {
+		factor = defaultPerfBudgetFactor
+	}
+	minBudget := c.MinBudgetMS
+	if minBudget == 0 {
+		minBudget = defaultPerfMinBudgetMS
+	}
+
+	return model.PerfCaseResult{
+		Size:        c.Size,
+		ReferenceMS: referenceMS,
+		BudgetMS:    math.Max(referenceMS*factor, minBudget),
+	}
+}
+
+// evaluateScaling fails the submission when its growth exponent exceeds the
+// expected class, unless the reference itself misses it on this executor
+func evaluateScaling(sc *model.ScalingSpec, ref, user map[int]float64) *model.ScalingResult {
+	result := &model.ScalingResult{
+		Sizes:    sc.Sizes,
+		Expected: sc.Complexity,
+	}
+
+	for _, size := range sc.Sizes {
+		result.ReferenceMS = append(result.ReferenceMS, ref[size])
+		result.TimesMS = append(result.TimesMS, user[size])
+	}
+
+	limit := complexityExponents[sc.Complexity] + scalingTolerance
+	result.ReferenceExponent = fitExponent(sc.Sizes, ref)
+	result.Exponent = fitExponent(sc.Sizes, user)
+	result.Estimated = estimateComplexity(sc.Sizes, user)
+
+	if result.ReferenceExponent > limit {
+		result.Inconclusive = true
+		result.Passed = true
+	} else {
+		result.Passed = result.Exponent <= limit
+	}
+	return result
+}
+
+// fitExponent returns the least-squares slope of log(time) against log(size)
+func fitExponent(sizes []int, times map[int]float64) float64 {
+	if len(sizes) < 2 {
+		return 0
+	}
+
+	var sumX, sumY, sumXY, sumXX float64
+	for _, size := range sizes {
+		x := math.Log(float64(size))
+		y := math.Log(math.Max(times[size], 0.001))
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	n := float64(len(sizes))
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		return 0
+	}
+	return (n*sumXY - sumX*sumY) / denom
+}
+
+// estimateComplexity labels the measured growth with the class whose curve
+// fits best in log space, i.e. leaves the least variance in log(time/f(n))
+func estimateComplexity(sizes []int, times map[int]float64) string {
+	if len(sizes) < 2 {
+		return "unknown"
+	}
+
+	names := make([]string, 0, len(complexityCurves))
+	for name := range complexityCurves {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	best, bestVariance := "unknown", math.Inf(1)
+	for _, name := range names {
+		f := complexityCurves[name]
+		residuals := make([]float64, len(sizes))
+		var mean float64
+		for i, size := range sizes {
+			residuals[i] = math.Log(math.Max(times[size], 0.001)) - math.Log(f(float64(size)))
+			mean += residuals[i]
+		}
+		mean /= float64(len(sizes))
+
+		var variance float64
+		for _, r := range residuals {
+			variance += (r - mean) * (r - mean)
+		}
+		if variance < bestVariance {
+			best, bestVariance = name, variance
+		}
+	}
+	return best
+}
+
+// lastLine returns the final non-empty line of output, usually the exception
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
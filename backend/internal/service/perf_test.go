@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/bugdrill/backend/internal/config"
+	"github.com/bugdrill/backend/internal/model"
+)
+
+func TestRunPerfBatch(t *testing.T) {
+	tests := []struct {
+		name    string
+		resp    ExecuteResponse
+		want    map[int]float64
+		wantErr string
+	}{
+		{
+			name: "timings from the harness",
+			resp: ExecuteResponse{Success: true, Harness: json.RawMessage(`[{"size":10,"ms":1.5},{"size":20,"ms":3}]`)},
+			want: map[int]float64{10: 1.5, 20: 3},
+		},
+		{
+			name:    "timings printed by the code are ignored",
+			resp:    ExecuteResponse{Success: true, Stdout: "__PERF__{\"size\":10,\"ms\":0}\n__PERF__{\"size\":20,\"ms\":0}\n"},
+			wantErr: "the run did not finish timing every size",
+		},
+		{
+			name:    "a run that stopped part way",
+			resp:    ExecuteResponse{Success: false, ExitCode: 1, Stderr: "Traceback\nMemoryError"},
+			wantErr: "MemoryError",
+		},
+		{
+			name:    "a run that timed out",
+			resp:    ExecuteResponse{Success: false, ExitCode: 124},
+			wantErr: "timed out",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var harness string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var req ExecuteRequest
+				json.NewDecoder(r.Body).Decode(&req)
+				harness = req.Harness
+				json.NewEncoder(w).Encode(tt.resp)
+			}))
+			defer server.Close()
+
+			s := &SnippetService{executorService: NewExecutorService(config.ExecutorConfig{URLs: []string{server.URL}, Timeout: 5 * time.Second, BreakerThreshold: 5, BreakerCooldown: time.Minute})}
+			spec := &model.PerfSpec{Params: []model.FuzzParam{{Name: "nums", Type: "int_list"}}}
+			got, err := s.runPerfBatch(context.Background(), "def f(nums):\n    return sorted(nums)\n", "python", spec, []int{10, 20}, model.ExecutionLimits{TimeoutSec: 5})
+
+			if harness == "" {
+				t.Fatal("expected the timings to be taken by a harness")
+			}
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("expected error %q, got %v, %v", tt.wantErr, got, err)
+				}
+				return
+			}
+			if err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v, %v", tt.want, got, err)
+			}
+		})
+	}
+}
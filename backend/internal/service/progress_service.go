@@ -0,0 +1,180 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/bugdrill/backend/internal/model"
+	"github.com/bugdrill/backend/internal/repository"
+)
+
+const (
+	// Weights of the mastery score components; they sum to 1
+	masterySolveWeight        = 0.7
+	masteryLocalizationWeight = 0.3
+)
+
+type ProgressService struct {
+	attemptRepo  *repository.AttemptRepository
+	progressRepo *repository.ProgressRepository
+}
+
+func NewProgressService(
+	attemptRepo *repository.AttemptRepository,
+	progressRepo *repository.ProgressRepository,
+) *ProgressService {
+	return &ProgressService{
+		attemptRepo:  attemptRepo,
+		progressRepo: progressRepo,
+	}
+}
+
+// RecordAttempt stores a submission along with the result of the active
+// localization guess, then refreshes the user's progress on the snippet's
+// pattern. The guess itself was recorded when it was made.
+func (s *ProgressService) RecordAttempt(userID string, snippet *model.Snippet, code string, result *model.ExecuteCodeResponse) error {
+	passed := 0
+	for _, tr := range result.TestResults {
+		if tr.Passed {
+			passed++
+		}
+	}
+
+	attempt := &model.Attempt{
+		UserID:          userID,
+		SnippetID:       snippet.ID,
+		SubmittedCode:   code,
+		IsCorrect:       result.IsCorrect,
+		ExecutionTimeMS: result.TotalTimeMS,
+		TestCasesPassed: passed,
+		TestCasesTotal:  len(result.TestResults),
+	}
+
+	localization, err := s.GetLocalization(userID, snippet.ID)
+	if err != nil {
+		log.Printf("[WARN] Failed to load localization: %v", err)
+	}
+	if localization != nil {
+		attempt.LocalizationResult = &localization.Result
+		result.Localization = localization
+	}
+
+	if err := s.attemptRepo.Create(attempt); err != nil {
+		return fmt.Errorf("failed to store attempt: %w", err)
+	}
+
+	// A solved snippet starts a fresh drill next time
+	if result.IsCorrect {
+		s.clearLocalization(userID, snippet.ID)
+	}
+
+	return s.refreshPatternProgress(userID, snippet.PatternID)
+}
+
+// GiveUp records a forfeit as an unsolved attempt and returns the learner's
+// solve state afterwards. Solved or already forfeited snippets are left as is.
+func (s *ProgressService) GiveUp(userID string, snippet *model.Snippet) (string, error) {
+	state, err := s.GetSolveState(userID, snippet.ID)
+	if err != nil {
+		return "", err
+	}
+	if state != model.SolveStateUnsolved {
+		return state, nil
+	}
+
+	attempt := &model.Attempt{
+		UserID:    userID,
+		SnippetID: snippet.ID,
+		GaveUp:    true,
+	}
+	if err := s.attemptRepo.Create(attempt); err != nil {
+		return "", fmt.Errorf("failed to store attempt: %w", err)
+	}
+	s.clearLocalization(userID, snippet.ID)
+	log.Printf("[GIVE UP] user=%s snippet=%s", userID, snippet.ID)
+
+	if err := s.refreshPatternProgress(userID, snippet.PatternID); err != nil {
+		return "", err
+	}
+	return model.SolveStateGivenUp, nil
+}
+
+func (s *ProgressService) GetSolveState(userID, snippetID string) (string, error) {
+	states, err := s.GetSolveStates(userID, []string{snippetID})
+	if err != nil {
+		return "", err
+	}
+	if state, ok := states[snippetID]; ok {
+		return state, nil
+	}
+	return model.SolveStateUnsolved, nil
+}
+
+// GetSolveStates maps snippet IDs to solve states, omitting unattempted ones
+func (s *ProgressService) GetSolveStates(userID string, snippetIDs []string) (map[string]string, error) {
+	if len(snippetIDs) == 0 {
+		return map[string]string{}, nil
+	}
+	return s.attemptRepo.GetSolveStates(userID, snippetIDs)
+}
+
+func (s *ProgressService) refreshPatternProgress(userID string, patternID int) error {
+	stats, err := s.progressRepo.GetPatternStats(userID, patternID)
+	if err != nil {
+		return fmt.Errorf("failed to aggregate progress: %w", err)
+	}
+
+	progress := &model.PatternProgress{
+		PatternID:     patternID,
+		Attempted:     stats.SnippetsAttempted,
+		Solved:        stats.SnippetsSolved,
+		TotalAttempts: stats.TotalAttempts,
+		MasteryLevel:  masteryLevel(stats),
+	}
+	if stats.SnippetsSolved > 0 {
+		// DECIMAL(4,2) tops out below 100
+		avg := math.Min(float64(stats.TotalAttempts)/float64(stats.SnippetsSolved), 99.99)
+		progress.AvgAttemptsPerSolve = &avg
+	}
+	if stats.Localizations > 0 {
+		accuracy := stats.LocalizationAccuracy
+		progress.LocalizationAccuracy = &accuracy
+	}
+
+	return s.progressRepo.Upsert(userID, progress)
+}
+
+// masteryLevel scores a pattern from 0 to 100. It is the share of attempted
+// snippets solved, blended with localization accuracy once the user has
+// practiced localizing.
+func masteryLevel(stats *model.PatternStats) int {
+	if stats.SnippetsAttempted == 0 {
+		return 0
+	}
+
+	mastery := float64(stats.SnippetsSolved) / float64(stats.SnippetsAttempted)
+	if stats.Localizations > 0 {
+		mastery = masterySolveWeight*mastery + masteryLocalizationWeight*stats.LocalizationAccuracy
+	}
+	return int(math.Round(mastery * 100))
+}
+
+func (s *ProgressService) GetUserProgress(userID string) (*model.UserProgress, error) {
+	patterns, err := s.progressRepo.GetByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	bugTypes, err := s.progressRepo.GetBugTypeAccuracy(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := &model.UserProgress{Patterns: patterns, BugTypes: bugTypes}
+	for _, p := range patterns {
+		progress.TotalSnippetsAttempted += p.Attempted
+		progress.TotalSnippetsSolved += p.Solved
+	}
+	return progress, nil
+}
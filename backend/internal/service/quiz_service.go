@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/bugdrill/backend/internal/model"
+	"github.com/bugdrill/backend/internal/repository"
+	"github.com/redis/go-redis/v9"
+)
+
+const quizChoiceCount = 4
+
+var (
+	ErrNoBugQuiz     = errors.New("snippet has no bug type quiz")
+	ErrQuizAnswered  = errors.New("bug type quiz already answered")
+	ErrInvalidChoice = errors.New("bug type is not one of the quiz choices")
+)
+
+type QuizService struct {
+	bugTypeRepo *repository.BugTypeRepository
+	redis       *redis.Client
+}
+
+func NewQuizService(bugTypeRepo *repository.BugTypeRepository, redis *redis.Client) *QuizService {
+	return &QuizService{
+		bugTypeRepo: bugTypeRepo,
+		redis:       redis,
+	}
+}
+
+func (s *QuizService) GetBugTypes() ([]model.BugType, error) {
+	ctx := context.Background()
+	cacheKey := "bug_types:all"
+
+	// Try cache first
+	cached, err := s.redis.Get(ctx, cacheKey).Result()
+	if err == nil {
+		var bugTypes []model.BugType
+		if err := json.Unmarshal([]byte(cached), &bugTypes); err == nil {
+			return bugTypes, nil
+		}
+	}
+
+	// Cache miss - fetch from DB
+	bugTypes, err := s.bugTypeRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	// Cache the result
+	data, _ := json.Marshal(bugTypes)
+	s.redis.Set(ctx, cacheKey, data, 24*time.Hour)
+
+	return bugTypes, nil
+}
+
+// GetQuiz offers the snippet's bug type among its siblings in the taxonomy.
+// Choices are shuffled per user so a refresh shows the same quiz.
+func (s *QuizService) GetQuiz(userID string, snippet *model.Snippet) (*model.BugQuiz, error) {
+	choices, _, err := s.quizChoices(userID, snippet)
+	if err != nil {
+		return nil, err
+	}
+	return &model.BugQuiz{SnippetID: snippet.ID, Choices: choices}, nil
+}
+
+// AnswerQuiz records the learner's first answer. Later answers get the stored
+// result back along with ErrQuizAnswered.
+func (s *QuizService) AnswerQuiz(userID string, snippet *model.Snippet, bugTypeID int) (*model.QuizAnswerResponse, error) {
+	choices, answer, err := s.quizChoices(userID, snippet)
+	if err != nil {
+		return nil, err
+	}
+
+	var chosen *model.BugType
+	for i := range choices {
+		if choices[i].ID == bugTypeID {
+			chosen = &choices[i]
+		}
+	}
+	if chosen == nil {
+		return nil, ErrInvalidChoice
+	}
+
+	record := &model.QuizAnswer{
+		UserID:           userID,
+		SnippetID:        snippet.ID,
+		ChosenBugTypeID:  chosen.ID,
+		CorrectBugTypeID: answer.ID,
+		IsCorrect:        chosen.ID == answer.ID,
+	}
+	created, err := s.bugTypeRepo.CreateAnswer(record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store quiz answer: %w", err)
+	}
+	if !created {
+		previous, err := s.bugTypeRepo.GetAnswer(userID, snippet.ID)
+		if err != nil {
+			return nil, err
+		}
+		for i := range choices {
+			if choices[i].ID == previous.ChosenBugTypeID {
+				chosen = &choices[i]
+			}
+		}
+		return &model.QuizAnswerResponse{IsCorrect: previous.IsCorrect, Chosen: chosen, Answer: answer}, ErrQuizAnswered
+	}
+
+	return &model.QuizAnswerResponse{IsCorrect: record.IsCorrect, Chosen: chosen, Answer: answer}, nil
+}
+
+func (s *QuizService) quizChoices(userID string, snippet *model.Snippet) ([]model.BugType, *model.BugType, error) {
+	if snippet.BugTypeID == nil {
+		return nil, nil, ErrNoBugQuiz
+	}
+
+	bugTypes, err := s.GetBugTypes()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(userID + ":" + snippet.ID))
+	choices := buildQuizChoices(bugTypes, *snippet.BugTypeID, int64(h.Sum64()))
+	if choices == nil {
+		return nil, nil, ErrNoBugQuiz
+	}
+
+	for i := range choices {
+		if choices[i].ID == *snippet.BugTypeID {
+			return choices, &choices[i], nil
+		}
+	}
+	return nil, nil, ErrNoBugQuiz
+}
+
+// buildQuizChoices picks the answer plus distractors, preferring leaf types
+// from the answer's own category and topping up from other categories
+func buildQuizChoices(bugTypes []model.BugType, answerID int, seed int64) []model.BugType {
+	var answer *model.BugType
+	for i := range bugTypes {
+		if bugTypes[i].ID == answerID {
+			answer = &bugTypes[i]
+		}
+	}
+	if answer == nil {
+		return nil
+	}
+
+	var siblings, others []model.BugType
+	for _, b := range bugTypes {
+		if b.ID == answer.ID || b.ParentID == nil {
+			continue
+		}
+		if answer.ParentID != nil && *b.ParentID == *answer.ParentID {
+			siblings = append(siblings, b)
+		} else {
+			others = append(others, b)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(seed))
+	rng.Shuffle(len(siblings), func(i, j int) { siblings[i], siblings[j] = siblings[j], siblings[i] })
+	rng.Shuffle(len(others), func(i, j int) { others[i], others[j] = others[j], others[i] })
+
+	choices := append([]model.BugType{*answer}, siblings...)
+	choices = append(choices, others...)
+	if len(choices) > quizChoiceCount {
+		choices = choices[:quizChoiceCount]
+	}
+	rng.Shuffle(len(choices), func(i, j int) { choices[i], choices[j] = choices[j], choices[i] })
+	return choices
+}
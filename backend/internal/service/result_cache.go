@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+// graderRevision covers grading logic the harness probe can't see, such as
+// compareOutputs and the edit policy; bump it when that changes
+const graderRevision = 2
+
+const runnerProbeCode = "def probe(x):\n    return x\n"
+
+// runnerVersion identifies the harnesses a cached result was produced with.
+// It hashes what the harness and request builders emit for a fixed probe, so
+// changing one invalidates earlier results on its own. What the executor
+// does with the requests is covered by its own runner version.
+var runnerVersion = computeRunnerVersion()
+
+func computeRunnerVersion() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "grader %d\n", graderRevision)
+	io.WriteString(h, buildPythonTestHarness(runnerProbeCode, "probe", []string{"x"}, map[string]interface{}{"x": 1}))
+
+	lo, hi := 0.0, 1.0
+	params := []model.FuzzParam{{Name: "x", Type: "int", Min: &lo, Max: &hi}}
+	fuzz, _ := buildPythonFuzzHarness(runnerProbeCode, "probe", [][]interface{}{{1}})
+	perf, _ := buildPythonPerfHarness("probe", params, 1, []int{1})
+	tokens, _ := buildTokenizeScript(runnerProbeCode)
+	for _, script := range []string{fuzz, perf, tokens} {
+		io.WriteString(h, script)
+	}
+
+	probe := &model.Snippet{
+		Language:   "python",
+		TestCases:  model.TestCases{{Stdin: "1\n"}},
+		TestFormat: model.TestFormatPytest,
+		TestCode:   "def test_probe():\n    pass\n",
+		SQLSpec:    &model.SQLSpec{Schema: "CREATE TABLE t (x INT);"},
+	}
+	limits := model.ExecutionLimits{TimeoutSec: 1}
+	for _, req := range []ExecuteRequest{
+		execRequest(runnerProbeCode, probe.Language, limits),
+		stdioRequest(probe, runnerProbeCode, limits),
+		sqlRequest(probe, "SELECT x FROM t", limits),
+		testFileRequest(probe, runnerProbeCode, limits),
+	} {
+		data, _ := json.Marshal(req)
+		h.Write(data)
+	}
+	return hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+// resultReusable reports whether running req again would give the same
+// verdict. Fuzzing without a seed draws new inputs every time, and timings
+// vary from run to run.
+func resultReusable(snippet *model.Snippet, req *model.ExecuteCodeRequest) bool {
+	if snippet.FuzzSpec != nil && req.FuzzSeed == nil {
+		return false
+	}
+	return snippet.PerfSpec == nil
+}
+
+// resultCacheKey addresses a result by everything that decides it: the code,
+// the snippet's hidden tests and grading specs, the limits, and how both the
+// API and the executor run them. Snippets with different tests never share a
+// key.
+func resultCacheKey(snippet *model.Snippet, req *model.ExecuteCodeRequest, limits model.ExecutionLimits, executor string) string {
+	data, _ := json.Marshal(struct {
+		Runner      string                `json:"runner"`
+		Executor    string                `json:"executor"`
+		Language    string                `json:"language"`
+		Code        string                `json:"code"`
+		TestCases   model.TestCases       `json:"test_cases"`
+		FuzzSpec    *model.FuzzSpec       `json:"fuzz_spec"`
+		FuzzSeed    *int64                `json:"fuzz_seed"`
+		PerfSpec    *model.PerfSpec       `json:"perf_spec"`
+		EditPolicy  *model.EditPolicy     `json:"edit_policy"`
+		BuggyCode   string                `json:"buggy_code"`
+		CorrectCode string                `json:"correct_code"`
+		Files       model.SnippetFiles    `json:"files"`
+		Submitted   []model.FileContent   `json:"submitted_files"`
+		TestFormat  string                `json:"test_format"`
+		TestCode    string                `json:"test_code"`
+		GoTest      *model.GoTestSpec     `json:"go_test"`
+		SQLSpec     *model.SQLSpec        `json:"sql_spec"`
+		Stdio       *model.StdioSpec      `json:"stdio"`
+		Coverage    bool                  `json:"coverage"`
+		Limits      model.ExecutionLimits `json:"limits"`
+	}{
+		Runner:      runnerVersion,
+		Executor:    executor,
+		Language:    req.Language,
+		Code:        req.Code,
+		TestCases:   snippet.TestCases,
+		FuzzSpec:    snippet.FuzzSpec,
+		FuzzSeed:    req.FuzzSeed,
+		PerfSpec:    snippet.PerfSpec,
+		EditPolicy:  snippet.EditPolicy,
+		BuggyCode:   snippet.BuggyCode,
+		CorrectCode: snippet.CorrectCode,
+		Files:       snippet.Files,
+		Submitted:   req.Files,
+		TestFormat:  snippet.TestFormat,
+		TestCode:    snippet.TestCode,
+		GoTest:      snippet.GoTest,
+		SQLSpec:     snippet.SQLSpec,
+		Stdio:       snippet.Stdio,
+		Coverage:    req.Coverage,
+		Limits:      limits,
+	})
+	sum := sha256.Sum256(data)
+	return "result:" + hex.EncodeToString(sum[:])
+}
+
+func (s *SnippetService) cachedResult(ctx context.Context, key string) *model.ExecuteCodeResponse {
+	if s.cfg.App.ResultCacheTTL <= 0 {
+		return nil
+	}
+	data, err := s.redis.Get(ctx, key).Bytes()
+	if err != nil {
+		return nil
+	}
+	var result model.ExecuteCodeResponse
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	return &result
+}
+
+func (s *SnippetService) cacheResult(ctx context.Context, key string, result *model.ExecuteCodeResponse) {
+	if s.cfg.App.ResultCacheTTL <= 0 {
+		return
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		return
+	}
+	if err := s.redis.Set(ctx, key, data, s.cfg.App.ResultCacheTTL).Err(); err != nil {
+		log.Printf("⚠️  Failed to cache result: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+const revealDiffContext = 3
+
+var ErrRevealLocked = errors.New("solve the snippet or forfeit to reveal the answer")
+
+// Reveal returns the answer to a learner who solved the snippet or gave up
+// on it. With forfeit, an unsolved snippet is given up first.
+func (s *ProgressService) Reveal(userID string, snippet *model.Snippet, forfeit bool) (*model.Reveal, error) {
+	state, err := s.GetSolveState(userID, snippet.ID)
+	if err != nil {
+		return nil, err
+	}
+	if state == model.SolveStateUnsolved {
+		if !forfeit {
+			return nil, ErrRevealLocked
+		}
+		if state, err = s.GiveUp(userID, snippet); err != nil {
+			return nil, err
+		}
+	}
+
+	return &model.Reveal{
+		SnippetID:      snippet.ID,
+		SolveState:     state,
+		CorrectCode:    snippet.CorrectCode,
+		Diff:           unifiedDiff("buggy_code", "correct_code", snippet.BuggyCode, snippet.CorrectCode, revealDiffContext),
+		Files:          revealFiles(snippet),
+		BugType:        snippet.BugType,
+		BugTypeID:      snippet.BugTypeID,
+		BugExplanation: snippet.BugExplanation,
+	}, nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+var (
+	ErrInvalidFileEdit = errors.New("invalid file edit")
+	// ErrMultiFileUnsupported is returned by features that only run the
+	// code on its own
+	ErrMultiFileUnsupported = errors.New("not supported for multi-file snippets")
+)
+
+// maxSnippetFiles bounds the tree of a multi-file snippet
+const maxSnippetFiles = 20
+
+// reservedFiles are the names the executor gives the code, its tests and
+// their scaffolding; a snippet's files may not take them
+var reservedFiles = map[string]map[string]bool{
+	"python": {"solution.py": true, "test_solution.py": true},
+	"go":     {"go.mod": true, "snippet.go": true, "snippet_test.go": true, "zz_leakcheck_test.go": true, "zz_cover.out": true},
+}
+
+// validateSnippetFiles checks the file tree of a multi-file snippet. Fuzzing
+// and performance checks call the code on its own, so they can't be used
+// with one.
+func validateSnippetFiles(snippet *model.Snippet, maxFileSize int) error {
+	if len(snippet.Files) == 0 {
+		return nil
+	}
+	if len(snippet.Files) > maxSnippetFiles {
+		return fmt.Errorf("at most %d files per snippet", maxSnippetFiles)
+	}
+	if snippet.FuzzSpec != nil || snippet.PerfSpec != nil {
+		return fmt.Errorf("fuzz_spec and perf_spec are %v", ErrMultiFileUnsupported)
+	}
+
+	seen := map[string]bool{}
+	for _, f := range snippet.Files {
+		if !validFilePath(f.Path) {
+			return fmt.Errorf("invalid file path %q", f.Path)
+		}
+		if seen[f.Path] {
+			return fmt.Errorf("duplicate file path %q", f.Path)
+		}
+		seen[f.Path] = true
+		if reservedFiles[snippet.Language][f.Path] {
+			return fmt.Errorf("file path %q is reserved", f.Path)
+		}
+		if !f.Editable && f.CorrectContent != "" {
+			return fmt.Errorf("read-only file %q has correct_content", f.Path)
+		}
+		if len(f.Content) > maxFileSize || len(f.CorrectContent) > maxFileSize {
+			return fmt.Errorf("file %q exceeds the maximum snippet size", f.Path)
+		}
+	}
+	return nil
+}
+
+// validFilePath accepts clean relative slash-separated paths that stay inside
+// the workspace
+func validFilePath(p string) bool {
+	return p != "" && p != "." && p != ".." && !path.IsAbs(p) && path.Clean(p) == p &&
+		!strings.HasPrefix(p, "../") && !strings.ContainsAny(p, "\\\x00")
+}
+
+// submissionFiles is the tree a submission runs in: the snippet's files with
+// the learner's edits applied. Only editable files may be edited.
+func submissionFiles(snippet *model.Snippet, edits []model.FileContent, maxFileSize int) ([]model.FileContent, error) {
+	edited := make(map[string]string, len(edits))
+	for _, e := range edits {
+		if _, dup := edited[e.Path]; dup {
+			return nil, fmt.Errorf("%w: %q is submitted twice", ErrInvalidFileEdit, e.Path)
+		}
+		if len(e.Content) > maxFileSize {
+			return nil, ErrCodeTooLarge
+		}
+		edited[e.Path] = e.Content
+	}
+
+	files := make([]model.FileContent, len(snippet.Files))
+	for i, f := range snippet.Files {
+		files[i] = model.FileContent{Path: f.Path, Content: f.Content}
+		content, ok := edited[f.Path]
+		if !ok {
+			continue
+		}
+		if !f.Editable {
+			return nil, fmt.Errorf("%w: %q is read-only", ErrInvalidFileEdit, f.Path)
+		}
+		files[i].Content = content
+		delete(edited, f.Path)
+	}
+	for p := range edited {
+		return nil, fmt.Errorf("%w: snippet has no file %q", ErrInvalidFileEdit, p)
+	}
+	return files, nil
+}
+
+// learnerFiles is the tree as a learner sees it, without the fixes
+func learnerFiles(snippet *model.Snippet) []model.SnippetFile {
+	if len(snippet.Files) == 0 {
+		return nil
+	}
+	files := make([]model.SnippetFile, len(snippet.Files))
+	for i, f := range snippet.Files {
+		files[i] = model.SnippetFile{Path: f.Path, Content: f.Content, Editable: f.Editable}
+	}
+	return files
+}
+
+// correctFiles are the fixed versions of the editable files
+func correctFiles(snippet *model.Snippet) []model.FileContent {
+	var files []model.FileContent
+	for _, f := range snippet.Files {
+		if f.Editable {
+			files = append(files, model.FileContent{Path: f.Path, Content: f.Correct()})
+		}
+	}
+	return files
+}
+
+// revealFiles diffs each editable file against its fixed version
+func revealFiles(snippet *model.Snippet) []model.FileDiff {
+	var diffs []model.FileDiff
+	for _, f := range snippet.Files {
+		if !f.Editable {
+			continue
+		}
+		d := model.FileDiff{Path: f.Path, CorrectContent: f.Correct()}
+		if d.CorrectContent != f.Content {
+			d.Diff = unifiedDiff("buggy/"+f.Path, "correct/"+f.Path, f.Content, d.CorrectContent, revealDiffContext)
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+func TestValidFilePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"helpers.py", true},
+		{"pkg/util.go", true},
+		{"..data", true},
+		{".env", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"../secret", false},
+		{"/etc/passwd", false},
+		{"./helpers.py", false},
+		{"pkg/../helpers.py", false},
+		{"pkg//util.go", false},
+		{"pkg/", false},
+		{"pkg\\util.go", false},
+		{"util\x00.go", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := validFilePath(tt.path); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSubmissionFiles(t *testing.T) {
+	snippet := &model.Snippet{Files: model.SnippetFiles{
+		{Path: "helpers.py", Content: "def h(): return 1\n", Editable: true},
+		{Path: "data.py", Content: "DATA = [1]\n"},
+	}}
+	tests := []struct {
+		name    string
+		edits   []model.FileContent
+		want    []model.FileContent
+		wantErr error
+	}{
+		{
+			name: "no edits",
+			want: []model.FileContent{{Path: "helpers.py", Content: "def h(): return 1\n"}, {Path: "data.py", Content: "DATA = [1]\n"}},
+		},
+		{
+			name:  "editable file",
+			edits: []model.FileContent{{Path: "helpers.py", Content: "def h(): return 2\n"}},
+			want:  []model.FileContent{{Path: "helpers.py", Content: "def h(): return 2\n"}, {Path: "data.py", Content: "DATA = [1]\n"}},
+		},
+		{
+			name:    "read-only file",
+			edits:   []model.FileContent{{Path: "data.py", Content: "DATA = []\n"}},
+			wantErr: ErrInvalidFileEdit,
+		},
+		{
+			name:    "unknown file",
+			edits:   []model.FileContent{{Path: "other.py", Content: ""}},
+			wantErr: ErrInvalidFileEdit,
+		},
+		{
+			name:    "same file twice",
+			edits:   []model.FileContent{{Path: "helpers.py", Content: "a"}, {Path: "helpers.py", Content: "b"}},
+			wantErr: ErrInvalidFileEdit,
+		},
+		{
+			name:    "too large",
+			edits:   []model.FileContent{{Path: "helpers.py", Content: "0123456789abcdefghijk"}},
+			wantErr: ErrCodeTooLarge,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := submissionFiles(snippet, tt.edits, 20)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+
+	// Edits to a single-file snippet name files it doesn't have
+	if _, err := submissionFiles(&model.Snippet{}, []model.FileContent{{Path: "helpers.py"}}, 20); !errors.Is(err, ErrInvalidFileEdit) {
+		t.Fatalf("expected %v for a single-file snippet, got %v", ErrInvalidFileEdit, err)
+	}
+}
@@ -3,17 +3,29 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
 
+	"github.com/bugdrill/backend/internal/config"
 	"github.com/bugdrill/backend/internal/model"
 	"github.com/bugdrill/backend/internal/repository"
 	"github.com/redis/go-redis/v9"
 )
 
+var (
+	// ErrInvalidSnippet wraps validation failures for author-supplied snippets
+	ErrInvalidSnippet = errors.New("invalid snippet")
+	ErrCodeTooLarge   = errors.New("code exceeds the maximum snippet size")
+)
+
+// maxLogBytes caps code and output echoed into logs
+const maxLogBytes = 500
+
 type SnippetService struct {
+	cfg             *config.Config
 	snippetRepo     *repository.SnippetRepository
 	patternRepo     *repository.PatternRepository
 	redis           *redis.Client
@@ -21,12 +33,14 @@ type SnippetService struct {
 }
 
 func NewSnippetService(
+	cfg *config.Config,
 	snippetRepo *repository.SnippetRepository,
 	patternRepo *repository.PatternRepository,
 	redis *redis.Client,
 	executorService *ExecutorService,
 ) *SnippetService {
 	return &SnippetService{
+		cfg:             cfg,
 		snippetRepo:     snippetRepo,
 		patternRepo:     patternRepo,
 		redis:           redis,
@@ -60,8 +74,8 @@ func (s *SnippetService) GetPatterns() ([]model.PatternCategory, error) {
 	return patterns, nil
 }
 
-func (s *SnippetService) GetSnippetsByPattern(patternID int, difficulty string) ([]model.Snippet, error) {
-	return s.snippetRepo.GetByPatternID(patternID, difficulty)
+func (s *SnippetService) GetSnippetsByPattern(patternID int, difficulty, bugType string) ([]model.Snippet, error) {
+	return s.snippetRepo.GetByPatternID(patternID, difficulty, bugType)
 }
 
 func (s *SnippetService) GetSnippet(snippetID string) (*model.Snippet, error) {
@@ -90,9 +104,20 @@ func (s *SnippetService) GetSnippet(snippetID string) (*model.Snippet, error) {
 	return snippet, nil
 }
 
-func (s *SnippetService) ExecuteCode(snippetID, code, language string) (*model.ExecuteCodeResponse, error) {
+// GetSnippetAdmin returns a snippet whatever its status, so admins can review
+// drafts. Only active snippets are cached, so it goes to the database.
+func (s *SnippetService) GetSnippetAdmin(snippetID string) (*model.Snippet, error) {
+	return s.snippetRepo.GetByIDAnyStatus(snippetID)
+}
+
+func (s *SnippetService) ExecuteCode(ctx context.Context, snippetID string, req *model.ExecuteCodeRequest) (*model.ExecuteCodeResponse, error) {
+	code, language := req.Code, req.Language
 	log.Printf("🔵 ExecuteCode called: snippetID=%s, codeLength=%d, language=%s", snippetID, len(code), language)
 
+	if len(code) > s.cfg.App.MaxSnippetSize {
+		return nil, ErrCodeTooLarge
+	}
+
 	// Get snippet to access test cases
 	snippet, err := s.GetSnippet(snippetID)
 	if err != nil {
@@ -100,16 +125,149 @@ func (s *SnippetService) ExecuteCode(snippetID, code, language string) (*model.E
 		return nil, err
 	}
 
-	log.Printf("🔵 Snippet retrieved, calling executor service...")
+	files, err := submissionFiles(snippet, req.Files, s.cfg.App.MaxSnippetSize)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := s.resolveLimits(snippet, req.IsTrial)
+	// Unchanged code against unchanged tests gets the earlier verdict, unless
+	// a rerun could decide differently. Until an executor has reported how
+	// it runs code, nothing is looked up.
+	reusable := resultReusable(snippet, req)
+	if executor := s.executorService.RunnerVersion(); reusable && executor != "" {
+		if cached := s.cachedResult(ctx, resultCacheKey(snippet, req, limits, executor)); cached != nil {
+			log.Printf("✅ Returning cached result for snippet %s", snippetID)
+			cached.ExecutionID = fmt.Sprintf("exec_%d", time.Now().Unix())
+			cached.Cached = true
+			return cached, nil
+		}
+	}
+	log.Printf("🔵 Snippet retrieved, calling executor service with limits %+v...", limits)
+
+	run, err := s.runTests(ctx, snippet, code, language, files, limits, req.Coverage)
+	if err != nil {
+		return nil, err
+	}
+	execResp, testResults, allPassed, cacheable := run.resp, run.results, run.allPassed, run.cacheable
+
+	// Hand-written cases passed - look for a counterexample on random inputs
+	var fuzzResult *model.FuzzResult
+	if allPassed && snippet.FuzzSpec != nil {
+		seed := time.Now().UnixNano()
+		if req.FuzzSeed != nil {
+			seed = *req.FuzzSeed
+		}
+		fuzzResult = s.runFuzz(ctx, snippet, code, language, seed, limits)
+		// A run that couldn't be compared doesn't pass either
+		if !fuzzResult.Passed {
+			allPassed = false
+		}
+		if fuzzResult.Error != "" {
+			cacheable = false
+		}
+	}
+
+	// Correct but possibly too slow - time it against the reference solution.
+	// A check that couldn't be made doesn't pass.
+	var perfResult *model.PerfResult
+	if allPassed && snippet.PerfSpec != nil {
+		perfResult = s.runPerf(ctx, snippet, code, language, limits)
+		if !perfResult.Passed {
+			allPassed = false
+		}
+		if perfResult.Error != "" {
+			cacheable = false
+		}
+	}
+
+	// Passing isn't enough - the fix should be a small edit of the buggy code.
+	// Failing runs aren't compared, so edits can't be used to probe for the bug.
+	var edit *model.EditResult
+	if allPassed {
+		edit = s.analyzeEdit(ctx, snippet, code, language, files)
+		if edit != nil && snippet.EditPolicy != nil && snippet.EditPolicy.Granularity != edit.Granularity {
+			cacheable = false
+		}
+		allPassed = applyEditPolicy(snippet, edit)
+	}
+
+	// The client is gone; don't record a verdict built from aborted runs
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	isCorrect := allPassed
+
+	score := 0
+	if isCorrect {
+		score = 100
+		if edit != nil {
+			score -= edit.Penalty
+		}
+	}
+
+	response := &model.ExecuteCodeResponse{
+		ExecutionID:      fmt.Sprintf("exec_%d", time.Now().Unix()),
+		Status:           "completed",
+		IsCorrect:        isCorrect,
+		TestResults:      testResults,
+		TotalTimeMS:      execResp.ExecutionTime,
+		Stdout:           execResp.Stdout,
+		Stderr:           execResp.Stderr,
+		Fuzz:             fuzzResult,
+		Performance:      perfResult,
+		Edit:             edit,
+		Score:            score,
+		Limits:           &limits,
+		PolicyViolations: execResp.PolicyViolations,
+		Coverage:         run.coverage,
+	}
+	// Report what the executor enforced, which may be stricter than asked
+	if execResp.Limits != nil {
+		response.Limits = execResp.Limits
+	}
+
+	// The result is filed under the runner that produced it
+	if cacheable && reusable && execResp.RunnerVersion != "" {
+		s.cacheResult(ctx, resultCacheKey(snippet, req, limits, execResp.RunnerVersion), response)
+	}
+
+	return response, nil
+}
+
+// testRun is a submission's run against the snippet's tests
+type testRun struct {
+	// resp is the run of the code itself, or of the whole test file
+	resp      *ExecuteResponse
+	results   []model.TestResult
+	allPassed bool
+	// cacheable is false when an executor failure touched a result
+	cacheable bool
+	// coverage is that of all the tests together, when asked for
+	coverage *model.Coverage
+}
+
+// runTests runs code and grades it by the snippet's tests
+func (s *SnippetService) runTests(ctx context.Context, snippet *model.Snippet, code, language string, files []model.FileContent, limits model.ExecutionLimits, coverage bool) (*testRun, error) {
+	coverage = coverage && supportsCoverage(snippet)
+	// Results touched by an executor failure are not worth keeping
+	cacheable := true
 
 	// Execute code using executor service
-	execReq := ExecuteRequest{
-		Code:       code,
-		Language:   language,
-		TimeoutSec: 10,
+	execReq := execRequest(code, language, limits)
+	if hasTestFile(snippet) {
+		// Test files grade the whole submission in a single run
+		execReq = testFileRequest(snippet, code, limits)
+	} else if snippet.Language == "sql" {
+		execReq = sqlRequest(snippet, code, limits)
+	} else if snippet.TestFormat == model.TestFormatStdio {
+		execReq = stdioRequest(snippet, code, limits)
 	}
+	execReq.Files = files
+	execReq.Coverage = coverage
 
-	execResp, err := s.executorService.Execute(execReq)
+	execResp, err := s.executorService.Execute(ctx, execReq)
 	if err != nil {
 		log.Printf("❌ Executor service failed: %v", err)
 		return nil, fmt.Errorf("execution failed: %w", err)
@@ -121,7 +279,21 @@ func (s *SnippetService) ExecuteCode(snippetID, code, language string) (*model.E
 	testResults := []model.TestResult{}
 	allPassed := true
 
-	if !execResp.Success || execResp.ExitCode != 0 {
+	if hasTestFile(snippet) {
+		testResults, allPassed = testFileResults(execResp)
+	} else if snippet.Language == "sql" {
+		// The query's rows are checked against the reference query's
+		testResults, allPassed, err = s.gradeSQL(ctx, snippet, execResp, limits)
+		if err != nil {
+			return nil, fmt.Errorf("execution failed: %w", err)
+		}
+	} else if snippet.TestFormat == model.TestFormatStdio {
+		// Every case is a run of the program; the first one just happened
+		testResults, allPassed, err = s.gradeStdio(ctx, snippet, execReq, execResp)
+		if err != nil {
+			return nil, fmt.Errorf("execution failed: %w", err)
+		}
+	} else if !execResp.Success || execResp.ExitCode != 0 {
 		// Code failed to compile/run, all test cases fail
 		for i, tc := range snippet.TestCases {
 			testResults = append(testResults, model.TestResult{
@@ -131,26 +303,43 @@ func (s *SnippetService) ExecuteCode(snippetID, code, language string) (*model.E
 				Actual:          execResp.Stderr,
 				Passed:          false,
 				ExecutionTimeMS: execResp.ExecutionTime,
+				Usage:           execResp.Usage,
+			})
+		}
+		allPassed = false
+	} else if funcName := extractFunctionName(code, language); funcName == "" {
+		// Nothing to call, so no case can pass
+		for i, tc := range snippet.TestCases {
+			testResults = append(testResults, model.TestResult{
+				TestCase:        i + 1,
+				Input:           tc.Input,
+				Expected:        tc.Expected,
+				Actual:          "no top-level function defined to call",
+				Passed:          false,
+				ExecutionTimeMS: execResp.ExecutionTime,
+				Usage:           execResp.Usage,
 			})
 		}
 		allPassed = false
 	} else {
-		// Code ran successfully, extract function name and run each test case
-		funcName := extractFunctionName(code, language)
-
+		// Code ran successfully, run each test case against the function
+		params := functionParams(snippet.CorrectCode, snippet.Language)
 		for i, tc := range snippet.TestCases {
 			// Build test harness code that calls the function with test input
-			testCode := buildPythonTestHarness(code, funcName, tc.Input)
+			testCode := buildPythonTestHarness(code, funcName, params, tc.Input)
 			log.Printf("[TEST] Test case %d: funcName=%s", i+1, funcName)
-			log.Printf("[CODE] Generated test code:\n%s", testCode)
+			log.Printf("[CODE] Generated test code:\n%s", truncateForLog(testCode))
 
-			testExecReq := ExecuteRequest{
-				Code:       testCode,
-				Language:   language,
-				TimeoutSec: 10,
-			}
+			testExecReq := execRequest(testCode, language, limits)
+			testExecReq.Files = files
+			testExecReq.Coverage = coverage
 
-			testResp, err := s.executorService.Execute(testExecReq)
+			testResp, err := s.executorService.Execute(ctx, testExecReq)
+			// Only the submitted code failing counts against it
+			var unavailable *ExecutorUnavailableError
+			if ctx.Err() != nil || errors.As(err, &unavailable) {
+				return nil, fmt.Errorf("execution failed: %w", err)
+			}
 
 			var actualOutput interface{}
 			var passed bool
@@ -160,9 +349,10 @@ func (s *SnippetService) ExecuteCode(snippetID, code, language string) (*model.E
 				passed = false
 				if testResp != nil {
 					actualOutput = testResp.Stderr
-					log.Printf("[FAIL] Test case %d failed: %s", i+1, testResp.Stderr)
+					log.Printf("[FAIL] Test case %d failed: %s", i+1, truncateForLog(testResp.Stderr))
 				} else {
 					actualOutput = fmt.Sprintf("Error: %v", err)
+					cacheable = false
 					log.Printf("[ERROR] Test case %d error: %v", i+1, err)
 				}
 			} else {
@@ -173,17 +363,22 @@ func (s *SnippetService) ExecuteCode(snippetID, code, language string) (*model.E
 				expectedStr := string(expectedJSON)
 				actualStr := fmt.Sprintf("%v", actualOutput)
 				passed = compareOutputs(expectedStr, actualStr)
-				log.Printf("[RESULT] Test case %d: expected='%s', actual='%s', passed=%v", i+1, expectedStr, actualStr, passed)
+				log.Printf("[RESULT] Test case %d: expected='%s', actual='%s', passed=%v", i+1, truncateForLog(expectedStr), truncateForLog(actualStr), passed)
 			}
 
-			testResults = append(testResults, model.TestResult{
-				TestCase:        i + 1,
-				Input:           tc.Input,
-				Expected:        tc.Expected,
-				Actual:          actualOutput,
-				Passed:          passed,
-				ExecutionTimeMS: testResp.ExecutionTime,
-			})
+			result := model.TestResult{
+				TestCase: i + 1,
+				Input:    tc.Input,
+				Expected: tc.Expected,
+				Actual:   actualOutput,
+				Passed:   passed,
+			}
+			if testResp != nil {
+				result.ExecutionTimeMS = testResp.ExecutionTime
+				result.Usage = testResp.Usage
+				result.Coverage = lineCoverage(testResp.Coverage, codeLines(code))
+			}
+			testResults = append(testResults, result)
 
 			if !passed {
 				allPassed = false
@@ -191,22 +386,55 @@ func (s *SnippetService) ExecuteCode(snippetID, code, language string) (*model.E
 		}
 	}
 
-	isCorrect := allPassed
-
-	response := &model.ExecuteCodeResponse{
-		ExecutionID: fmt.Sprintf("exec_%d", time.Now().Unix()),
-		Status:      "completed",
-		IsCorrect:   isCorrect,
-		TestResults: testResults,
-		TotalTimeMS: execResp.ExecutionTime,
-		Stdout:      execResp.Stdout,
-		Stderr:      execResp.Stderr,
+	run := &testRun{resp: execResp, results: testResults, allPassed: allPassed, cacheable: cacheable}
+	switch {
+	case !coverage:
+	case hasTestFile(snippet):
+		// Each Go test is rerun alone for its own coverage; the first run's
+		// profile covers them together
+		run.coverage = lineCoverage(execResp.Coverage, 0)
+	default:
+		run.coverage = mergeCoverage(testResults)
 	}
-
-	return response, nil
+	return run, nil
 }
 
 func (s *SnippetService) CreateSnippet(snippet *model.Snippet) error {
+	if snippet.TestFormat == "" {
+		snippet.TestFormat = model.TestFormatCases
+		if snippet.Language == "go" {
+			snippet.TestFormat = model.TestFormatGoTest
+		}
+	}
+	if err := validateTestFormat(snippet, &s.cfg.App.MaxLimits); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnippet, err)
+	}
+	if err := validateSnippetFiles(snippet, s.cfg.App.MaxSnippetSize); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnippet, err)
+	}
+	if err := validateSQLSpec(snippet); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSnippet, err)
+	}
+	if snippet.FuzzSpec != nil {
+		if err := validateFuzzSpec(snippet.FuzzSpec); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnippet, err)
+		}
+	}
+	if snippet.PerfSpec != nil {
+		if err := validatePerfSpec(snippet.PerfSpec); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnippet, err)
+		}
+	}
+	if snippet.EditPolicy != nil {
+		if err := validateEditPolicy(snippet.EditPolicy); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnippet, err)
+		}
+	}
+	if snippet.Limits != nil {
+		if err := validateLimits(snippet.Limits, &s.cfg.App.MaxLimits); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSnippet, err)
+		}
+	}
 	return s.snippetRepo.Create(snippet)
 }
 
@@ -216,11 +444,12 @@ func extractFunctionName(code, language string) string {
 		return ""
 	}
 
-	// Look for "def functionName(" pattern
+	// Look for an unindented "def functionName(", skipping methods and
+	// nested helpers
 	lines := strings.Split(code, "\n")
 	for _, line := range lines {
 		trimmed := strings.TrimSpace(line)
-		if strings.HasPrefix(trimmed, "def ") {
+		if strings.HasPrefix(line, "def ") {
 			// Extract function name
 			parts := strings.Split(trimmed, "(")
 			if len(parts) > 0 {
@@ -233,27 +462,80 @@ func extractFunctionName(code, language string) string {
 	return ""
 }
 
-// Helper function to build Python test harness
-func buildPythonTestHarness(userCode, funcName string, testInput interface{}) string {
+// functionParams lists the positional parameters of the first top-level
+// function in code, in order, leaving out self and the / marker and stopping
+// at * or *args. Annotations and defaults are dropped.
+func functionParams(code, language string) []string {
+	name := extractFunctionName(code, language)
+	if name == "" {
+		return nil
+	}
+	start := strings.Index(code, "def "+name+"(")
+	if start < 0 {
+		return nil
+	}
+	start += len("def " + name + "(")
+
+	// Split on the commas outside brackets and strings, up to the closing
+	// parenthesis, which may be lines away
+	var params []string
+	var current strings.Builder
+	depth := 0
+	var quote rune
+	keywordOnly := false
+	add := func() {
+		param := current.String()
+		current.Reset()
+		if i := strings.IndexAny(param, ":="); i >= 0 {
+			param = param[:i]
+		}
+		param = strings.TrimSpace(param)
+		if strings.HasPrefix(param, "*") {
+			keywordOnly = true
+		}
+		if param != "" && param != "self" && param != "/" && !keywordOnly {
+			params = append(params, param)
+		}
+	}
+	for _, r := range code[start:] {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(' || r == '[' || r == '{':
+			depth++
+		case r == ']' || r == '}':
+			depth--
+		case r == ')':
+			if depth == 0 {
+				add()
+				return params
+			}
+			depth--
+		case r == ',' && depth == 0:
+			add()
+			continue
+		}
+		current.WriteRune(r)
+	}
+	return nil
+}
+
+// Helper function to build Python test harness. Inputs are passed in the
+// order of params, the reference solution's parameters.
+func buildPythonTestHarness(userCode, funcName string, params []string, testInput interface{}) string {
 	// Convert testInput map to Python function call
 	inputMap, ok := testInput.(map[string]interface{})
 	if !ok {
 		return userCode + "\nprint('Error: invalid test input')"
 	}
 
-	// Build function call arguments in order
-	// For now, hardcode common parameter order (nums, target)
-	// TODO: Make this more generic by parsing function signature
 	args := []string{}
-	if nums, ok := inputMap["nums"]; ok {
-		args = append(args, formatPythonValue(nums))
-	}
-	if target, ok := inputMap["target"]; ok {
-		args = append(args, formatPythonValue(target))
-	}
-	// For string parameter
-	if s, ok := inputMap["s"]; ok {
-		args = append(args, formatPythonValue(s))
+	for _, arg := range testCaseArgs(params, inputMap) {
+		args = append(args, formatPythonValue(arg))
 	}
 
 	// Create test harness
@@ -268,6 +550,62 @@ print(json.dumps(result))
 	return harness
 }
 
+// legacyParams are the input names early snippets were written for, passed
+// in this order whatever the function calls its parameters
+var legacyParams = []string{"nums", "target", "s"}
+
+// Helper to order test case inputs as function call arguments, following the
+// function's parameters. Inputs that don't name them fall back to
+// legacyParams.
+func testCaseArgs(params []string, inputMap map[string]interface{}) []interface{} {
+	args := []interface{}{}
+	if coversInput(params, inputMap) {
+		for _, name := range params {
+			v, ok := inputMap[name]
+			if !ok {
+				// The rest take their defaults
+				break
+			}
+			args = append(args, v)
+		}
+		return args
+	}
+	for _, name := range legacyParams {
+		if v, ok := inputMap[name]; ok {
+			args = append(args, v)
+		}
+	}
+	return args
+}
+
+// coversInput reports whether every input names one of params
+func coversInput(params []string, inputMap map[string]interface{}) bool {
+	if len(params) == 0 {
+		return false
+	}
+	for name := range inputMap {
+		found := false
+		for _, p := range params {
+			if p == name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Helper to keep generated harnesses and program output from flooding logs
+func truncateForLog(s string) string {
+	if len(s) <= maxLogBytes {
+		return s
+	}
+	return fmt.Sprintf("%s... (%d bytes truncated)", s[:maxLogBytes], len(s)-maxLogBytes)
+}
+
 // Helper to format Go values as Python literals
 func formatPythonValue(value interface{}) string {
 	switch v := value.(type) {
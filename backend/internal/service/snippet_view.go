@@ -0,0 +1,131 @@
+package service
+
+import (
+	"github.com/bugdrill/backend/internal/model"
+)
+
+// Viewer is the user a snippet is projected for
+type Viewer struct {
+	UserID string
+	Role   string
+}
+
+// ProjectSnippet returns the view of snippet that viewer may see. Admins get
+// the full snippet and authors everything about their own; learners only see
+// the answer once solveState is solved or given up.
+func ProjectSnippet(snippet *model.Snippet, viewer Viewer, solveState string) interface{} {
+	if viewer.Role == "admin" {
+		return snippet
+	}
+
+	learner := model.LearnerSnippet{
+		ID:                  snippet.ID,
+		PatternID:           snippet.PatternID,
+		Title:               snippet.Title,
+		Description:         snippet.Description,
+		Difficulty:          snippet.Difficulty,
+		Language:            snippet.Language,
+		BuggyCode:           snippet.BuggyCode,
+		Files:               learnerFiles(snippet),
+		TestCases:           snippet.TestCases,
+		TestFormat:          snippet.TestFormat,
+		TestCode:            snippet.TestCode,
+		SQLSpec:             snippet.SQLSpec,
+		Stdio:               snippet.Stdio,
+		RequireLocalization: snippet.RequireLocalization,
+		SolveState:          solveState,
+	}
+	for _, hint := range []string{snippet.Hint1, snippet.Hint2, snippet.Hint3} {
+		if hint != "" {
+			learner.HintsAvailable++
+		}
+	}
+
+	isAuthor := snippet.CreatedBy != nil && *snippet.CreatedBy == viewer.UserID
+	if !isAuthor && solveState == model.SolveStateUnsolved {
+		return learner
+	}
+
+	solved := model.SolvedSnippet{
+		LearnerSnippet: learner,
+		CorrectCode:    snippet.CorrectCode,
+		CorrectFiles:   correctFiles(snippet),
+		BugType:        snippet.BugType,
+		BugTypeID:      snippet.BugTypeID,
+		BugExplanation: snippet.BugExplanation,
+		Hint1:          snippet.Hint1,
+		Hint2:          snippet.Hint2,
+		Hint3:          snippet.Hint3,
+	}
+	if !isAuthor {
+		return solved
+	}
+
+	return model.AuthorSnippet{
+		SolvedSnippet: solved,
+		FuzzSpec:      snippet.FuzzSpec,
+		PerfSpec:      snippet.PerfSpec,
+		EditPolicy:    snippet.EditPolicy,
+		GoTest:        snippet.GoTest,
+		Status:        snippet.Status,
+		CreatedAt:     snippet.CreatedAt,
+		UpdatedAt:     snippet.UpdatedAt,
+	}
+}
+
+// CanSeeSolution reports whether viewer may see CorrectCode and anything
+// derived from it, following the same rules as ProjectSnippet
+func CanSeeSolution(snippet *model.Snippet, viewer Viewer, solveState string) bool {
+	isAuthor := snippet.CreatedBy != nil && *snippet.CreatedBy == viewer.UserID
+	return viewer.Role == "admin" || isAuthor || solveState != model.SolveStateUnsolved
+}
+
+// ProjectEdit hides where the bug is from viewers who may not see the
+// solution, leaving them only the verdict on their edit
+func ProjectEdit(edit *model.EditResult, showRegion bool) {
+	if edit == nil || showRegion {
+		return
+	}
+	edit.BugRegion, edit.TouchesBugRegion = nil, false
+	// The limit is derived from the reference fix and the reasons may name
+	// the bug region, so neither is shown
+	edit.MaxEditDistance = 0
+	if edit.IsRewrite {
+		edit.Message = "This submission is not a minimal fix. " + minimalEditHint
+	}
+	for i := range edit.Files {
+		edit.Files[i].BugRegion, edit.Files[i].TouchesBugRegion = nil, false
+	}
+}
+
+// VisibleBugTypes keeps the snippets whose bug type viewer may already see,
+// following CanSeeSolution. A bug type filter is narrowed to them, as a
+// learner would otherwise learn an unsolved snippet's bug type from whether
+// it matched.
+func VisibleBugTypes(snippets []model.Snippet, viewer Viewer, states map[string]string) []model.Snippet {
+	visible := snippets[:0:0]
+	for i := range snippets {
+		state, ok := states[snippets[i].ID]
+		if !ok {
+			state = model.SolveStateUnsolved
+		}
+		if CanSeeSolution(&snippets[i], viewer, state) {
+			visible = append(visible, snippets[i])
+		}
+	}
+	return visible
+}
+
+// ProjectSnippets projects a list, looking up each snippet's solve state in
+// states and treating missing entries as unsolved
+func ProjectSnippets(snippets []model.Snippet, viewer Viewer, states map[string]string) []interface{} {
+	views := make([]interface{}, len(snippets))
+	for i := range snippets {
+		state, ok := states[snippets[i].ID]
+		if !ok {
+			state = model.SolveStateUnsolved
+		}
+		views[i] = ProjectSnippet(&snippets[i], viewer, state)
+	}
+	return views
+}
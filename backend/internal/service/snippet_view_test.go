@@ -0,0 +1,83 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+func TestProjectEdit(t *testing.T) {
+	author := "author"
+	snippet := &model.Snippet{CreatedBy: &author}
+	tests := []struct {
+		name       string
+		viewer     Viewer
+		state      string
+		wantRegion bool
+	}{
+		{"learner before solving", Viewer{UserID: "learner"}, model.SolveStateUnsolved, false},
+		{"learner after solving", Viewer{UserID: "learner"}, model.SolveStateSolved, true},
+		{"learner after giving up", Viewer{UserID: "learner"}, model.SolveStateGivenUp, true},
+		{"author", Viewer{UserID: author}, model.SolveStateUnsolved, true},
+		{"admin", Viewer{UserID: "admin", Role: "admin"}, model.SolveStateUnsolved, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message := "This submission leaves the buggy lines untouched. " + minimalEditHint
+			edit := &model.EditResult{
+				MaxEditDistance:  4,
+				BugRegion:        []int{3},
+				TouchesBugRegion: true,
+				IsRewrite:        true,
+				Message:          message,
+				Files:            []model.FileEditResult{{Path: "helpers.py", BugRegion: []int{1}, TouchesBugRegion: true}},
+			}
+			ProjectEdit(edit, CanSeeSolution(snippet, tt.viewer, tt.state))
+
+			shown := edit.BugRegion != nil && edit.TouchesBugRegion
+			fileShown := edit.Files[0].BugRegion != nil && edit.Files[0].TouchesBugRegion
+			if shown != tt.wantRegion || fileShown != tt.wantRegion {
+				t.Fatalf("expected the bug region shown=%v, got %v and %v for the file", tt.wantRegion, shown, fileShown)
+			}
+			if detailed := edit.MaxEditDistance == 4 && edit.Message == message; detailed != tt.wantRegion {
+				t.Fatalf("expected the limit and reasons shown=%v, got %d and %q", tt.wantRegion, edit.MaxEditDistance, edit.Message)
+			}
+			if !edit.IsRewrite {
+				t.Fatalf("expected the verdict kept")
+			}
+		})
+	}
+}
+
+func TestVisibleBugTypes(t *testing.T) {
+	author := "author"
+	snippets := []model.Snippet{
+		{ID: "unsolved"},
+		{ID: "solved"},
+		{ID: "given-up"},
+		{ID: "authored", CreatedBy: &author},
+	}
+	states := map[string]string{"solved": model.SolveStateSolved, "given-up": model.SolveStateGivenUp}
+	tests := []struct {
+		name   string
+		viewer Viewer
+		want   []string
+	}{
+		{"learner", Viewer{UserID: "learner"}, []string{"solved", "given-up"}},
+		{"author", Viewer{UserID: author}, []string{"solved", "given-up", "authored"}},
+		{"admin", Viewer{UserID: "admin", Role: "admin"}, []string{"unsolved", "solved", "given-up", "authored"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			visible := VisibleBugTypes(snippets, tt.viewer, states)
+			if len(visible) != len(tt.want) {
+				t.Fatalf("expected %d snippets, got %d", len(tt.want), len(visible))
+			}
+			for i, s := range visible {
+				if s.ID != tt.want[i] {
+					t.Fatalf("expected %s at %d, got %s", tt.want[i], i, s.ID)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+// validateSQLSpec checks a SQL snippet has a database to run against, and
+// that nothing else does
+func validateSQLSpec(snippet *model.Snippet) error {
+	spec := snippet.SQLSpec
+	if snippet.Language != "sql" {
+		if spec != nil {
+			return fmt.Errorf("sql_spec only applies to sql snippets")
+		}
+		return nil
+	}
+	if spec == nil || strings.TrimSpace(spec.Schema) == "" {
+		return fmt.Errorf("sql snippets need a sql_spec with a schema")
+	}
+	if spec.MaxRows < 0 || spec.TimeoutMS < 0 {
+		return fmt.Errorf("sql_spec max_rows and timeout_ms must not be negative")
+	}
+	if snippet.FuzzSpec != nil || snippet.PerfSpec != nil || len(snippet.Files) > 0 {
+		return fmt.Errorf("fuzz_spec, perf_spec and files are not supported for sql snippets")
+	}
+	return nil
+}
+
+// sqlRequest runs query against a fresh copy of the snippet's database
+func sqlRequest(snippet *model.Snippet, query string, limits model.ExecutionLimits) ExecuteRequest {
+	req := execRequest(query, "sql", limits)
+	req.SQL = snippet.SQLSpec
+	return req
+}
+
+// gradeSQL runs CorrectCode on the same data as the submission and compares
+// the two result sets. Column names are not compared, so aliases don't
+// matter.
+func (s *SnippetService) gradeSQL(ctx context.Context, snippet *model.Snippet, learner *ExecuteResponse, limits model.ExecutionLimits) ([]model.TestResult, bool, error) {
+	ref, err := s.executorService.Execute(ctx, sqlRequest(snippet, snippet.CorrectCode, limits))
+	if err != nil {
+		return nil, false, err
+	}
+
+	result := model.TestResult{
+		TestCase:        1,
+		Name:            "result set",
+		ExecutionTimeMS: learner.ExecutionTime,
+		Usage:           learner.Usage,
+	}
+	switch {
+	case ref.ResultSet == nil:
+		log.Printf("[ERROR] SQL reference query failed: %s", truncateForLog(ref.Stderr))
+		result.Actual = learner.Stderr
+		result.Errors = []model.TestError{{Kind: "error", Message: "reference query failed"}}
+	case learner.ResultSet == nil:
+		result.Expected = ref.ResultSet
+		result.Actual = learner.Stderr
+		result.Errors = []model.TestError{{Kind: "error", Message: lastLine(learner.Stderr)}}
+	default:
+		result.Expected = ref.ResultSet
+		result.Actual = learner.ResultSet
+		if msg := compareResultSets(ref.ResultSet, learner.ResultSet, snippet.SQLSpec.Ordered); msg != "" {
+			result.Errors = []model.TestError{{Kind: "failure", Message: msg}}
+		} else {
+			result.Passed = true
+		}
+	}
+	return []model.TestResult{result}, result.Passed, nil
+}
+
+// compareResultSets explains the first difference between two result sets,
+// or returns "" when they match. Unordered sets are compared as multisets.
+func compareResultSets(expected, actual *model.ResultSet, ordered bool) string {
+	if len(expected.Columns) != len(actual.Columns) {
+		return fmt.Sprintf("expected %d columns, got %d", len(expected.Columns), len(actual.Columns))
+	}
+	if len(expected.Rows) != len(actual.Rows) {
+		return fmt.Sprintf("expected %d rows, got %d", len(expected.Rows), len(actual.Rows))
+	}
+
+	want, got := canonicalRows(expected.Rows), canonicalRows(actual.Rows)
+	counts := map[string]int{}
+	for _, row := range want {
+		counts[row]++
+	}
+	for _, row := range got {
+		counts[row]--
+	}
+	for _, row := range want {
+		if counts[row] > 0 {
+			return "missing row " + row
+		}
+	}
+	for _, row := range got {
+		if counts[row] < 0 {
+			return "unexpected row " + row
+		}
+	}
+
+	if ordered {
+		for i := range want {
+			if want[i] != got[i] {
+				return fmt.Sprintf("rows are right but out of order: row %d is %s, expected %s", i+1, got[i], want[i])
+			}
+		}
+	}
+	return ""
+}
+
+// canonicalRows renders rows as JSON, so 1 and 1.0 compare equal as they
+// both decode to the same number
+func canonicalRows(rows [][]interface{}) []string {
+	out := make([]string, len(rows))
+	for i, row := range rows {
+		data, _ := json.Marshal(row)
+		out[i] = string(data)
+	}
+	return out
+}
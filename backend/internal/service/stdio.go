@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+// validateStdio checks a program snippet has cases to feed it, each expecting
+// printed output, and that only program snippets say how output is compared
+func validateStdio(snippet *model.Snippet) error {
+	spec := snippet.Stdio
+	if snippet.TestFormat != model.TestFormatStdio {
+		if spec != nil {
+			return fmt.Errorf("stdio only applies to test_format %q", model.TestFormatStdio)
+		}
+		return nil
+	}
+
+	if snippet.Language != "python" {
+		return fmt.Errorf("test_format %q needs a python snippet", model.TestFormatStdio)
+	}
+	if len(snippet.TestCases) == 0 {
+		return fmt.Errorf("test_format %q needs test_cases", model.TestFormatStdio)
+	}
+	for i, tc := range snippet.TestCases {
+		if _, ok := tc.Expected.(string); !ok {
+			return fmt.Errorf("test case %d: expected must be the program's output as a string", i+1)
+		}
+	}
+	if snippet.FuzzSpec != nil || snippet.PerfSpec != nil {
+		return fmt.Errorf("fuzz_spec and perf_spec are not supported for test_format %q", model.TestFormatStdio)
+	}
+
+	if spec == nil {
+		return nil
+	}
+	switch spec.Whitespace {
+	case "", model.StdioExact, model.StdioLines, model.StdioTokens:
+	default:
+		return fmt.Errorf("unknown stdio whitespace %q", spec.Whitespace)
+	}
+	if spec.FloatTolerance < 0 || math.IsNaN(spec.FloatTolerance) {
+		return fmt.Errorf("stdio float_tolerance must not be negative")
+	}
+	if spec.FloatTolerance > 0 && spec.Whitespace != model.StdioTokens {
+		return fmt.Errorf("stdio float_tolerance needs whitespace %q", model.StdioTokens)
+	}
+	return nil
+}
+
+// stdioRequest runs code as a program fed the first test case's stdin
+func stdioRequest(snippet *model.Snippet, code string, limits model.ExecutionLimits) ExecuteRequest {
+	req := execRequest(code, snippet.Language, limits)
+	req.Stdin = snippet.TestCases[0].Stdin
+	return req
+}
+
+// gradeStdio runs the program once per test case with the case's stdin and
+// compares what it printed. first is the run of the first case if it has
+// already been made.
+func (s *SnippetService) gradeStdio(ctx context.Context, snippet *model.Snippet, req ExecuteRequest, first *ExecuteResponse) ([]model.TestResult, bool, error) {
+	results := make([]model.TestResult, 0, len(snippet.TestCases))
+	allPassed := true
+	for i, tc := range snippet.TestCases {
+		resp := first
+		if i > 0 || resp == nil {
+			req.Stdin = tc.Stdin
+			var err error
+			resp, err = s.executorService.Execute(ctx, req)
+			if err != nil {
+				return nil, false, err
+			}
+		}
+
+		result := model.TestResult{
+			TestCase:        i + 1,
+			Input:           tc.Stdin,
+			Expected:        tc.Expected,
+			Actual:          resp.Stdout,
+			ExecutionTimeMS: resp.ExecutionTime,
+			Usage:           resp.Usage,
+			Coverage:        lineCoverage(resp.Coverage, 0),
+		}
+		expected, _ := tc.Expected.(string)
+		if !resp.Success || resp.ExitCode != 0 {
+			result.Actual = resp.Stderr
+			result.Errors = []model.TestError{{Kind: "error", Message: programError(resp)}}
+		} else if msg := compareStdout(expected, resp.Stdout, snippet.Stdio); msg != "" {
+			result.Errors = []model.TestError{{Kind: "failure", Message: msg}}
+		} else {
+			result.Passed = true
+		}
+		results = append(results, result)
+		allPassed = allPassed && result.Passed
+	}
+	return results, allPassed, nil
+}
+
+// programError is why a program run failed, usually its exception
+func programError(resp *ExecuteResponse) string {
+	if msg := lastLine(resp.Stderr); msg != "" {
+		return msg
+	}
+	if resp.Error != "" {
+		return resp.Error
+	}
+	return fmt.Sprintf("exited with code %d", resp.ExitCode)
+}
+
+// compareStdout explains the first difference between the expected and the
+// actual output under the spec's whitespace rules, or returns "" when they
+// match
+func compareStdout(expected, actual string, spec *model.StdioSpec) string {
+	mode, tolerance := model.StdioLines, 0.0
+	if spec != nil {
+		if spec.Whitespace != "" {
+			mode = spec.Whitespace
+		}
+		tolerance = spec.FloatTolerance
+	}
+
+	switch mode {
+	case model.StdioExact:
+		if expected == actual {
+			return ""
+		}
+		if strings.TrimRight(expected, "\n") == strings.TrimRight(actual, "\n") {
+			return "output differs only in trailing newlines"
+		}
+		return compareLines(strings.Split(expected, "\n"), strings.Split(actual, "\n"))
+	case model.StdioTokens:
+		return compareTokens(strings.Fields(expected), strings.Fields(actual), tolerance)
+	default:
+		return compareLines(normalizedLines(expected), normalizedLines(actual))
+	}
+}
+
+// normalizedLines drops line endings, trailing spaces and trailing blank
+// lines, which don't show when the output is printed
+func normalizedLines(output string) []string {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+func compareLines(expected, actual []string) string {
+	for i := 0; i < len(expected) && i < len(actual); i++ {
+		if expected[i] != actual[i] {
+			return fmt.Sprintf("line %d: expected %q, got %q", i+1, expected[i], actual[i])
+		}
+	}
+	switch {
+	case len(actual) > len(expected):
+		return fmt.Sprintf("unexpected extra output at line %d: %q", len(expected)+1, actual[len(expected)])
+	case len(actual) < len(expected):
+		return fmt.Sprintf("output ends early: line %d should be %q", len(actual)+1, expected[len(actual)])
+	}
+	return ""
+}
+
+func compareTokens(expected, actual []string, tolerance float64) string {
+	for i := 0; i < len(expected) && i < len(actual); i++ {
+		if !tokensMatch(expected[i], actual[i], tolerance) {
+			return fmt.Sprintf("token %d: expected %q, got %q", i+1, expected[i], actual[i])
+		}
+	}
+	if len(expected) != len(actual) {
+		return fmt.Sprintf("expected %d tokens, got %d", len(expected), len(actual))
+	}
+	return ""
+}
+
+// tokensMatch compares numbers within tolerance, absolute or relative to the
+// expected value, and everything else exactly
+func tokensMatch(expected, actual string, tolerance float64) bool {
+	if expected == actual {
+		return true
+	}
+	if tolerance == 0 {
+		return false
+	}
+	want, err := strconv.ParseFloat(expected, 64)
+	if err != nil {
+		return false
+	}
+	got, err := strconv.ParseFloat(actual, 64)
+	if err != nil || math.IsNaN(got) {
+		return false
+	}
+	diff := math.Abs(want - got)
+	return diff <= tolerance || diff <= tolerance*math.Abs(want)
+}
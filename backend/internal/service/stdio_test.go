@@ -0,0 +1,69 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+func TestCompareStdout(t *testing.T) {
+	exact := &model.StdioSpec{Whitespace: model.StdioExact}
+	tokens := &model.StdioSpec{Whitespace: model.StdioTokens}
+	tolerant := &model.StdioSpec{Whitespace: model.StdioTokens, FloatTolerance: 1e-6}
+	tests := []struct {
+		name     string
+		expected string
+		actual   string
+		spec     *model.StdioSpec
+		want     string
+	}{
+		{"lines by default", "1\n2\n", "1  \r\n2\n\n\n", nil, ""},
+		{"lines differ", "1\n2\n", "1\n3\n", nil, `line 2: expected "2", got "3"`},
+		{"leading spaces count in lines", "a\n", "  a\n", &model.StdioSpec{Whitespace: model.StdioLines}, `line 1: expected "a", got "  a"`},
+		{"extra output", "1\n", "1\n2\n", nil, `unexpected extra output at line 2: "2"`},
+		{"output ends early", "1\n2\n", "1\n", nil, `output ends early: line 2 should be "2"`},
+		{"exact match", "1 2\n", "1 2\n", exact, ""},
+		{"exact trailing newline", "1\n", "1\n\n", exact, "output differs only in trailing newlines"},
+		{"exact trailing space", "1\n", "1 \n", exact, `line 1: expected "1", got "1 "`},
+		{"tokens ignore layout", "1 2\n3\n", "1\n2 3", tokens, ""},
+		{"tokens differ", "1 2 3", "1 2 4", tokens, `token 3: expected "3", got "4"`},
+		{"token count differs", "1 2", "1 2 3", tokens, "expected 2 tokens, got 3"},
+		{"floats within tolerance", "0.333333", "0.3333333333", tolerant, ""},
+		{"floats need a tolerance", "0.333333", "0.3333333333", tokens, `token 1: expected "0.333333", got "0.3333333333"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := compareStdout(tt.expected, tt.actual, tt.spec); got != tt.want {
+				t.Fatalf("expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestTokensMatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		expected  string
+		actual    string
+		tolerance float64
+		want      bool
+	}{
+		{"identical words", "yes", "yes", 0, true},
+		{"different words", "yes", "YES", 0.1, false},
+		{"numbers without tolerance", "1.0", "1", 0, false},
+		{"absolute tolerance", "0.5", "0.50001", 1e-4, true},
+		{"outside absolute tolerance", "0.5", "0.501", 1e-4, false},
+		{"relative tolerance", "1000000", "1000000.5", 1e-6, true},
+		{"exponent notation", "1e3", "1000", 1e-9, true},
+		{"word against number", "1", "one", 1, false},
+		{"nan never matches a number", "1", "nan", 1, false},
+		{"infinity is not close", "1e308", "inf", 1e-6, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tokensMatch(tt.expected, tt.actual, tt.tolerance); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
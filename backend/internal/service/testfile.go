@@ -0,0 +1,109 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+// hasTestFile reports whether the snippet is graded by a test file rather
+// than by its input/expected test cases
+func hasTestFile(snippet *model.Snippet) bool {
+	return snippet.TestFormat == model.TestFormatPytest || snippet.TestFormat == model.TestFormatGoTest
+}
+
+// testFileRequest runs the snippet's test file against code. Go tests are
+// built with -race and checked for leaked goroutines; pytest tests import
+// the code as solution.py.
+func testFileRequest(snippet *model.Snippet, code string, limits model.ExecutionLimits) ExecuteRequest {
+	req := execRequest(code, snippet.Language, limits)
+	req.TestCode = snippet.TestCode
+	req.TestFormat = snippet.TestFormat
+	req.GoTest = snippet.GoTest
+	return req
+}
+
+// testFileResults turns the executor's per-test report into test results. A
+// run that reported no tests, such as one that failed to build or timed out,
+// fails as a whole.
+func testFileResults(resp *ExecuteResponse) ([]model.TestResult, bool) {
+	results := make([]model.TestResult, 0, len(resp.TestResults))
+	allPassed := resp.Success && len(resp.TestResults) > 0
+	for i, tr := range resp.TestResults {
+		results = append(results, model.TestResult{
+			TestCase:        i + 1,
+			Name:            tr.Name,
+			Actual:          tr.Output,
+			Passed:          tr.Passed,
+			ExecutionTimeMS: tr.ElapsedMS,
+			Errors:          tr.Errors,
+			Coverage:        lineCoverage(tr.Coverage, 0),
+		})
+		if !tr.Passed {
+			allPassed = false
+		}
+	}
+
+	if len(results) == 0 {
+		actual := resp.Stderr
+		if actual == "" {
+			actual = resp.Error
+		}
+		results = append(results, model.TestResult{
+			TestCase:        1,
+			Name:            "build",
+			Actual:          actual,
+			ExecutionTimeMS: resp.ExecutionTime,
+			Usage:           resp.Usage,
+		})
+	}
+	return results, allPassed
+}
+
+// validateTestFormat checks the snippet has what its test format grades
+// with. Go snippets are always graded by go test.
+func validateTestFormat(snippet *model.Snippet, maxLimits *model.ExecutionLimits) error {
+	switch snippet.TestFormat {
+	case model.TestFormatCases:
+		if snippet.Language == "go" {
+			return fmt.Errorf("go snippets need test_format %q", model.TestFormatGoTest)
+		}
+		if snippet.Language == "python" && extractFunctionName(snippet.CorrectCode, snippet.Language) == "" {
+			return fmt.Errorf("correct_code defines no top-level function to call; use test_format %q for programs", model.TestFormatStdio)
+		}
+	case model.TestFormatStdio:
+		// validateStdio checks the rest
+	case model.TestFormatPytest:
+		if snippet.Language != "python" {
+			return fmt.Errorf("test_format %q needs a python snippet", model.TestFormatPytest)
+		}
+		if snippet.TestCode == "" {
+			return fmt.Errorf("test_format %q needs test_code", model.TestFormatPytest)
+		}
+	case model.TestFormatGoTest:
+		if snippet.Language != "go" {
+			return fmt.Errorf("test_format %q needs a go snippet", model.TestFormatGoTest)
+		}
+		if snippet.TestCode == "" {
+			return fmt.Errorf("test_format %q needs test_code", model.TestFormatGoTest)
+		}
+		if snippet.FuzzSpec != nil || snippet.PerfSpec != nil {
+			return fmt.Errorf("fuzz_spec and perf_spec are not supported for go snippets")
+		}
+	default:
+		return fmt.Errorf("unknown test_format %q", snippet.TestFormat)
+	}
+
+	if spec := snippet.GoTest; spec != nil {
+		if snippet.TestFormat != model.TestFormatGoTest {
+			return fmt.Errorf("go_test only applies to test_format %q", model.TestFormatGoTest)
+		}
+		if spec.Count < 0 {
+			return fmt.Errorf("go_test count must not be negative")
+		}
+		if spec.TimeoutSec < 0 || spec.TimeoutSec > maxLimits.TimeoutSec {
+			return fmt.Errorf("go_test timeout_sec must be between 0 and %d", maxLimits.TimeoutSec)
+		}
+	}
+	return validateStdio(snippet)
+}
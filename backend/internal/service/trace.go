@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bugdrill/backend/internal/model"
+)
+
+var (
+	ErrNoSuchTestCase   = errors.New("no such test case")
+	ErrTraceUnsupported = errors.New("tracing is only supported for python snippets")
+	// ErrTraceNeedsFunction is returned for programs, which have no function
+	// call to trace
+	ErrTraceNeedsFunction = errors.New("tracing needs a function to call; programs can't be traced")
+)
+
+// Budgets for one traced run; the executor also keeps the trace within half
+// the output limit
+const (
+	traceMaxSteps      = 500
+	traceMaxValueBytes = 200
+)
+
+// TraceOptions ask the executor to record every step of the code's functions
+type TraceOptions struct {
+	MaxSteps      int `json:"max_steps"`
+	MaxBytes      int `json:"max_bytes,omitempty"`
+	MaxValueBytes int `json:"max_value_bytes"`
+}
+
+// executorTrace is the timeline as the executor reports it
+type executorTrace struct {
+	Steps     []model.TraceStep `json:"steps"`
+	Truncated bool              `json:"truncated"`
+}
+
+// TraceCode runs one test case of req.Code with line tracing and, if asked,
+// the reference solution too. The reference timeline is only returned when
+// showReference is set.
+func (s *SnippetService) TraceCode(ctx context.Context, snippet *model.Snippet, req *model.TraceRequest, showReference bool) (*model.TraceResult, error) {
+	if len(req.Code) > s.cfg.App.MaxSnippetSize {
+		return nil, ErrCodeTooLarge
+	}
+	// The reference runs as the snippet's language, so the code must match it
+	if req.Language != "python" || req.Language != snippet.Language {
+		return nil, ErrTraceUnsupported
+	}
+	if len(snippet.Files) > 0 {
+		return nil, fmt.Errorf("tracing is %w", ErrMultiFileUnsupported)
+	}
+	if snippet.TestFormat == model.TestFormatStdio {
+		return nil, ErrTraceNeedsFunction
+	}
+	if req.TestCase > len(snippet.TestCases) {
+		return nil, ErrNoSuchTestCase
+	}
+	tc := snippet.TestCases[req.TestCase-1]
+	limits := s.resolveLimits(snippet, req.IsTrial)
+
+	params := functionParams(snippet.CorrectCode, snippet.Language)
+	learner, err := s.runTrace(ctx, req.Code, req.Language, params, tc, limits)
+	if err != nil {
+		return nil, err
+	}
+	result := &model.TraceResult{
+		SnippetID: snippet.ID,
+		TestCase:  req.TestCase,
+		Input:     tc.Input,
+		Expected:  tc.Expected,
+		Learner:   *learner,
+	}
+
+	if req.CompareReference {
+		reference, err := s.runTrace(ctx, snippet.CorrectCode, snippet.Language, params, tc, limits)
+		if err != nil {
+			return nil, err
+		}
+		result.Divergence = firstDivergence(learner.Steps, reference.Steps)
+		if showReference {
+			result.Reference = reference
+		}
+	}
+	return result, nil
+}
+
+func (s *SnippetService) runTrace(ctx context.Context, code, language string, params []string, tc model.TestCase, limits model.ExecutionLimits) (*model.Trace, error) {
+	harness := buildPythonTestHarness(code, extractFunctionName(code, language), params, tc.Input)
+	req := execRequest(harness, language, limits)
+	req.Trace = &TraceOptions{MaxSteps: traceMaxSteps, MaxValueBytes: traceMaxValueBytes}
+
+	resp, err := s.executorService.Execute(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("trace failed: %w", err)
+	}
+
+	trace := &model.Trace{
+		Steps:  []model.TraceStep{},
+		Output: strings.TrimSpace(resp.Stdout),
+	}
+	if resp.Trace != nil {
+		trace.Steps = resp.Trace.Steps
+		trace.Truncated = resp.Trace.Truncated
+	}
+	for i := range trace.Steps {
+		trace.Steps[i].Step = i + 1
+	}
+	if !resp.Success {
+		trace.Error = lastLine(resp.Stderr)
+		if trace.Error == "" {
+			trace.Error = resp.Error
+		}
+	}
+	return trace, nil
+}
+
+// firstDivergence walks both timelines in lockstep and reports the first
+// step where control flow, a local variable or a returned value differs.
+// Line numbers are not compared since the fix may move lines.
+func firstDivergence(learner, reference []model.TraceStep) *model.TraceDivergence {
+	for i := 0; i < len(learner) && i < len(reference); i++ {
+		l, r := learner[i], reference[i]
+		d := &model.TraceDivergence{Step: i + 1, LearnerLine: l.Line, ReferenceLine: r.Line}
+
+		if l.Function != r.Function || l.Event != r.Event {
+			d.Reason = "flow"
+			d.Learner, d.Reference = l.Event+" "+l.Function, r.Event+" "+r.Function
+			return d
+		}
+
+		names := make([]string, 0, len(l.Locals)+len(r.Locals))
+		for name := range l.Locals {
+			names = append(names, name)
+		}
+		for name := range r.Locals {
+			if _, ok := l.Locals[name]; !ok {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			lv, lok := l.Locals[name]
+			rv, rok := r.Locals[name]
+			if lok != rok || lv != rv {
+				d.Reason, d.Variable = "variable", name
+				d.Learner, d.Reference = lv, rv
+				return d
+			}
+		}
+
+		if l.Value != r.Value {
+			d.Reason = "value"
+			d.Learner, d.Reference = l.Value, r.Value
+			return d
+		}
+	}
+
+	if len(learner) == len(reference) {
+		return nil
+	}
+	n := min(len(learner), len(reference))
+	d := &model.TraceDivergence{Step: n + 1, Reason: "ended"}
+	if n < len(learner) {
+		d.LearnerLine = learner[n].Line
+	}
+	if n < len(reference) {
+		d.ReferenceLine = reference[n].Line
+	}
+	return d
+}
@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bugdrill/backend/internal/config"
+	"github.com/bugdrill/backend/internal/model"
+)
+
+func TestTraceCodeLanguage(t *testing.T) {
+	s := &SnippetService{cfg: &config.Config{App: config.AppConfig{MaxSnippetSize: 10000}}}
+	tests := []struct {
+		name            string
+		snippetLanguage string
+		language        string
+	}{
+		{"go code", "go", "go"},
+		{"python code for a sql snippet", "sql", "python"},
+		{"sql code for a python snippet", "python", "sql"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snippet := &model.Snippet{Language: tt.snippetLanguage, TestCases: model.TestCases{{}}}
+			req := &model.TraceRequest{Code: "x", Language: tt.language, TestCase: 1}
+			if _, err := s.TraceCode(context.Background(), snippet, req, false); !errors.Is(err, ErrTraceUnsupported) {
+				t.Fatalf("expected %v, got %v", ErrTraceUnsupported, err)
+			}
+		})
+	}
+}
+
+func TestFirstDivergence(t *testing.T) {
+	call := model.TraceStep{Line: 1, Event: "call", Function: "f", Locals: map[string]string{"n": "3"}}
+	line := func(lineNo int, locals map[string]string) model.TraceStep {
+		return model.TraceStep{Line: lineNo, Event: "line", Function: "f", Locals: locals}
+	}
+	ret := func(lineNo int, value string) model.TraceStep {
+		return model.TraceStep{Line: lineNo, Event: "return", Function: "f", Locals: map[string]string{"n": "3"}, Value: value}
+	}
+
+	tests := []struct {
+		name      string
+		learner   []model.TraceStep
+		reference []model.TraceStep
+		want      *model.TraceDivergence
+	}{
+		{
+			name:      "same run",
+			learner:   []model.TraceStep{call, ret(2, "6")},
+			reference: []model.TraceStep{call, ret(2, "6")},
+		},
+		{
+			name:      "moved lines are not a divergence",
+			learner:   []model.TraceStep{call, ret(5, "6")},
+			reference: []model.TraceStep{call, ret(2, "6")},
+		},
+		{
+			name:      "different flow",
+			learner:   []model.TraceStep{call, ret(2, "6")},
+			reference: []model.TraceStep{call, line(2, map[string]string{"n": "3"}), ret(3, "6")},
+			want:      &model.TraceDivergence{Step: 2, Reason: "flow", LearnerLine: 2, ReferenceLine: 2, Learner: "return f", Reference: "line f"},
+		},
+		{
+			name:      "different variable",
+			learner:   []model.TraceStep{call, line(2, map[string]string{"n": "3", "i": "0"})},
+			reference: []model.TraceStep{call, line(2, map[string]string{"n": "3", "i": "1"})},
+			want:      &model.TraceDivergence{Step: 2, Reason: "variable", LearnerLine: 2, ReferenceLine: 2, Variable: "i", Learner: "0", Reference: "1"},
+		},
+		{
+			name:      "variable only on one side",
+			learner:   []model.TraceStep{call, line(2, map[string]string{"n": "3"})},
+			reference: []model.TraceStep{call, line(2, map[string]string{"n": "3", "acc": "0"})},
+			want:      &model.TraceDivergence{Step: 2, Reason: "variable", LearnerLine: 2, ReferenceLine: 2, Variable: "acc", Reference: "0"},
+		},
+		{
+			name:      "first differing variable by name",
+			learner:   []model.TraceStep{line(4, map[string]string{"b": "1", "a": "1"})},
+			reference: []model.TraceStep{line(4, map[string]string{"b": "2", "a": "2"})},
+			want:      &model.TraceDivergence{Step: 1, Reason: "variable", LearnerLine: 4, ReferenceLine: 4, Variable: "a", Learner: "1", Reference: "2"},
+		},
+		{
+			name:      "different return value",
+			learner:   []model.TraceStep{call, ret(2, "5")},
+			reference: []model.TraceStep{call, ret(2, "6")},
+			want:      &model.TraceDivergence{Step: 2, Reason: "value", LearnerLine: 2, ReferenceLine: 2, Learner: "5", Reference: "6"},
+		},
+		{
+			name:      "learner stops early",
+			learner:   []model.TraceStep{call},
+			reference: []model.TraceStep{call, ret(2, "6")},
+			want:      &model.TraceDivergence{Step: 2, Reason: "ended", ReferenceLine: 2},
+		},
+		{
+			name:      "learner runs on",
+			learner:   []model.TraceStep{call, ret(2, "6"), call},
+			reference: []model.TraceStep{call, ret(2, "6")},
+			want:      &model.TraceDivergence{Step: 3, Reason: "ended", LearnerLine: 1},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := firstDivergence(tt.learner, tt.reference); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
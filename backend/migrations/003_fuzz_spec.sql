@@ -0,0 +1,10 @@
+-- Optional random input generator for differential testing against correct_code
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS fuzz_spec JSONB;
+
+-- Two Sum Sorted: sorted unique arrays with a target that is usually reachable
+UPDATE snippets SET fuzz_spec = '{"runs": 25, "params": [{"name": "nums", "type": "int_list", "min": -50, "max": 50, "min_len": 2, "max_len": 12, "sorted": true, "unique": true}, {"name": "target", "type": "int", "min": -60, "max": 60}]}'::jsonb
+WHERE title = 'Two Sum Sorted' AND pattern_id = 1 AND fuzz_spec IS NULL;
+
+-- Valid Palindrome: short strings over a tiny alphabet so palindromes are common
+UPDATE snippets SET fuzz_spec = '{"runs": 25, "params": [{"name": "s", "type": "string", "min_len": 0, "max_len": 7, "alphabet": "ab"}]}'::jsonb
+WHERE title = 'Valid Palindrome' AND pattern_id = 1 AND fuzz_spec IS NULL;
@@ -0,0 +1,6 @@
+-- Optional performance cases and scaling check timed against correct_code
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS perf_spec JSONB;
+
+-- Two Sum Sorted: a quadratic fix passes the small cases but not the scaling check
+UPDATE snippets SET perf_spec = '{"params": [{"name": "nums", "type": "int_list", "min": -1000000, "max": 1000000, "sorted": true, "unique": true}, {"name": "target", "type": "int", "min": 2000001, "max": 2000001}], "cases": [{"size": 50000}], "scaling": {"sizes": [2000, 8000, 32000], "complexity": "O(n)"}}'::jsonb
+WHERE title = 'Two Sum Sorted' AND pattern_id = 1 AND perf_spec IS NULL;
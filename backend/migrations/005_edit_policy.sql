@@ -0,0 +1,6 @@
+-- Per-snippet policy for passing submissions that rewrite instead of fix
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS edit_policy JSONB;
+
+-- Both seeded bugs are a single token, so compare at token granularity
+UPDATE snippets SET edit_policy = '{"mode": "penalize", "granularity": "token", "penalty": 50}'::jsonb
+WHERE pattern_id = 1 AND title IN ('Two Sum Sorted', 'Valid Palindrome') AND edit_policy IS NULL;
@@ -0,0 +1,7 @@
+-- Bug localization drill: the learner's line-range guess is scored before the fix
+ALTER TABLE user_snippet_attempts ADD COLUMN IF NOT EXISTS localization_result VARCHAR(10);
+ALTER TABLE user_snippet_attempts DROP CONSTRAINT IF EXISTS check_localization_result;
+ALTER TABLE user_snippet_attempts ADD CONSTRAINT check_localization_result
+    CHECK (localization_result IN ('exact', 'near', 'wrong'));
+
+ALTER TABLE user_pattern_progress ADD COLUMN IF NOT EXISTS localization_accuracy DECIMAL(4,3);
@@ -0,0 +1,67 @@
+-- Bug type taxonomy: top-level categories have no parent
+CREATE TABLE IF NOT EXISTS bug_types (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(50) UNIQUE NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    description TEXT,
+    parent_id INT REFERENCES bug_types(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_bug_types_parent ON bug_types(parent_id);
+
+INSERT INTO bug_types (slug, name, description) VALUES
+('off-by-one', 'Off-by-one', 'A bound, index or count is one too high or too low'),
+('boundary', 'Boundary', 'Edge inputs such as empty, single-element or extreme values are mishandled'),
+('mutation', 'Mutation', 'Shared or iterated state is modified when it should not be'),
+('wrong-operator', 'Wrong operator', 'An operator is replaced by a similar but incorrect one'),
+('control-flow', 'Control flow', 'Branches, loops or returns run in the wrong order or at the wrong time'),
+('initialization', 'Initialization', 'A variable starts from the wrong value or is not reset')
+ON CONFLICT (slug) DO NOTHING;
+
+INSERT INTO bug_types (slug, name, description, parent_id)
+SELECT v.slug, v.name, v.description, p.id
+FROM (VALUES
+    ('inclusive-loop-bound', 'Inclusive loop bound', 'A loop uses <= where < is needed, or the reverse', 'off-by-one'),
+    ('range-end', 'Range end', 'range() or a slice stops one element early or late', 'off-by-one'),
+    ('index-shift', 'Index shift', 'An index is read or returned shifted by one', 'off-by-one'),
+    ('empty-input', 'Empty input', 'The code fails or returns the wrong value for empty input', 'boundary'),
+    ('single-element', 'Single element', 'The code mishandles an input with exactly one element', 'boundary'),
+    ('extreme-values', 'Extreme values', 'Minimum, maximum or negative values are not handled', 'boundary'),
+    ('shared-reference', 'Shared reference', 'Two names alias one mutable object that should be copied', 'mutation'),
+    ('mutate-while-iterating', 'Mutate while iterating', 'A collection is changed while it is being iterated', 'mutation'),
+    ('mutated-input', 'Mutated input', 'The function modifies an argument the caller still relies on', 'mutation'),
+    ('flipped-comparison', 'Flipped comparison', 'A comparison points the wrong way, such as > instead of <', 'wrong-operator'),
+    ('arithmetic-operator', 'Arithmetic operator', 'An arithmetic operator is wrong, such as - instead of +', 'wrong-operator'),
+    ('logical-operator', 'Logical operator', 'and and or are swapped, or a negation is missing', 'wrong-operator'),
+    ('early-return', 'Early return', 'The function returns before all candidates are considered', 'control-flow'),
+    ('swapped-branches', 'Swapped branches', 'The bodies of two branches are exchanged', 'control-flow'),
+    ('missing-break', 'Missing break', 'A loop keeps going after it should stop', 'control-flow'),
+    ('wrong-initial-value', 'Wrong initial value', 'An accumulator or pointer starts from the wrong value', 'initialization'),
+    ('missing-reset', 'Missing reset', 'State from a previous iteration leaks into the next one', 'initialization')
+) AS v(slug, name, description, parent_slug)
+JOIN bug_types p ON p.slug = v.parent_slug
+ON CONFLICT (slug) DO NOTHING;
+
+-- Snippets point at a leaf type; bug_type stays as the author's free-text label
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS bug_type_id INT REFERENCES bug_types(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_snippets_bug_type ON snippets(bug_type_id);
+
+UPDATE snippets SET bug_type_id = (SELECT id FROM bug_types WHERE slug = 'flipped-comparison')
+WHERE title = 'Two Sum Sorted' AND pattern_id = 1 AND bug_type_id IS NULL;
+
+UPDATE snippets SET bug_type_id = (SELECT id FROM bug_types WHERE slug = 'inclusive-loop-bound')
+WHERE title = 'Valid Palindrome' AND pattern_id = 1 AND bug_type_id IS NULL;
+
+-- Bug type quiz answers, one per user and snippet
+CREATE TABLE IF NOT EXISTS bug_type_quiz_answers (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
+    snippet_id UUID REFERENCES snippets(id) ON DELETE CASCADE,
+    chosen_bug_type_id INT REFERENCES bug_types(id) ON DELETE CASCADE,
+    correct_bug_type_id INT REFERENCES bug_types(id) ON DELETE CASCADE,
+    is_correct BOOLEAN NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW(),
+    UNIQUE (user_id, snippet_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_quiz_answers_user ON bug_type_quiz_answers(user_id);
@@ -0,0 +1,5 @@
+-- Bug type for mutants that swap the operands of a non-commutative operator
+INSERT INTO bug_types (slug, name, description, parent_id)
+SELECT 'swapped-operands', 'Swapped operands', 'The operands of -, / or % are in the wrong order', id
+FROM bug_types WHERE slug = 'wrong-operator'
+ON CONFLICT (slug) DO NOTHING;
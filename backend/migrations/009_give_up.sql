@@ -0,0 +1,4 @@
+-- Giving up reveals the answer and is recorded as an unsolved attempt
+ALTER TABLE user_snippet_attempts ADD COLUMN IF NOT EXISTS gave_up BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX IF NOT EXISTS idx_attempts_user_snippet ON user_snippet_attempts(user_id, snippet_id);
@@ -0,0 +1,2 @@
+-- Per-snippet execution limit overrides, e.g. more time and memory for heavy DP
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS limits JSONB;
@@ -0,0 +1,3 @@
+-- Go snippets are graded by a _test.go file run under go test -race
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS test_code TEXT NOT NULL DEFAULT '';
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS go_test JSONB;
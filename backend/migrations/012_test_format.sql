@@ -0,0 +1,4 @@
+-- Snippets are graded by input/expected test cases or by a test file
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS test_format TEXT NOT NULL DEFAULT 'cases';
+
+UPDATE snippets SET test_format = 'go_test' WHERE language = 'go' AND test_code <> '';
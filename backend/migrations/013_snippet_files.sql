@@ -0,0 +1,2 @@
+-- Multi-file snippets run their code next to a tree of files, some editable
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS files JSONB;
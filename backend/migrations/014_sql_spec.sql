@@ -0,0 +1,2 @@
+-- SQL snippets run their query against a schema and seed data in SQLite
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS sql_spec JSONB;
@@ -0,0 +1,2 @@
+-- Program snippets read stdin and are graded on what they print
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS stdio JSONB;
@@ -0,0 +1,41 @@
+-- Each localization guess gets a row of its own, so accuracy is averaged per
+-- guess rather than over every attempt made after one
+CREATE TABLE IF NOT EXISTS user_snippet_localizations (
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    snippet_id UUID NOT NULL REFERENCES snippets(id) ON DELETE CASCADE,
+    -- Unknown for guesses carried over from attempts
+    start_line INT,
+    end_line INT,
+    result VARCHAR(10) NOT NULL CHECK (result IN ('exact', 'near', 'wrong')),
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_localizations_user_snippet ON user_snippet_localizations(user_id, snippet_id);
+
+-- Attempts copied the guess until the snippet was solved; a guess starts
+-- where the previous attempt had none or ended the drill
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_name = 'user_snippet_attempts' AND column_name = 'localization_result'
+    ) THEN
+        INSERT INTO user_snippet_localizations (user_id, snippet_id, result, created_at)
+        SELECT user_id, snippet_id, localization_result, created_at
+        FROM (
+            SELECT a.*,
+                   LAG(a.localization_result) OVER w AS prev_result,
+                   LAG(a.is_correct OR a.gave_up) OVER w AS prev_ended
+            FROM user_snippet_attempts a
+            WINDOW w AS (PARTITION BY a.user_id, a.snippet_id ORDER BY a.created_at, a.id)
+        ) a
+        WHERE localization_result IS NOT NULL
+          AND (prev_result IS NULL OR prev_ended);
+
+        ALTER TABLE user_snippet_attempts DROP COLUMN localization_result;
+    END IF;
+END $$;
+
+-- Snippets can make the localization drill mandatory
+ALTER TABLE snippets ADD COLUMN IF NOT EXISTS require_localization BOOLEAN NOT NULL DEFAULT FALSE;
@@ -0,0 +1,42 @@
+-- Attempts carry the localization guess that was active when they were made
+ALTER TABLE user_snippet_attempts ADD COLUMN IF NOT EXISTS localization_result VARCHAR(10)
+    CHECK (localization_result IN ('exact', 'near', 'wrong'));
+
+-- 016 moved the guess off the attempts; an attempt gets back the latest guess
+-- made before it that no solve or give-up has ended since
+UPDATE user_snippet_attempts a
+SET localization_result = (
+    SELECT l.result
+    FROM user_snippet_localizations l
+    WHERE l.user_id = a.user_id AND l.snippet_id = a.snippet_id
+      AND l.created_at <= a.created_at
+      AND NOT EXISTS (
+          SELECT 1 FROM user_snippet_attempts e
+          WHERE e.user_id = a.user_id AND e.snippet_id = a.snippet_id
+            AND (e.is_correct OR e.gave_up)
+            AND e.created_at >= l.created_at AND e.created_at < a.created_at
+      )
+    ORDER BY l.created_at DESC, l.id DESC
+    LIMIT 1
+)
+WHERE a.localization_result IS NULL;
+
+-- The active guess lives here rather than only in Redis: a guess stays
+-- active until it is cleared by solving or giving up the snippet
+ALTER TABLE user_snippet_localizations ADD COLUMN IF NOT EXISTS cleared_at TIMESTAMP;
+
+-- Earlier guesses ended at the next guess or the solve or give-up after them
+UPDATE user_snippet_localizations l
+SET cleared_at = LEAST(
+    (SELECT MIN(n.created_at) FROM user_snippet_localizations n
+     WHERE n.user_id = l.user_id AND n.snippet_id = l.snippet_id
+       AND (n.created_at, n.id) > (l.created_at, l.id)),
+    (SELECT MIN(e.created_at) FROM user_snippet_attempts e
+     WHERE e.user_id = l.user_id AND e.snippet_id = l.snippet_id
+       AND (e.is_correct OR e.gave_up) AND e.created_at >= l.created_at)
+)
+WHERE l.cleared_at IS NULL;
+
+-- Only one guess per snippet can be active, so only the first counts
+CREATE UNIQUE INDEX IF NOT EXISTS idx_localizations_active
+    ON user_snippet_localizations(user_id, snippet_id) WHERE cleared_at IS NULL;